@@ -1,5 +1,102 @@
 package main
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"mein-doi-bolor/pkg/gb"
+)
+
 func main() {
-	println("mein doi bolor")
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mein-doi-bolor", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	romPath := fs.String("rom", "", "path to the ROM to run")
+	wavPath := fs.String("wav", "", "record audio to this WAV file instead of playing")
+	frames := fs.Int("frames", 0, "number of frames to record")
+	seconds := fs.Float64("seconds", 0, "number of seconds to record (ignored if -frames is set)")
+	rate := fs.Int("rate", gb.DefaultSampleRate, "WAV sample rate in Hz")
+	savePath := fs.String("save", "", "battery save file to start the recording from; it is never written")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *wavPath == "" {
+		fmt.Fprintln(stdout, "mein doi bolor")
+
+		return nil
+	}
+
+	if *romPath == "" {
+		return errors.New("-wav needs a -rom to run")
+	}
+
+	cycles := *frames * gb.CyclesPerFrame
+
+	if *frames == 0 {
+		cycles = int(*seconds * gb.ClockRate)
+	}
+
+	if cycles <= 0 {
+		return errors.New("-wav needs a positive -frames or -seconds")
+	}
+
+	if *rate <= 0 {
+		return errors.New("-rate must be positive")
+	}
+
+	return recordWAV(*romPath, *wavPath, *savePath, *rate, cycles)
+}
+
+// recordWAV runs the ROM for the given number of cycles and writes its audio
+// to a 16-bit stereo WAV file. Battery-backed RAM is loaded from savePath,
+// if given, so the recording can start from a saved game.
+func recordWAV(romPath, wavPath, savePath string, rate, cycles int) error {
+	rom, err := os.ReadFile(romPath)
+
+	if err != nil {
+		return fmt.Errorf("failed to read ROM: %w", err)
+	}
+
+	f, err := os.Create(wavPath)
+
+	if err != nil {
+		return fmt.Errorf("failed to create WAV file: %w", err)
+	}
+
+	err = writeWAV(f, rom, savePath, rate, cycles)
+
+	return errors.Join(err, f.Close())
+}
+
+// writeWAV records the ROM's audio to w as a complete WAV stream.
+func writeWAV(w io.WriteSeeker, rom []uint8, savePath string, rate, cycles int) error {
+	wav, err := gb.NewWAVWriter(w, rate)
+
+	if err != nil {
+		return err
+	}
+
+	var opts []gb.Option
+
+	if savePath != "" {
+		opts = append(opts, gb.WithSaveFile(savePath, 0))
+	}
+
+	if err := gb.RecordAudio(rom, wav, rate, cycles, opts...); err != nil {
+		return err
+	}
+
+	return wav.Close()
 }
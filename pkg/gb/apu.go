@@ -0,0 +1,376 @@
+package gb
+
+import "math"
+
+// ClockRate is the number of T-cycles the Game Boy runs per second at
+// normal speed.
+const ClockRate = 4194304
+
+const (
+	// DefaultSampleRate is the PCM rate used until a sink asks for another.
+	DefaultSampleRate = 48000
+
+	frameSequencerCycles = ClockRate / 512
+	apuBufferFrames      = 1024
+	apuChannels          = 4
+	apuMaxLevel          = apuChannels * 0x0F * 8 // four DACs at full swing, NR50 at 7
+	apuChargeFactor      = 0.999958               // high-pass capacitor charge kept per T-cycle
+	nr52Power            = 0x80
+	nrxTrigger           = 0x80
+	nrxLengthEnable      = 0x40
+	nr30DACOn            = 0x80
+	apuRegisters         = regWave - regNR10
+)
+
+// AudioSink receives the APU's output as interleaved stereo (left, right)
+// signed 16-bit PCM. The slice is only valid for the duration of the call.
+type AudioSink interface {
+	WriteSamples(samples []int16)
+}
+
+// apu is the audio processing unit: two square channels (the first with a
+// frequency sweep), the wave channel and the noise channel. A 512 Hz frame
+// sequencer clocks their length counters, envelopes and sweep, and NR50/NR51
+// mix them into two outputs, which are resampled to the sink's rate. Each
+// channel's DAC turns its digital level into an analog one centred on zero,
+// and a high-pass filter removes the offset left when the levels don't
+// average out, as the capacitors on the output do.
+//
+// Reference: Pan Docs - Audio, Audio details
+// https://gbdev.io/pandocs/Audio.html
+// https://gbdev.io/pandocs/Audio_details.html
+type apu struct {
+	power bool
+	regs  [apuRegisters]uint8 // last values written to NR10-NR51, read back through the bus
+
+	ch1 *square
+	ch2 *square
+	ch3 *wave
+	ch4 *noise
+
+	nr50 uint8
+	nr51 uint8
+
+	sequencerCycles int
+	sequencerStep   uint8
+
+	sink       AudioSink
+	sampleRate int
+	phase      int // resampling accumulator, in units of ClockRate
+	sumLeft    int
+	sumRight   int
+	sumCount   int
+	charge     float64    // capacitor charge kept per output sample
+	capacitor  [2]float64 // high-pass state, left and right
+	buffer     []int16
+}
+
+func newAPU() *apu {
+	return &apu{
+		ch1:        newSquare(true),
+		ch2:        newSquare(false),
+		ch3:        newWave(),
+		ch4:        newNoise(),
+		sampleRate: DefaultSampleRate,
+		charge:     chargeFactor(DefaultSampleRate),
+		buffer:     make([]int16, 0, apuBufferFrames*2),
+	}
+}
+
+// chargeFactor scales the per-cycle capacitor charge to one output sample.
+func chargeFactor(sampleRate int) float64 {
+	return math.Pow(apuChargeFactor, float64(ClockRate)/float64(sampleRate))
+}
+
+// SetSink routes audio to sink at the given sample rate. A nil sink stops
+// sample generation; the channels keep running either way.
+func (a *apu) SetSink(sink AudioSink, sampleRate int) {
+	a.Flush()
+
+	a.sink = sink
+	a.sampleRate = sampleRate
+	a.phase = 0
+	a.sumLeft, a.sumRight, a.sumCount = 0, 0, 0
+	a.charge = chargeFactor(sampleRate)
+	a.capacitor = [2]float64{}
+}
+
+// Flush hands any buffered samples to the sink.
+func (a *apu) Flush() {
+	if a.sink != nil && len(a.buffer) > 0 {
+		a.sink.WriteSamples(a.buffer)
+	}
+
+	a.buffer = a.buffer[:0]
+}
+
+func (a *apu) Read(addr uint16) uint8 {
+	switch {
+	case addr >= regWave:
+		return a.ch3.ram[addr-regWave]
+	case addr == regNR52:
+		return a.status()
+	case addr > regNR52:
+		return 0xFF
+	}
+
+	return a.regs[addr-regNR10]
+}
+
+// status is NR52: the power bit and one "on" bit per channel.
+func (a *apu) status() uint8 {
+	value := uint8(0)
+
+	if a.power {
+		value |= nr52Power
+	}
+
+	for i, on := range []bool{a.ch1.enabled, a.ch2.enabled, a.ch3.enabled, a.ch4.enabled} {
+		if on {
+			value |= 1 << i
+		}
+	}
+
+	return value
+}
+
+func (a *apu) Write(addr uint16, value uint8) {
+	switch {
+	case addr >= regWave:
+		a.ch3.ram[addr-regWave] = value
+
+		return
+	case addr == regNR52:
+		a.setPower(value&nr52Power != 0)
+
+		return
+	case addr > regNR52 || !a.power:
+		return
+	}
+
+	a.regs[addr-regNR10] = value
+
+	switch addr {
+	case regNR10:
+		a.ch1.sweep.write(value)
+	case regNR11:
+		a.ch1.writeDuty(value)
+	case regNR12:
+		writeEnvelope(&a.ch1.envelope, &a.ch1.enabled, value)
+	case regNR13:
+		a.ch1.period = a.ch1.period&0x700 | uint16(value)
+	case regNR14:
+		a.ch1.writeControl(value)
+	case regNR21:
+		a.ch2.writeDuty(value)
+	case regNR22:
+		writeEnvelope(&a.ch2.envelope, &a.ch2.enabled, value)
+	case regNR23:
+		a.ch2.period = a.ch2.period&0x700 | uint16(value)
+	case regNR24:
+		a.ch2.writeControl(value)
+	case regNR30:
+		a.ch3.dacOn = value&nr30DACOn != 0
+		a.ch3.enabled = a.ch3.enabled && a.ch3.dacOn
+	case regNR31:
+		a.ch3.length.load(int(value))
+	case regNR32:
+		a.ch3.level = value >> 5 & 0x03
+	case regNR33:
+		a.ch3.period = a.ch3.period&0x700 | uint16(value)
+	case regNR34:
+		a.ch3.period = a.ch3.period&0xFF | uint16(value&0x07)<<8
+		a.ch3.length.enabled = value&nrxLengthEnable != 0
+
+		if value&nrxTrigger != 0 {
+			a.ch3.trigger()
+		}
+	case regNR41:
+		a.ch4.length.load(int(value & 0x3F))
+	case regNR42:
+		writeEnvelope(&a.ch4.envelope, &a.ch4.enabled, value)
+	case regNR43:
+		a.ch4.write(value)
+	case regNR44:
+		a.ch4.length.enabled = value&nrxLengthEnable != 0
+
+		if value&nrxTrigger != 0 {
+			a.ch4.trigger()
+		}
+	case regNR50:
+		a.nr50 = value
+	case regNR51:
+		a.nr51 = value
+	}
+}
+
+// writeEnvelope loads NRx2. Clearing the upper five bits turns the DAC, and
+// with it the channel, off.
+func writeEnvelope(e *envelope, enabled *bool, value uint8) {
+	e.write(value)
+
+	if !e.dacOn() {
+		*enabled = false
+	}
+}
+
+// setPower handles NR52 bit 7. Turning the APU off clears every register
+// except wave RAM and ignores writes until it is turned back on.
+func (a *apu) setPower(on bool) {
+	if a.power == on {
+		return
+	}
+
+	a.power = on
+
+	if on {
+		a.sequencerStep = 0
+
+		return
+	}
+
+	ram := a.ch3.ram
+	a.regs = [apuRegisters]uint8{}
+	a.ch1 = newSquare(true)
+	a.ch2 = newSquare(false)
+	a.ch3 = newWave()
+	a.ch3.ram = ram
+	a.ch4 = newNoise()
+	a.nr50 = 0
+	a.nr51 = 0
+}
+
+// Tick advances the channels, the frame sequencer and the resampler.
+func (a *apu) Tick(cycles int) {
+	for range cycles {
+		if a.power {
+			a.ch1.tick()
+			a.ch2.tick()
+			a.ch3.tick()
+			a.ch4.tick()
+			a.clockSequencer()
+		}
+
+		if a.sink != nil {
+			a.sample()
+		}
+	}
+}
+
+// clockSequencer steps the 512 Hz frame sequencer:
+//
+//	step   0    1    2    3    4    5    6    7
+//	length x         x         x         x
+//	sweep            x                   x
+//	volume                                    x
+func (a *apu) clockSequencer() {
+	a.sequencerCycles++
+
+	if a.sequencerCycles < frameSequencerCycles {
+		return
+	}
+
+	a.sequencerCycles = 0
+
+	if a.sequencerStep%2 == 0 {
+		a.clockLengths()
+	}
+
+	if a.sequencerStep == 2 || a.sequencerStep == 6 {
+		a.ch1.clockSweep()
+	}
+
+	if a.sequencerStep == 7 {
+		a.ch1.envelope.clock()
+		a.ch2.envelope.clock()
+		a.ch4.envelope.clock()
+	}
+
+	a.sequencerStep = (a.sequencerStep + 1) & 0x07
+}
+
+func (a *apu) clockLengths() {
+	if a.ch1.length.clock() {
+		a.ch1.enabled = false
+	}
+
+	if a.ch2.length.clock() {
+		a.ch2.enabled = false
+	}
+
+	if a.ch3.length.clock() {
+		a.ch3.enabled = false
+	}
+
+	if a.ch4.length.clock() {
+		a.ch4.enabled = false
+	}
+}
+
+// mix converts the channel levels to analog and applies NR51 panning and
+// NR50 master volume. A DAC maps digital 0-15 linearly to +15..-15; a DAC
+// that is off outputs nothing.
+func (a *apu) mix() (int, int) {
+	outputs := [apuChannels]uint8{a.ch1.output(), a.ch2.output(), a.ch3.output(), a.ch4.output()}
+	dacs := [apuChannels]bool{a.ch1.envelope.dacOn(), a.ch2.envelope.dacOn(), a.ch3.dacOn, a.ch4.envelope.dacOn()}
+	left, right := 0, 0
+
+	for i, level := range outputs {
+		if !dacs[i] {
+			continue
+		}
+
+		analog := 0x0F - 2*int(level)
+
+		if a.nr51&(0x10<<i) != 0 {
+			left += analog
+		}
+
+		if a.nr51&(0x01<<i) != 0 {
+			right += analog
+		}
+	}
+
+	left *= int(a.nr50>>4&0x07) + 1
+	right *= int(a.nr50&0x07) + 1
+
+	return left, right
+}
+
+// sample averages the mix over every T-cycle since the previous output
+// sample, a box filter that is cheap and takes the edge off aliasing, then
+// runs it through the high-pass filter.
+func (a *apu) sample() {
+	left, right := a.mix()
+	a.sumLeft += left
+	a.sumRight += right
+	a.sumCount++
+
+	a.phase += a.sampleRate
+
+	if a.phase < ClockRate {
+		return
+	}
+
+	a.phase -= ClockRate
+
+	scale := float64(a.sumCount * apuMaxLevel)
+	a.buffer = append(a.buffer,
+		a.highPass(0, float64(a.sumLeft)/scale),
+		a.highPass(1, float64(a.sumRight)/scale),
+	)
+	a.sumLeft, a.sumRight, a.sumCount = 0, 0, 0
+
+	if len(a.buffer) == cap(a.buffer) {
+		a.Flush()
+	}
+}
+
+// highPass filters one side's output, -1 to 1, and converts it to PCM. The
+// capacitor charges towards the input, so a constant level decays to zero.
+func (a *apu) highPass(side int, in float64) int16 {
+	out := in - a.capacitor[side]
+	a.capacitor[side] = in - out*a.charge
+
+	return int16(max(-1, min(1, out)) * 0x7FFF)
+}
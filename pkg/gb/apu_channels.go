@@ -0,0 +1,386 @@
+package gb
+
+const (
+	maxPeriod       = 2047
+	squareLength    = 64
+	waveLength      = 256
+	noiseLength     = 64
+	waveSamples     = 32
+	lfsrReset       = 0x7FFF
+	lfsrWidth7Bit   = 6
+	lfsrHighBit     = 14
+	sweepEnvDefault = 8 // a period of 0 reloads the sweep/envelope timer with 8
+)
+
+// squareDuties holds the 8-step waveforms for 12.5%, 25%, 50% and 75% duty.
+//
+// Reference: Pan Docs - Audio Registers, NR11
+// https://gbdev.io/pandocs/Audio_Registers.html
+var squareDuties = [4]uint8{0b00000001, 0b10000001, 0b10000111, 0b01111110}
+
+// noiseDivisors maps NR43's divisor code to a period in T-cycles, before
+// the clock shift is applied.
+var noiseDivisors = [8]int{8, 16, 32, 48, 64, 80, 96, 112}
+
+// lengthCounter silences a channel once it has counted down, if enabled.
+type lengthCounter struct {
+	max     int
+	value   int
+	enabled bool
+}
+
+// load sets the counter from the value written to NRx1.
+func (l *lengthCounter) load(value int) {
+	l.value = l.max - value
+}
+
+// reload refills an expired counter on trigger.
+func (l *lengthCounter) reload() {
+	if l.value == 0 {
+		l.value = l.max
+	}
+}
+
+// clock runs on frame sequencer steps 0, 2, 4 and 6 and reports whether the
+// channel should be switched off.
+func (l *lengthCounter) clock() bool {
+	if !l.enabled || l.value == 0 {
+		return false
+	}
+
+	l.value--
+
+	return l.value == 0
+}
+
+// envelope ramps a channel's volume up or down every period/64 seconds.
+type envelope struct {
+	initial  uint8
+	increase bool
+	period   uint8
+	volume   uint8
+	timer    uint8
+}
+
+// write loads NRx2.
+func (e *envelope) write(value uint8) {
+	e.initial = value >> 4
+	e.increase = value&0x08 != 0
+	e.period = value & 0x07
+}
+
+// dacOn reports whether NRx2 powers the channel's DAC: any of the upper five
+// bits set.
+func (e *envelope) dacOn() bool {
+	return e.initial != 0 || e.increase
+}
+
+func (e *envelope) trigger() {
+	e.volume = e.initial
+	e.timer = e.period
+
+	if e.timer == 0 {
+		e.timer = sweepEnvDefault
+	}
+}
+
+// clock runs on frame sequencer step 7.
+func (e *envelope) clock() {
+	if e.period == 0 {
+		return
+	}
+
+	e.timer--
+
+	if e.timer > 0 {
+		return
+	}
+
+	e.timer = e.period
+
+	switch {
+	case e.increase && e.volume < 0x0F:
+		e.volume++
+	case !e.increase && e.volume > 0:
+		e.volume--
+	}
+}
+
+// sweep is channel 1's periodic frequency shift.
+type sweep struct {
+	period  uint8
+	negate  bool
+	shift   uint8
+	timer   uint8
+	enabled bool
+	shadow  uint16
+}
+
+// write loads NR10.
+func (s *sweep) write(value uint8) {
+	s.period = value >> 4 & 0x07
+	s.negate = value&0x08 != 0
+	s.shift = value & 0x07
+}
+
+// next computes the swept period and reports whether it overflowed.
+func (s *sweep) next() (uint16, bool) {
+	delta := s.shadow >> s.shift
+
+	if s.negate {
+		return s.shadow - delta, false
+	}
+
+	next := s.shadow + delta
+
+	return next, next > maxPeriod
+}
+
+// square is a pulse channel. Channel 1 additionally owns a sweep unit.
+type square struct {
+	enabled  bool
+	duty     uint8
+	step     uint8
+	period   uint16
+	timer    int
+	length   lengthCounter
+	envelope envelope
+	sweep    *sweep
+}
+
+func newSquare(withSweep bool) *square {
+	s := &square{length: lengthCounter{max: squareLength}}
+
+	if withSweep {
+		s.sweep = &sweep{}
+	}
+
+	return s
+}
+
+// writeDuty loads NRx1.
+func (s *square) writeDuty(value uint8) {
+	s.duty = value >> 6
+	s.length.load(int(value & 0x3F))
+}
+
+// writeControl loads NRx4: the period's upper bits, length enable and
+// trigger.
+func (s *square) writeControl(value uint8) {
+	s.period = s.period&0xFF | uint16(value&0x07)<<8
+	s.length.enabled = value&nrxLengthEnable != 0
+
+	if value&nrxTrigger != 0 {
+		s.trigger()
+	}
+}
+
+func (s *square) reloadTimer() {
+	s.timer = int(maxPeriod+1-s.period) * 4
+}
+
+func (s *square) trigger() {
+	s.enabled = s.envelope.dacOn()
+	s.length.reload()
+	s.reloadTimer()
+	s.envelope.trigger()
+
+	if s.sweep == nil {
+		return
+	}
+
+	sw := s.sweep
+	sw.shadow = s.period
+	sw.timer = sw.period
+
+	if sw.timer == 0 {
+		sw.timer = sweepEnvDefault
+	}
+
+	sw.enabled = sw.period != 0 || sw.shift != 0
+
+	if sw.shift != 0 {
+		if _, overflow := sw.next(); overflow {
+			s.enabled = false
+		}
+	}
+}
+
+// clockSweep runs on frame sequencer steps 2 and 6.
+func (s *square) clockSweep() {
+	sw := s.sweep
+
+	sw.timer--
+
+	if sw.timer > 0 {
+		return
+	}
+
+	sw.timer = sw.period
+
+	if sw.timer == 0 {
+		sw.timer = sweepEnvDefault
+	}
+
+	if !sw.enabled || sw.period == 0 {
+		return
+	}
+
+	next, overflow := sw.next()
+
+	if overflow {
+		s.enabled = false
+
+		return
+	}
+
+	if sw.shift == 0 {
+		return
+	}
+
+	sw.shadow = next
+	s.period = next
+
+	// The new period is checked for overflow once more, but not applied
+	if _, overflow := sw.next(); overflow {
+		s.enabled = false
+	}
+}
+
+func (s *square) tick() {
+	s.timer--
+
+	if s.timer > 0 {
+		return
+	}
+
+	s.reloadTimer()
+	s.step = (s.step + 1) & 0x07
+}
+
+// output returns the channel's digital level, 0-15.
+func (s *square) output() uint8 {
+	if !s.enabled {
+		return 0
+	}
+
+	return (squareDuties[s.duty] >> (7 - s.step) & 0x01) * s.envelope.volume
+}
+
+// wave plays 32 4-bit samples from wave RAM.
+type wave struct {
+	enabled  bool
+	dacOn    bool
+	level    uint8 // NR32 output level code
+	period   uint16
+	timer    int
+	position uint8
+	sample   uint8
+	length   lengthCounter
+	ram      [waveSamples / 2]uint8
+}
+
+func newWave() *wave {
+	return &wave{length: lengthCounter{max: waveLength}}
+}
+
+func (w *wave) reloadTimer() {
+	w.timer = int(maxPeriod+1-w.period) * 2
+}
+
+func (w *wave) trigger() {
+	w.enabled = w.dacOn
+	w.length.reload()
+	w.reloadTimer()
+	w.position = 0
+}
+
+func (w *wave) tick() {
+	w.timer--
+
+	if w.timer > 0 {
+		return
+	}
+
+	w.reloadTimer()
+	w.position = (w.position + 1) % waveSamples
+	w.sample = w.ram[w.position/2]
+
+	if w.position%2 == 0 {
+		w.sample >>= 4
+	}
+
+	w.sample &= 0x0F
+}
+
+// output returns the channel's digital level, 0-15. Output level codes 0-3
+// mean mute, 100%, 50% and 25%.
+func (w *wave) output() uint8 {
+	if !w.enabled || w.level == 0 {
+		return 0
+	}
+
+	return w.sample >> (w.level - 1)
+}
+
+// noise is the pseudo-random channel driven by a 15-bit LFSR, optionally
+// shortened to 7 bits for a more tonal sound.
+type noise struct {
+	enabled  bool
+	shift    uint8
+	width7   bool
+	divisor  uint8
+	timer    int
+	lfsr     uint16
+	length   lengthCounter
+	envelope envelope
+}
+
+func newNoise() *noise {
+	return &noise{length: lengthCounter{max: noiseLength}, lfsr: lfsrReset}
+}
+
+// write loads NR43.
+func (n *noise) write(value uint8) {
+	n.shift = value >> 4
+	n.width7 = value&0x08 != 0
+	n.divisor = value & 0x07
+}
+
+func (n *noise) reloadTimer() {
+	n.timer = noiseDivisors[n.divisor] << n.shift
+}
+
+func (n *noise) trigger() {
+	n.enabled = n.envelope.dacOn()
+	n.length.reload()
+	n.reloadTimer()
+	n.envelope.trigger()
+	n.lfsr = lfsrReset
+}
+
+func (n *noise) tick() {
+	n.timer--
+
+	if n.timer > 0 {
+		return
+	}
+
+	n.reloadTimer()
+
+	bit := (n.lfsr ^ n.lfsr>>1) & 0x01
+	n.lfsr = n.lfsr>>1 | bit<<lfsrHighBit
+
+	if n.width7 {
+		n.lfsr = n.lfsr&^(1<<lfsrWidth7Bit) | bit<<lfsrWidth7Bit
+	}
+}
+
+// output returns the channel's digital level, 0-15.
+func (n *noise) output() uint8 {
+	if !n.enabled || n.lfsr&0x01 != 0 {
+		return 0
+	}
+
+	return n.envelope.volume
+}
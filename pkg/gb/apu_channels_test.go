@@ -0,0 +1,203 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// SQUARE CHANNELS
+// =============================================================================
+//
+// The duty step advances every (2048 - period) * 4 T-cycles through one of
+// four 8-step waveforms.
+//
+// Reference: Pan Docs - Audio Details
+// https://gbdev.io/pandocs/Audio_details.html
+
+func TestSquare_DutyWaveforms(t *testing.T) {
+	testCases := []struct {
+		name string
+		duty uint8
+		want [8]uint8
+	}{
+		{"12.5%", 0, [8]uint8{0, 0, 0, 0, 0, 0, 0, 1}},
+		{"25%", 1, [8]uint8{1, 0, 0, 0, 0, 0, 0, 1}},
+		{"50%", 2, [8]uint8{1, 0, 0, 0, 0, 1, 1, 1}},
+		{"75%", 3, [8]uint8{0, 1, 1, 1, 1, 1, 1, 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSquare(false)
+			s.envelope.write(0x10) // volume 1
+			s.writeDuty(tc.duty << 6)
+			s.period = maxPeriod // 4 T-cycles per step
+			s.writeControl(nrxTrigger | 0x07)
+
+			var got [8]uint8
+
+			for i := range got {
+				got[i] = s.output()
+
+				for range 4 {
+					s.tick()
+				}
+			}
+
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSquare_Envelope(t *testing.T) {
+	testCases := []struct {
+		name  string
+		nrx2  uint8
+		want  []uint8
+		dacOn bool
+	}{
+		{"decrease", 0x31, []uint8{3, 2, 1, 0, 0}, true},
+		{"increase", 0xE9, []uint8{14, 15, 15}, true},
+		{"period 0 holds", 0x50, []uint8{5, 5, 5}, true},
+		{"DAC off", 0x00, []uint8{0}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSquare(false)
+			s.envelope.write(tc.nrx2)
+			s.trigger()
+
+			require.Equal(t, tc.dacOn, s.enabled)
+
+			for _, volume := range tc.want {
+				require.Equal(t, volume, s.envelope.volume)
+				s.envelope.clock()
+			}
+		})
+	}
+}
+
+func TestSquare_Sweep(t *testing.T) {
+	testCases := []struct {
+		name    string
+		nr10    uint8
+		period  uint16
+		want    uint16
+		enabled bool
+	}{
+		{"increase", 0x11, 0x100, 0x180, true},
+		{"decrease", 0x19, 0x100, 0x080, true},
+		{"overflow disables", 0x11, 0x600, 0x600, false},
+		{"shift 0 keeps the period", 0x10, 0x100, 0x100, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSquare(true)
+			s.sweep.write(tc.nr10)
+			s.envelope.write(0xF0)
+			s.period = tc.period
+			s.trigger()
+
+			s.clockSweep()
+
+			require.Equal(t, tc.want, s.period)
+			require.Equal(t, tc.enabled, s.enabled)
+		})
+	}
+}
+
+func TestLengthCounter(t *testing.T) {
+	l := lengthCounter{max: squareLength}
+	l.load(62)
+	l.enabled = true
+
+	require.False(t, l.clock())
+	require.True(t, l.clock(), "two clocks exhaust a length of 2")
+	require.False(t, l.clock())
+
+	l.reload()
+	require.Equal(t, squareLength, l.value, "trigger refills an expired counter")
+}
+
+// =============================================================================
+// WAVE AND NOISE CHANNELS
+// =============================================================================
+
+func TestWave_OutputLevels(t *testing.T) {
+	testCases := []struct {
+		level uint8
+		want  uint8
+	}{
+		{0, 0},
+		{1, 0x0C},
+		{2, 0x06},
+		{3, 0x03},
+	}
+
+	for _, tc := range testCases {
+		w := newWave()
+		w.ram[0] = 0xAC // samples 0xA, 0xC
+		w.dacOn = true
+		w.period = maxPeriod // 2 T-cycles per sample
+		w.level = tc.level
+		w.trigger()
+
+		// The first sample played after a trigger is sample 1
+		w.tick()
+		w.tick()
+
+		require.Equal(t, tc.want, w.output(), "level %d", tc.level)
+	}
+}
+
+func TestNoise_LFSRPeriod(t *testing.T) {
+	testCases := []struct {
+		name   string
+		nr43   uint8
+		period int
+	}{
+		{"15-bit", 0x00, 0x7FFF},
+		{"7-bit", 0x08, 0x7F},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newNoise()
+			n.write(tc.nr43)
+			n.envelope.write(0xF0)
+			n.trigger()
+
+			// Step until the register first repeats
+			seen := n.lfsr
+			steps := 0
+
+			for {
+				for range noiseDivisors[0] {
+					n.tick()
+				}
+
+				steps++
+
+				if n.lfsr&lfsrPeriodMask(n) == seen&lfsrPeriodMask(n) || steps > 0x8000 {
+					break
+				}
+			}
+
+			require.Equal(t, tc.period, steps)
+		})
+	}
+}
+
+// lfsrPeriodMask returns the LFSR bits that take part in the sequence.
+func lfsrPeriodMask(n *noise) uint16 {
+	if n.width7 {
+		return 0x7F
+	}
+
+	return 0x7FFF
+}
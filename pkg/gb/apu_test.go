@@ -0,0 +1,241 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// captureSink records every sample it receives.
+type captureSink struct {
+	samples []int16
+}
+
+func (s *captureSink) WriteSamples(samples []int16) {
+	s.samples = append(s.samples, samples...)
+}
+
+// newAPUTestBus returns a bus with the APU powered on and every channel
+// panned to both sides at full volume.
+func newAPUTestBus() *bus {
+	b := newBus()
+	b.Write(regNR52, nr52Power)
+	b.Write(regNR50, 0x77)
+	b.Write(regNR51, 0xFF)
+
+	return b
+}
+
+// =============================================================================
+// APU REGISTERS
+// =============================================================================
+//
+// Reference: Pan Docs - Audio Registers
+// https://gbdev.io/pandocs/Audio_Registers.html
+
+func TestAPU_RegisterReadBack(t *testing.T) {
+	testCases := []struct {
+		name  string
+		addr  uint16
+		value uint8
+		want  uint8
+	}{
+		{"NR10", regNR10, 0x7F, 0xFF},
+		{"NR11 length is write-only", regNR11, 0x85, 0xBF},
+		{"NR12", regNR12, 0xA3, 0xA3},
+		{"NR13 is write-only", regNR13, 0x12, 0xFF},
+		{"NR14 only length enable", regNR14, 0x47, 0xFF},
+		{"NR32", regNR32, 0x40, 0xDF},
+		{"NR50", regNR50, 0x35, 0x35},
+		{"unused 0xFF27", 0xFF27, 0x00, 0xFF},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newAPUTestBus()
+			b.Write(tc.addr, tc.value)
+
+			value, _ := b.Read(tc.addr)
+			require.Equal(t, tc.want, value)
+		})
+	}
+}
+
+func TestAPU_PowerOff(t *testing.T) {
+	b := newAPUTestBus()
+	b.Write(regWave, 0x5A)
+	b.Write(regNR12, 0xF0)
+	b.Write(regNR14, nrxTrigger)
+
+	status, _ := b.Read(regNR52)
+	require.Equal(t, uint8(0xF1), status, "power and channel 1 on")
+
+	b.Write(regNR52, 0x00)
+	b.Write(regNR50, 0x77)
+
+	status, _ = b.Read(regNR52)
+	require.Equal(t, uint8(0x70), status)
+
+	value, _ := b.Read(regNR50)
+	require.Equal(t, uint8(0x00), value, "registers are cleared and read-only while off")
+
+	value, _ = b.Read(regWave)
+	require.Equal(t, uint8(0x5A), value, "wave RAM survives")
+}
+
+func TestAPU_TriggerAndDAC(t *testing.T) {
+	b := newAPUTestBus()
+
+	b.Write(regNR22, 0x00)
+	b.Write(regNR24, nrxTrigger)
+	require.False(t, b.apu.ch2.enabled, "triggering with the DAC off does nothing")
+
+	b.Write(regNR22, 0x08)
+	b.Write(regNR24, nrxTrigger)
+	require.True(t, b.apu.ch2.enabled)
+
+	b.Write(regNR22, 0x00)
+	require.False(t, b.apu.ch2.enabled, "turning the DAC off stops the channel")
+
+	b.Write(regNR30, nr30DACOn)
+	b.Write(regNR34, nrxTrigger)
+	b.Write(regNR42, 0xF0)
+	b.Write(regNR44, nrxTrigger)
+
+	status, _ := b.Read(regNR52)
+	require.Equal(t, uint8(0xFC), status)
+}
+
+// =============================================================================
+// FRAME SEQUENCER
+// =============================================================================
+//
+// A 512 Hz sequencer clocks length on even steps, sweep on steps 2 and 6 and
+// the envelopes on step 7.
+
+func TestAPU_FrameSequencer_Length(t *testing.T) {
+	b := newAPUTestBus()
+	b.Write(regNR12, 0xF0)
+	b.Write(regNR11, 0x3F) // length 1
+	b.Write(regNR14, nrxTrigger|nrxLengthEnable)
+
+	b.tick(frameSequencerCycles-1, false)
+	require.True(t, b.apu.ch1.enabled)
+
+	b.tick(1, false)
+	require.False(t, b.apu.ch1.enabled, "step 0 clocks length")
+}
+
+func TestAPU_FrameSequencer_Envelope(t *testing.T) {
+	b := newAPUTestBus()
+	b.Write(regNR42, 0x71) // volume 7, decreasing every step 7
+	b.Write(regNR44, nrxTrigger)
+
+	b.tick(frameSequencerCycles*7, false)
+	require.Equal(t, uint8(7), b.apu.ch4.envelope.volume)
+
+	b.tick(frameSequencerCycles, false)
+	require.Equal(t, uint8(6), b.apu.ch4.envelope.volume)
+
+	b.tick(frameSequencerCycles*8, false)
+	require.Equal(t, uint8(5), b.apu.ch4.envelope.volume, "one envelope clock per 8 steps")
+}
+
+// =============================================================================
+// MIXING AND OUTPUT
+// =============================================================================
+
+func TestAPU_Panning(t *testing.T) {
+	b := newAPUTestBus()
+	b.Write(regNR51, 0x20) // channel 2 left only
+	b.Write(regNR50, 0x70) // left volume 8, right 1
+	b.Write(regNR22, 0xF0) // DAC on
+	b.apu.ch2.enabled = true
+	b.apu.ch2.envelope.volume = 0x0F
+	b.apu.ch2.duty = 3
+	b.apu.ch2.step = 1
+
+	left, right := b.apu.mix()
+	require.Equal(t, -0x0F*8, left, "digital 15 is the DAC's negative peak")
+	require.Zero(t, right)
+}
+
+func TestAPU_SilenceIsCentred(t *testing.T) {
+	b := newAPUTestBus()
+	sink := &captureSink{}
+	b.apu.SetSink(sink, DefaultSampleRate)
+
+	// DACs on, but every channel outputs a steady digital 0.
+	b.Write(regNR12, 0x08)
+	b.Write(regNR22, 0x08)
+	b.Write(regNR30, nr30DACOn)
+	b.Write(regNR42, 0x08)
+
+	left, _ := b.apu.mix()
+	require.Equal(t, apuMaxLevel, left, "the DACs sit at their positive peak")
+
+	b.tick(ClockRate/4, false)
+	b.apu.Flush()
+
+	tail := sink.samples[len(sink.samples)-DefaultSampleRate/100*2:]
+
+	for _, sample := range tail {
+		require.InDelta(t, 0, sample, 1, "the high-pass filter removes the offset")
+	}
+}
+
+func TestAPU_SinkReceivesResampledStereo(t *testing.T) {
+	testCases := []struct {
+		name string
+		rate int
+	}{
+		{"48 kHz", 48000},
+		{"44.1 kHz", 44100},
+		{"22.05 kHz", 22050},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newAPUTestBus()
+			sink := &captureSink{}
+			b.apu.SetSink(sink, tc.rate)
+
+			b.Write(regNR12, 0xF0)
+			b.Write(regNR13, 0x00)
+			b.Write(regNR14, nrxTrigger|0x07)
+
+			b.tick(ClockRate/4, false)
+			b.apu.Flush()
+
+			require.Len(t, sink.samples, tc.rate/4*2)
+
+			peak, trough := int16(0), int16(0)
+
+			for _, sample := range sink.samples {
+				peak = max(peak, sample)
+				trough = min(trough, sample)
+			}
+
+			require.Positive(t, peak, "the square wave is audible")
+			require.Negative(t, trough, "and swings around zero")
+		})
+	}
+}
+
+func TestAPU_ClockedByCPUStep(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	sink := &captureSink{}
+	cpu.bus.apu.SetSink(sink, DefaultSampleRate)
+
+	// NOPs
+	for range 1000 {
+		_, err := cpu.Step()
+		require.NoError(t, err)
+	}
+
+	cpu.bus.apu.Flush()
+
+	require.Len(t, sink.samples, 4000*DefaultSampleRate/ClockRate*2)
+}
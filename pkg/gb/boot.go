@@ -0,0 +1,137 @@
+package gb
+
+import "fmt"
+
+const (
+	dmgBootROMSize = 0x100
+	cgbBootROMSize = 0x900
+	cgbBootGapEnd  = 0x200 // 0x0100-0x01FF always shows the cartridge
+)
+
+// BootROMSizeError is returned when a boot ROM image does not have the size
+// the model's boot ROM has.
+type BootROMSizeError struct {
+	Model  Model
+	Size   int
+	Expect int
+}
+
+func (e *BootROMSizeError) Error() string {
+	return fmt.Sprintf("%v boot ROM must be %d bytes, got %d", e.Model, e.Expect, e.Size)
+}
+
+// bootControl is the BOOT register at 0xFF50 and, on color models, KEY0 at
+// 0xFF4C. Writing any non-zero value to BOOT unmaps the boot ROM for good;
+// only a reset maps it again. KEY0 is how the CGB boot ROM drops into DMG
+// compatibility mode, and is locked once the boot ROM is gone.
+type bootControl struct {
+	bus *bus
+}
+
+func (bc *bootControl) Read(_ uint16) uint8 {
+	return 0xFF
+}
+
+func (bc *bootControl) Write(addr uint16, value uint8) {
+	if addr == regKEY0 {
+		if bc.bus.bootMapped {
+			bc.bus.cgbMode = value&key0DMGCompat == 0
+		}
+
+		return
+	}
+
+	if value != 0 {
+		bc.bus.bootMapped = false
+	}
+}
+
+// SetBootROM installs a boot ROM image for the bus's model. It is mapped
+// from the next reset on; nil goes back to skipping the boot sequence.
+func (b *bus) SetBootROM(image []uint8) error {
+	if image != nil && len(image) != b.model.bootROMSize() {
+		return &BootROMSizeError{Model: b.model, Size: len(image), Expect: b.model.bootROMSize()}
+	}
+
+	b.bootROM = image
+
+	return nil
+}
+
+// readBootROM returns the boot ROM byte overlaying addr, if any.
+func (b *bus) readBootROM(addr uint16) (uint8, bool) {
+	if !b.bootMapped {
+		return 0, false
+	}
+
+	if addr < dmgBootROMSize || addr >= cgbBootGapEnd && int(addr) < len(b.bootROM) {
+		return b.bootROM[addr], true
+	}
+
+	return 0, false
+}
+
+// powerOn puts the I/O registers the boot ROM is responsible for in their
+// power-on state and maps the boot ROM.
+func (b *bus) powerOn() {
+	b.bootMapped = true
+	b.cgbMode = b.model.IsCGB()
+	b.vramBank, b.wramBank = 0, 1
+	b.ppu.opri = 0
+	b.timer.counter = 0
+	b.ppu.lcdc = 0
+	b.ppu.bgp = 0
+	b.dma.page = 0
+}
+
+// skipBoot puts the I/O registers where the model's boot ROM leaves them.
+func (b *bus) skipBoot(state bootState) {
+	b.bootMapped = false
+	b.selectCGBMode()
+	b.timer.counter = state.div
+	b.ppu.lcdc = initLCDC
+	b.ppu.bgp = initBGP
+
+	b.dma.page = 0xFF
+
+	if b.model.IsCGB() {
+		b.dma.page = 0x00
+	}
+
+	// The boot chime leaves the APU on with channel 1 set up, but silent
+	b.apu.Write(regNR52, nr52Power)
+	b.apu.Write(regNR11, 0xBF)
+	b.apu.Write(regNR12, 0xF3)
+	b.apu.Write(regNR50, 0x77)
+	b.apu.Write(regNR51, 0xF3)
+}
+
+// reset starts the CPU either at the boot ROM, with every register cleared,
+// or straight at the cartridge entry point with the registers the model's
+// boot ROM would have left.
+func (c *cpu) reset() {
+	c.ime, c.imeDelay = false, false
+	c.halted, c.haltBug, c.stopped = false, false, false
+	c.bus.timer.stopped = false
+	c.doubleSpeed, c.speedSwitchArmed = false, false
+	c.cycles = 0
+	c.sp = initSP
+	c.bus.registerCGB()
+	c.registerKEY1()
+	c.bus.registerSGB()
+
+	if c.bus.bootROM != nil {
+		c.a, c.f, c.b, c.c, c.d, c.e, c.h, c.l = 0, 0, 0, 0, 0, 0, 0, 0
+		c.sp = 0
+		c.pc = 0
+		c.bus.powerOn()
+
+		return
+	}
+
+	state := postBootState(c.bus.model, c.bus.cartridge)
+	c.a, c.f, c.b, c.c = state.a, state.f, state.b, state.c
+	c.d, c.e, c.h, c.l = state.d, state.e, state.h, state.l
+	c.pc = initPC
+	c.bus.skipBoot(state)
+}
@@ -0,0 +1,209 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// POST-BOOT STATE
+// =============================================================================
+//
+// Without a boot ROM the CPU starts at 0x0100 with the registers the model's
+// boot ROM would have left.
+//
+// Reference: Pan Docs - Power Up Sequence
+// https://gbdev.io/pandocs/Power_Up_Sequence.html
+
+func TestBoot_PostBootRegistersPerModel(t *testing.T) {
+	testCases := []struct {
+		model Model
+		cgb   bool   // cartridge header CGB flag
+		af    uint16 // expected AF, BC, DE, HL
+		bc    uint16
+		de    uint16
+		hl    uint16
+	}{
+		{ModelDMG0, false, 0x0100, 0xFF13, 0x00C1, 0x8403},
+		{ModelDMG, false, 0x01B0, 0x0013, 0x00D8, 0x014D},
+		{ModelMGB, false, 0xFFB0, 0x0013, 0x00D8, 0x014D},
+		{ModelSGB, false, 0x0100, 0x0014, 0x0000, 0xC060},
+		{ModelCGB, true, 0x1180, 0x0000, 0xFF56, 0x000D},
+		{ModelCGB, false, 0x1180, 0x0000, 0x0008, 0x007C},
+		{ModelAGB, true, 0x1100, 0x0100, 0xFF56, 0x000D},
+		{ModelAGB, false, 0x1100, 0x0100, 0x0008, 0x007C},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.model.String(), func(t *testing.T) {
+			cpu, err := newCPUForModel(tc.model, nil)
+			require.NoError(t, err)
+
+			rom := newTestROM(0x00, 0x00, 0x00)
+
+			if tc.cgb {
+				rom[headerCGBFlag] = cgbFlagSupported
+				fixTestROMChecksums(rom)
+			}
+
+			require.NoError(t, cpu.bus.LoadROM(rom))
+			cpu.reset()
+
+			require.Equal(t, tc.af, cpu.AF())
+			require.Equal(t, tc.bc, cpu.BC())
+			require.Equal(t, tc.de, cpu.DE())
+			require.Equal(t, tc.hl, cpu.HL())
+			require.Equal(t, initSP, cpu.SP())
+			require.Equal(t, initPC, cpu.PC())
+		})
+	}
+}
+
+func TestBoot_DMGFlagsFollowHeaderChecksum(t *testing.T) {
+	rom := newTestROM(0x00, 0x00, 0x00)
+
+	// Pick a version byte that makes the header checksum zero
+	for version := range 0x100 {
+		rom[headerVersion] = uint8(version)
+
+		if computeHeaderChecksum(rom) == 0 {
+			break
+		}
+	}
+
+	fixTestROMChecksums(rom)
+	require.Zero(t, rom[headerChecksum])
+
+	cpu := newCPU()
+	require.NoError(t, cpu.bus.LoadROM(rom))
+	cpu.reset()
+
+	require.Equal(t, uint8(flagZ), cpu.F(), "H and C stay clear")
+}
+
+func TestBoot_PostBootIO(t *testing.T) {
+	cpu := newCPU()
+
+	value, _ := cpu.bus.Read(regDIV)
+	require.Equal(t, uint8(0xAB), value)
+
+	value, _ = cpu.bus.Read(regLCDC)
+	require.Equal(t, initLCDC, value)
+
+	value, _ = cpu.bus.Read(regNR52)
+	require.Equal(t, uint8(0xF0), value)
+
+	value, _ = cpu.bus.Read(regNR51)
+	require.Equal(t, uint8(0xF3), value)
+
+	value, _ = cpu.bus.Read(regDMA)
+	require.Equal(t, uint8(0xFF), value)
+}
+
+// =============================================================================
+// BOOT ROM OVERLAY
+// =============================================================================
+//
+// The boot ROM covers 0x0000-0x00FF (and 0x0200-0x08FF on color models)
+// until a non-zero value is written to 0xFF50.
+
+// testBootROM returns an image filled with 0xB0 that ends by unmapping
+// itself, as the real boot ROMs do at 0x00FC.
+func testBootROM(model Model) []uint8 {
+	image := make([]uint8, model.bootROMSize())
+
+	for i := range image {
+		image[i] = 0xB0
+	}
+
+	// NOP sled up to LD A,$01 ; LDH ($50),A
+	for i := range 0xFC {
+		image[i] = 0x00
+	}
+
+	copy(image[0xFC:], []uint8{0x3E, 0x01, 0xE0, 0x50})
+
+	return image
+}
+
+func TestBoot_Overlay(t *testing.T) {
+	testCases := []struct {
+		model    Model
+		overlaid []uint16
+		cart     []uint16
+	}{
+		{ModelDMG, []uint16{0x0000, 0x00FF}, []uint16{0x0100, 0x0200, 0x08FF}},
+		{ModelCGB, []uint16{0x0000, 0x00FF, 0x0200, 0x08FF}, []uint16{0x0100, 0x01FF, 0x0900}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.model.String(), func(t *testing.T) {
+			cpu, err := newCPUForModel(tc.model, testBootROM(tc.model))
+			require.NoError(t, err)
+
+			rom := make([]uint8, Size32Kb)
+
+			for i := range rom {
+				rom[i] = 0xCA
+			}
+
+			cpu.bus.mapper = newROMOnly(rom)
+
+			for _, addr := range tc.overlaid {
+				value, _ := cpu.bus.Read(addr)
+				require.NotEqual(t, uint8(0xCA), value, "0x%04X shows the boot ROM", addr)
+			}
+
+			for _, addr := range tc.cart {
+				value, _ := cpu.bus.Read(addr)
+				require.Equal(t, uint8(0xCA), value, "0x%04X shows the cartridge", addr)
+			}
+
+			cpu.bus.Write(regBOOT, 0x00)
+			value, _ := cpu.bus.Read(0x0000)
+			require.Equal(t, uint8(0x00), value, "writing zero keeps the boot ROM")
+
+			cpu.bus.Write(regBOOT, 0x01)
+			value, _ = cpu.bus.Read(0x0000)
+			require.Equal(t, uint8(0xCA), value)
+		})
+	}
+}
+
+func TestBoot_RunsFromZeroAndHandsOver(t *testing.T) {
+	cpu, err := newCPUForModel(ModelDMG, testBootROM(ModelDMG))
+	require.NoError(t, err)
+
+	require.Equal(t, uint16(0x0000), cpu.PC())
+	require.Equal(t, uint16(0x0000), cpu.SP())
+	require.Equal(t, uint16(0x0000), cpu.AF())
+	require.Equal(t, uint16(0x0000), cpu.HL())
+
+	value, _ := cpu.bus.Read(regLCDC)
+	require.Equal(t, uint8(0x00), value, "the boot ROM turns the LCD on itself")
+
+	value, _ = cpu.bus.Read(regNR52)
+	require.Equal(t, uint8(0x70), value)
+
+	for cpu.PC() != initPC {
+		_, err := cpu.Step()
+		require.NoError(t, err)
+	}
+
+	require.False(t, cpu.bus.bootMapped)
+
+	// Reset maps the boot ROM again
+	cpu.reset()
+	require.True(t, cpu.bus.bootMapped)
+	require.Equal(t, uint16(0x0000), cpu.PC())
+}
+
+func TestBoot_ImageSize(t *testing.T) {
+	_, err := newCPUForModel(ModelCGB, make([]uint8, dmgBootROMSize))
+
+	var sizeErr *BootROMSizeError
+	require.ErrorAs(t, err, &sizeErr)
+	require.Equal(t, cgbBootROMSize, sizeErr.Expect)
+}
@@ -3,39 +3,107 @@ package gb
 import "fmt"
 
 type bus struct {
-	rom  []uint8
+	model   Model
+	cgbMode bool // a CGB model running a CGB cartridge, not DMG compatibility mode
+
 	vram []uint8
 	wram []uint8
+	oam  []uint8
 	hram []uint8
-	ie   uint8
+	io   [ioSize]device
+
+	vramBank uint8 // VBK, CGB mode only
+	wramBank uint8 // SVBK, 1-7 at 0xD000, CGB mode only
+
+	cartridge  *Cartridge
+	mapper     Mapper
+	clock      Clock
+	bootROM    []uint8
+	bootMapped bool
+	interrupts *interrupts
+	ppu        *ppu
+	dma        *dma
+	hdma       *hdma
+	sgb        *sgb // only on the SGB model
+	timer      *timer
+	joypad     *Joypad
+	apu        *apu
+	serial     *serial
 }
 
 const Size32Kb = 0x8000
 const Size8Kb = 0x2000
 const Size127b = 0x7F
+const Size160b = 0xA0
 
 func newBus() *bus {
-	return &bus{
-		rom:  make([]uint8, Size32Kb),
-		vram: make([]uint8, Size8Kb),
-		wram: make([]uint8, Size8Kb),
+	b := &bus{
+		vram: make([]uint8, vramBankSize*cgbVRAMBanks),
+		wram: make([]uint8, wramBankSize*cgbWRAMBanks),
+		oam:  make([]uint8, Size160b),
 		hram: make([]uint8, Size127b),
-		ie:   uint8(0),
+
+		wramBank: 1,
+
+		mapper:     newROMOnly(make([]uint8, Size32Kb)),
+		clock:      systemClock{},
+		interrupts: newInterrupts(),
 	}
+
+	b.dma = newDMA(b)
+	b.hdma = newHDMA(b)
+	b.timer = newTimer(b.interrupts)
+	b.joypad = newJoypad(b.interrupts)
+	b.apu = newAPU()
+	b.serial = newSerial(b.interrupts)
+
+	b.register(regP1, regP1, b.joypad)
+	b.register(regSB, regSC, b.serial)
+	b.register(regDIV, regTAC, b.timer)
+	b.register(regIF, regIF, b.interrupts)
+	b.register(regNR10, regWaveE, b.apu)
+	b.register(regBOOT, regBOOT, &bootControl{bus: b})
+	b.register(regDMA, regDMA, b.dma)
+	b.attachPPU(RenderScanline)
+
+	return b
 }
 
 func (b *bus) Read(addr uint16) (uint8, error) {
+	if b.dma.blocks(addr) {
+		return b.dma.conflict(addr), nil
+	}
+
+	return b.read(addr)
+}
+
+// read is the memory map without OAM DMA bus conflicts, as seen by the DMA
+// controller itself.
+func (b *bus) read(addr uint16) (uint8, error) {
+	if value, ok := b.readBootROM(addr); ok {
+		return value, nil
+	}
+
 	switch {
 	case addr == 0xFFFF:
-		return b.ie, nil
+		return b.interrupts.enable, nil
 	case addr <= 0x7FFF:
-		return b.rom[addr], nil
+		return b.mapper.ReadROM(addr), nil
 	case addr >= 0x8000 && addr <= 0x9FFF:
-		return b.vram[addr-0x8000], nil
+		return b.vram[b.vramOffset(addr)], nil
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		return b.mapper.ReadRAM(addr), nil
 	case addr >= 0xC000 && addr <= 0xDFFF:
-		return b.wram[addr-0xC000], nil
+		return b.wram[b.wramOffset(addr)], nil
 	case addr >= 0xE000 && addr <= 0xFDFF:
-		return b.wram[addr-0xE000], nil
+		return b.wram[b.wramOffset(addr-0x2000)], nil
+	case addr >= 0xFE00 && addr <= 0xFE9F:
+		return b.oam[addr-0xFE00], nil
+	case addr >= 0xFEA0 && addr <= 0xFEFF:
+		// Unusable region: DMG reads return 0x00
+		return 0x00, nil
+	case addr >= ioStart && addr <= ioEnd:
+		return b.readIO(addr), nil
 	case addr >= 0xFF80 && addr <= 0xFFFE:
 		return b.hram[addr-0xFF80], nil
 	}
@@ -44,17 +112,27 @@ func (b *bus) Read(addr uint16) (uint8, error) {
 }
 
 func (b *bus) Write(addr uint16, value uint8) error {
+	if b.dma.blocks(addr) {
+		return nil
+	}
+
 	switch {
 	case addr == 0xFFFF:
-		b.ie = value
+		b.interrupts.enable = value
 	case addr <= 0x7FFF:
-		return fmt.Errorf("attempt to write to ROM address: 0x%04X", addr)
+		b.mapper.WriteROM(addr, value)
 	case addr >= 0x8000 && addr <= 0x9FFF:
-		b.vram[addr-0x8000] = value
+		b.vram[b.vramOffset(addr)] = value
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		b.mapper.WriteRAM(addr, value)
 	case addr >= 0xC000 && addr <= 0xDFFF:
-		b.wram[addr-0xC000] = value
+		b.wram[b.wramOffset(addr)] = value
 	case addr >= 0xE000 && addr <= 0xFDFF:
-		b.wram[addr-0xE000] = value
+		b.wram[b.wramOffset(addr-0x2000)] = value
+	case addr >= 0xFE00 && addr <= 0xFE9F:
+		b.oam[addr-0xFE00] = value
+	case addr >= ioStart && addr <= ioEnd:
+		b.writeIO(addr, value)
 	case addr >= 0xFF80 && addr <= 0xFFFE:
 		b.hram[addr-0xFF80] = value
 	}
@@ -62,14 +140,54 @@ func (b *bus) Write(addr uint16, value uint8) error {
 	return nil
 }
 
+// attachPPU replaces the PPU with one using the given render mode. Both modes
+// draw from the bus's VRAM and OAM.
+func (b *bus) attachPPU(mode RenderMode) {
+	b.ppu = newPPU(b, mode)
+
+	b.register(regLCDC, regLYC, b.ppu)
+	b.register(regBGP, regWX, b.ppu)
+	b.registerCGB()
+}
+
+// tick advances every peripheral by the cycles the CPU just spent. The PPU
+// and APU run off the 4 MiHz clock whatever the CPU speed.
+func (b *bus) tick(cycles int, doubleSpeed bool) {
+	b.timer.Tick(cycles)
+	b.serial.Tick(cycles)
+	b.dma.Tick(cycles)
+	b.ppu.Tick(dots(cycles, doubleSpeed))
+	b.apu.Tick(dots(cycles, doubleSpeed))
+}
+
+// LoadROM parses the cartridge header and plugs in the mapper it asks for.
+// Images too short to carry a header are mapped as a bare 32 KiB ROM, which
+// is what small test programs rely on.
 func (b *bus) LoadROM(data []uint8) error {
-	if len(data) > len(b.rom) {
-		return fmt.Errorf("ROM size exceeds maximum of %d bytes", len(b.rom))
+	if len(data) < headerEnd {
+		rom := make([]uint8, Size32Kb)
+		copy(rom, data)
+
+		b.cartridge = nil
+		b.mapper = newROMOnly(rom)
+
+		return nil
 	}
 
-	for i := 0; i < len(b.rom) && i < len(data); i++ {
-		b.rom[i] = data[i]
+	cart, err := ParseCartridge(data)
+
+	if err != nil {
+		return fmt.Errorf("failed to load ROM: %w", err)
 	}
 
+	m, err := newMapper(cart, b.clock)
+
+	if err != nil {
+		return fmt.Errorf("failed to load ROM: %w", err)
+	}
+
+	b.cartridge = cart
+	b.mapper = m
+
 	return nil
 }
@@ -0,0 +1,237 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Cartridge header layout (0x0100-0x014F).
+//
+// Reference: Pan Docs - The Cartridge Header
+// https://gbdev.io/pandocs/The_Cartridge_Header.html
+const (
+	headerTitleStart        = 0x0134
+	headerTitleEnd          = 0x0144 // exclusive; 16 bytes on DMG cartridges
+	headerCGBTitleEnd       = 0x013F // exclusive; 11 bytes when a CGB flag is present
+	headerManufacturerStart = 0x013F
+	headerManufacturerEnd   = 0x0143
+	headerCGBFlag           = 0x0143
+	headerNewLicenseeStart  = 0x0144
+	headerNewLicenseeEnd    = 0x0146
+	headerSGBFlag           = 0x0146
+	headerCartridgeType     = 0x0147
+	headerROMSize           = 0x0148
+	headerRAMSize           = 0x0149
+	headerDestination       = 0x014A
+	headerOldLicensee       = 0x014B
+	headerVersion           = 0x014C
+	headerChecksum          = 0x014D
+	headerGlobalChecksum    = 0x014E
+	headerEnd               = 0x0150
+)
+
+const (
+	cgbFlagSupported = 0x80 // works on DMG and CGB
+	cgbFlagOnly      = 0xC0 // CGB only
+	sgbFlagSupported = 0x03
+	oldLicenseeNew   = 0x33 // licensee is in the new licensee code instead
+	romBankSize      = 0x4000
+	ramBankSize      = Size8Kb
+	maxROMSizeCode   = 0x08 // 8 MiB
+)
+
+// ramSizes maps the RAM size header byte to a size in bytes.
+var ramSizes = map[uint8]int{
+	0x00: 0,
+	0x01: 0x800, // unofficial 2 KiB
+	0x02: 1 * ramBankSize,
+	0x03: 4 * ramBankSize,
+	0x04: 16 * ramBankSize,
+	0x05: 8 * ramBankSize,
+}
+
+// ErrHeaderTruncated is returned when the ROM image is too short to contain
+// a cartridge header.
+var ErrHeaderTruncated = errors.New("ROM is too short to contain a cartridge header")
+
+// HeaderChecksumError is returned when the header checksum at 0x014D does not
+// match the bytes 0x0134-0x014C. The boot ROM refuses to start such a
+// cartridge.
+type HeaderChecksumError struct {
+	Expected uint8
+	Actual   uint8
+}
+
+func (e *HeaderChecksumError) Error() string {
+	return fmt.Sprintf("header checksum mismatch: header says 0x%02X, computed 0x%02X", e.Expected, e.Actual)
+}
+
+// GlobalChecksumError is returned when the global checksum at 0x014E-0x014F
+// does not match the ROM contents. Real hardware never checks it.
+type GlobalChecksumError struct {
+	Expected uint16
+	Actual   uint16
+}
+
+func (e *GlobalChecksumError) Error() string {
+	return fmt.Sprintf("global checksum mismatch: header says 0x%04X, computed 0x%04X", e.Expected, e.Actual)
+}
+
+// ROMSizeError is returned when the ROM image size does not match the size
+// declared at 0x0148, or the declared size code is unknown.
+type ROMSizeError struct {
+	Code     uint8
+	Declared int
+	Actual   int
+}
+
+func (e *ROMSizeError) Error() string {
+	if e.Declared == 0 {
+		return fmt.Sprintf("unknown ROM size code 0x%02X", e.Code)
+	}
+
+	return fmt.Sprintf("ROM size mismatch: header declares %d bytes, got %d", e.Declared, e.Actual)
+}
+
+// RAMSizeError is returned when the RAM size code at 0x0149 is unknown.
+type RAMSizeError struct {
+	Code uint8
+}
+
+func (e *RAMSizeError) Error() string {
+	return fmt.Sprintf("unknown RAM size code 0x%02X", e.Code)
+}
+
+// Cartridge is a ROM image together with its parsed header.
+type Cartridge struct {
+	Title            string
+	ManufacturerCode string
+	CGBFlag          uint8
+	NewLicenseeCode  string
+	SGBFlag          uint8
+	Type             uint8
+	ROMSize          int // bytes
+	RAMSize          int // bytes, as declared in the header
+	Destination      uint8
+	OldLicenseeCode  uint8
+	Version          uint8
+	HeaderChecksum   uint8
+	GlobalChecksum   uint16
+
+	rom []uint8
+}
+
+// ParseCartridge parses and validates the header of a ROM image. The header
+// checksum and the declared ROM size must match; the global checksum is only
+// checked by VerifyGlobalChecksum, as on hardware.
+func ParseCartridge(data []uint8) (*Cartridge, error) {
+	if len(data) < headerEnd {
+		return nil, ErrHeaderTruncated
+	}
+
+	cart := &Cartridge{
+		CGBFlag:         data[headerCGBFlag],
+		SGBFlag:         data[headerSGBFlag],
+		Type:            data[headerCartridgeType],
+		Destination:     data[headerDestination],
+		OldLicenseeCode: data[headerOldLicensee],
+		Version:         data[headerVersion],
+		HeaderChecksum:  data[headerChecksum],
+		GlobalChecksum:  uint16(data[headerGlobalChecksum])<<8 | uint16(data[headerGlobalChecksum+1]),
+		rom:             data,
+	}
+
+	if cart.IsCGB() {
+		cart.Title = headerString(data[headerTitleStart:headerCGBTitleEnd])
+		cart.ManufacturerCode = headerString(data[headerManufacturerStart:headerManufacturerEnd])
+	} else {
+		cart.Title = headerString(data[headerTitleStart:headerTitleEnd])
+	}
+
+	if cart.OldLicenseeCode == oldLicenseeNew {
+		cart.NewLicenseeCode = headerString(data[headerNewLicenseeStart:headerNewLicenseeEnd])
+	}
+
+	if actual := computeHeaderChecksum(data); actual != cart.HeaderChecksum {
+		return nil, &HeaderChecksumError{Expected: cart.HeaderChecksum, Actual: actual}
+	}
+
+	romSizeCode := data[headerROMSize]
+
+	if romSizeCode > maxROMSizeCode {
+		return nil, &ROMSizeError{Code: romSizeCode, Actual: len(data)}
+	}
+
+	cart.ROMSize = Size32Kb << romSizeCode
+
+	if cart.ROMSize != len(data) {
+		return nil, &ROMSizeError{Code: romSizeCode, Declared: cart.ROMSize, Actual: len(data)}
+	}
+
+	ramSize, ok := ramSizes[data[headerRAMSize]]
+
+	if !ok {
+		return nil, &RAMSizeError{Code: data[headerRAMSize]}
+	}
+
+	cart.RAMSize = ramSize
+
+	return cart, nil
+}
+
+// IsCGB reports whether the cartridge enables CGB features.
+func (c *Cartridge) IsCGB() bool {
+	return c.CGBFlag == cgbFlagSupported || c.CGBFlag == cgbFlagOnly
+}
+
+// IsCGBOnly reports whether the cartridge refuses to run on a DMG.
+func (c *Cartridge) IsCGBOnly() bool {
+	return c.CGBFlag == cgbFlagOnly
+}
+
+// SupportsSGB reports whether the cartridge enables SGB functions. Per the
+// hardware, this also requires the old licensee code to be 0x33.
+func (c *Cartridge) SupportsSGB() bool {
+	return c.SGBFlag == sgbFlagSupported && c.OldLicenseeCode == oldLicenseeNew
+}
+
+// ROM returns the raw ROM image.
+func (c *Cartridge) ROM() []uint8 {
+	return c.rom
+}
+
+// VerifyGlobalChecksum checks the 16-bit sum of every ROM byte except the
+// two checksum bytes themselves.
+func (c *Cartridge) VerifyGlobalChecksum() error {
+	var sum uint16
+
+	for i, value := range c.rom {
+		if i == headerGlobalChecksum || i == headerGlobalChecksum+1 {
+			continue
+		}
+
+		sum += uint16(value)
+	}
+
+	if sum != c.GlobalChecksum {
+		return &GlobalChecksumError{Expected: c.GlobalChecksum, Actual: sum}
+	}
+
+	return nil
+}
+
+func computeHeaderChecksum(data []uint8) uint8 {
+	var checksum uint8
+
+	for addr := headerTitleStart; addr < headerChecksum; addr++ {
+		checksum = checksum - data[addr] - 1
+	}
+
+	return checksum
+}
+
+// headerString decodes a NUL-padded ASCII header field.
+func headerString(field []uint8) string {
+	return strings.TrimRight(string(field), "\x00")
+}
@@ -0,0 +1,144 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestROM builds a ROM image of the size declared by romSizeCode with a
+// valid header and checksums. Each 16 KiB bank starts with its bank number
+// so banking tests can tell banks apart.
+func newTestROM(cartType, romSizeCode, ramSizeCode uint8) []uint8 {
+	rom := make([]uint8, Size32Kb<<romSizeCode)
+
+	for bank := 0; bank < len(rom)/romBankSize; bank++ {
+		rom[bank*romBankSize] = uint8(bank)
+		rom[bank*romBankSize+1] = uint8(bank >> 8)
+	}
+
+	copy(rom[headerTitleStart:], "TESTROM")
+	rom[headerCartridgeType] = cartType
+	rom[headerROMSize] = romSizeCode
+	rom[headerRAMSize] = ramSizeCode
+
+	fixTestROMChecksums(rom)
+
+	return rom
+}
+
+// fixTestROMChecksums recomputes both header checksums after a test edits
+// the header.
+func fixTestROMChecksums(rom []uint8) {
+	rom[headerChecksum] = computeHeaderChecksum(rom)
+
+	var sum uint16
+
+	for i, value := range rom {
+		if i != headerGlobalChecksum && i != headerGlobalChecksum+1 {
+			sum += uint16(value)
+		}
+	}
+
+	rom[headerGlobalChecksum] = uint8(sum >> 8)
+	rom[headerGlobalChecksum+1] = uint8(sum)
+}
+
+// =============================================================================
+// CARTRIDGE HEADER
+// =============================================================================
+//
+// Every cartridge carries a header at 0x0100-0x014F describing its title,
+// hardware (mapper, RAM, battery) and checksums.
+//
+// Reference: Pan Docs - The Cartridge Header
+// https://gbdev.io/pandocs/The_Cartridge_Header.html
+
+func TestCartridge_Parse(t *testing.T) {
+	rom := newTestROM(0x13, 0x02, 0x03)
+	rom[headerDestination] = 0x01
+	rom[headerOldLicensee] = 0x33
+	copy(rom[headerNewLicenseeStart:], "01")
+	rom[headerSGBFlag] = 0x03
+	rom[headerVersion] = 0x02
+	fixTestROMChecksums(rom)
+
+	cart, err := ParseCartridge(rom)
+	require.NoError(t, err)
+
+	require.Equal(t, "TESTROM", cart.Title)
+	require.Equal(t, uint8(0x13), cart.Type)
+	require.Equal(t, 128*1024, cart.ROMSize)
+	require.Equal(t, 32*1024, cart.RAMSize)
+	require.Equal(t, uint8(0x01), cart.Destination)
+	require.Equal(t, "01", cart.NewLicenseeCode)
+	require.Equal(t, uint8(0x02), cart.Version)
+	require.True(t, cart.SupportsSGB())
+	require.False(t, cart.IsCGB())
+	require.NoError(t, cart.VerifyGlobalChecksum())
+}
+
+func TestCartridge_Parse_CGBTitleAndManufacturer(t *testing.T) {
+	rom := newTestROM(0x00, 0x00, 0x00)
+	copy(rom[headerTitleStart:], "POKEMON CRY")
+	copy(rom[headerManufacturerStart:], "BYTE")
+	rom[headerCGBFlag] = 0xC0
+	fixTestROMChecksums(rom)
+
+	cart, err := ParseCartridge(rom)
+	require.NoError(t, err)
+
+	require.Equal(t, "POKEMON CRY", cart.Title)
+	require.Equal(t, "BYTE", cart.ManufacturerCode)
+	require.True(t, cart.IsCGB())
+	require.True(t, cart.IsCGBOnly())
+}
+
+func TestCartridge_Parse_Errors(t *testing.T) {
+	t.Run("truncated", func(t *testing.T) {
+		_, err := ParseCartridge(make([]uint8, 0x100))
+		require.ErrorIs(t, err, ErrHeaderTruncated)
+	})
+
+	t.Run("header checksum", func(t *testing.T) {
+		rom := newTestROM(0x00, 0x00, 0x00)
+		rom[headerChecksum]++
+
+		_, err := ParseCartridge(rom)
+
+		var checksumErr *HeaderChecksumError
+		require.ErrorAs(t, err, &checksumErr)
+	})
+
+	t.Run("ROM smaller than declared", func(t *testing.T) {
+		rom := newTestROM(0x01, 0x01, 0x00)
+
+		_, err := ParseCartridge(rom[:Size32Kb])
+
+		var sizeErr *ROMSizeError
+		require.ErrorAs(t, err, &sizeErr)
+		require.Equal(t, 64*1024, sizeErr.Declared)
+		require.Equal(t, 32*1024, sizeErr.Actual)
+	})
+
+	t.Run("unknown RAM size", func(t *testing.T) {
+		rom := newTestROM(0x00, 0x00, 0x09)
+
+		_, err := ParseCartridge(rom)
+
+		var ramErr *RAMSizeError
+		require.ErrorAs(t, err, &ramErr)
+	})
+
+	t.Run("global checksum", func(t *testing.T) {
+		rom := newTestROM(0x00, 0x00, 0x00)
+		rom[0x4000]++
+
+		cart, err := ParseCartridge(rom)
+		require.NoError(t, err, "the global checksum is not checked on load")
+
+		var checksumErr *GlobalChecksumError
+		require.ErrorAs(t, cart.VerifyGlobalChecksum(), &checksumErr)
+	})
+}
@@ -0,0 +1,175 @@
+package gb
+
+import "image/color"
+
+const (
+	vramBankSize    = 0x2000
+	wramBankSize    = 0x1000
+	cgbVRAMBanks    = 2
+	cgbWRAMBanks    = 8
+	paletteRAMSize  = 64 // 8 palettes of 4 RGB555 colors
+	paletteAutoInc  = 0x80
+	paletteIndex    = 0x3F
+	key0DMGCompat   = 0x04 // KEY0 bit 2: the boot ROM selects DMG compatibility mode
+	opriCoordinates = 0x01 // OPRI bit 0: DMG-style X priority for sprites
+)
+
+// BG map attribute bits, stored in VRAM bank 1 behind each tile index.
+const (
+	bgAttrPalette  = 0x07
+	bgAttrBank     = 0x08
+	bgAttrFlipX    = 0x20
+	bgAttrFlipY    = 0x40
+	bgAttrPriority = 0x80 // BG colors 1-3 are drawn over every sprite
+)
+
+// CGB sprite attribute bits, in addition to the DMG ones.
+const (
+	attrCGBPalette = 0x07
+	attrBank       = 0x08
+)
+
+// paletteRAM is one of the two CGB palette memories, reached through an
+// index register (BCPS/OCPS) and a data register (BCPD/OCPD). Bit 7 of the
+// index makes every data write advance the index.
+//
+// Reference: Pan Docs - LCD Color Palettes (CGB only)
+// https://gbdev.io/pandocs/Palettes.html#lcd-color-palettes-cgb-only
+type paletteRAM struct {
+	index uint8
+	data  [paletteRAMSize]uint8
+}
+
+func (r *paletteRAM) writeIndex(value uint8) {
+	r.index = value & (paletteAutoInc | paletteIndex)
+}
+
+func (r *paletteRAM) readData() uint8 {
+	return r.data[r.index&paletteIndex]
+}
+
+func (r *paletteRAM) writeData(value uint8) {
+	r.data[r.index&paletteIndex] = value
+
+	if r.index&paletteAutoInc != 0 {
+		r.index = paletteAutoInc | (r.index+1)&paletteIndex
+	}
+}
+
+// set stores four RGB555 colors as palette number palette.
+func (r *paletteRAM) set(palette uint8, colors [4]uint16) {
+	for i, c := range colors {
+		offset := int(palette)*8 + i*2
+		r.data[offset] = uint8(c)
+		r.data[offset+1] = uint8(c >> 8)
+	}
+}
+
+// color converts color index of palette number palette to RGBA.
+func (r *paletteRAM) color(palette, index uint8) color.RGBA {
+	offset := int(palette&0x07)*8 + int(index)*2
+
+	return rgb555(uint16(r.data[offset]) | uint16(r.data[offset+1])<<8)
+}
+
+// rgb555 converts a CGB or SGB color to RGBA, scaling the 5-bit channels to
+// 8 bits.
+func rgb555(rgb uint16) color.RGBA {
+	return color.RGBA{
+		R: expand5(uint8(rgb & 0x1F)),
+		G: expand5(uint8(rgb >> 5 & 0x1F)),
+		B: expand5(uint8(rgb >> 10 & 0x1F)),
+		A: 0xFF,
+	}
+}
+
+func expand5(v uint8) uint8 {
+	return v<<3 | v>>2
+}
+
+// cgbBanks is VBK (0xFF4F) and SVBK (0xFF70). Both are locked to their
+// defaults outside CGB mode.
+type cgbBanks struct {
+	bus *bus
+}
+
+func (cb *cgbBanks) Read(addr uint16) uint8 {
+	if addr == regVBK {
+		return cb.bus.vramBank
+	}
+
+	return cb.bus.wramBank
+}
+
+func (cb *cgbBanks) Write(addr uint16, value uint8) {
+	if !cb.bus.cgbMode {
+		return
+	}
+
+	if addr == regVBK {
+		cb.bus.vramBank = value & (cgbVRAMBanks - 1)
+
+		return
+	}
+
+	// Bank 0 is always at 0xC000, so selecting it maps bank 1
+	cb.bus.wramBank = max(value&(cgbWRAMBanks-1), 1)
+}
+
+// vramOffset maps 0x8000-0x9FFF to the selected VRAM bank.
+func (b *bus) vramOffset(addr uint16) int {
+	return int(b.vramBank)*vramBankSize + int(addr-0x8000)
+}
+
+// wramOffset maps 0xC000-0xDFFF to WRAM: bank 0, then the switchable bank.
+func (b *bus) wramOffset(addr uint16) int {
+	if addr < 0xD000 {
+		return int(addr - 0xC000)
+	}
+
+	return int(b.wramBank)*wramBankSize + int(addr-0xD000)
+}
+
+// registerCGB maps the color-only registers on CGB models and unmaps them
+// on the others.
+func (b *bus) registerCGB() {
+	if !b.model.IsCGB() {
+		b.register(regKEY0, regKEY0, nil)
+		b.register(regVBK, regVBK, nil)
+		b.register(regSVBK, regSVBK, nil)
+		b.register(regHDMA1, regHDMA5, nil)
+		b.register(regBCPS, regOPRI, nil)
+
+		return
+	}
+
+	banks := &cgbBanks{bus: b}
+
+	b.register(regKEY0, regKEY0, &bootControl{bus: b})
+	b.register(regVBK, regVBK, banks)
+	b.register(regSVBK, regSVBK, banks)
+	b.register(regHDMA1, regHDMA5, b.hdma)
+	b.register(regBCPS, regOPRI, b.ppu)
+}
+
+// selectCGBMode decides between CGB mode and DMG compatibility mode the way
+// the boot ROM does, from the cartridge's CGB flag, and loads the
+// cartridge's compatibility palettes if needed. Bare test images count as
+// CGB programs.
+func (b *bus) selectCGBMode() {
+	b.cgbMode = b.model.IsCGB() && (b.cartridge == nil || b.cartridge.IsCGB())
+	b.vramBank = 0
+	b.wramBank = 1
+
+	if !b.model.IsCGB() || b.cgbMode {
+		b.ppu.opri = 0
+
+		return
+	}
+
+	palette := compatPaletteFor(b.cartridge)
+	b.ppu.opri = opriCoordinates
+	b.ppu.bgPalettes.set(0, palette.bg)
+	b.ppu.objPalettes.set(0, palette.obj0)
+	b.ppu.objPalettes.set(1, palette.obj1)
+}
@@ -0,0 +1,250 @@
+package gb
+
+const (
+	compatLicenseeNintendo    = 0x01 // old licensee code
+	compatNewLicenseeNintendo = "01"
+	compatDisambiguation      = 3 // title letter checked when several titles share a checksum
+)
+
+// compatPalette is a set of colors the CGB boot ROM gives a DMG cartridge:
+// the BGP, OBP0 and OBP1 shades index into these instead of grey levels.
+type compatPalette struct {
+	bg   [4]uint16 // RGB555
+	obj0 [4]uint16
+	obj1 [4]uint16
+}
+
+// compatColors is the boot ROM's table of four-color palettes, RGB555.
+var compatColors = [...]uint16{
+	0x7FFF, 0x32BF, 0x00D0, 0x0000, // 0
+	0x639F, 0x4279, 0x15B0, 0x04CB, // 1
+	0x7FFF, 0x6E31, 0x454A, 0x0000, // 2
+	0x7FFF, 0x1BEF, 0x0200, 0x0000, // 3
+	0x7FFF, 0x421F, 0x1CF2, 0x0000, // 4
+	0x7FFF, 0x5294, 0x294A, 0x0000, // 5
+	0x7FFF, 0x03FF, 0x012F, 0x0000, // 6
+	0x7FFF, 0x03EF, 0x01D6, 0x0000, // 7
+	0x7FFF, 0x42B5, 0x3DC8, 0x0000, // 8
+	0x7E74, 0x03FF, 0x0180, 0x0000, // 9
+	0x67FF, 0x77AC, 0x1A13, 0x2D6B, // 10
+	0x7ED6, 0x4BFF, 0x2175, 0x0000, // 11
+	0x53FF, 0x4A5F, 0x7E52, 0x0000, // 12
+	0x4FFF, 0x7ED2, 0x3A4C, 0x1CE0, // 13
+	0x03ED, 0x7FFF, 0x255F, 0x0000, // 14
+	0x036A, 0x021F, 0x03FF, 0x7FFF, // 15
+	0x7FFF, 0x01DF, 0x0112, 0x0000, // 16
+	0x231F, 0x035F, 0x00F2, 0x0009, // 17
+	0x7FFF, 0x03EA, 0x011F, 0x0000, // 18
+	0x299F, 0x001A, 0x000C, 0x0000, // 19
+	0x7FFF, 0x027F, 0x001F, 0x0000, // 20
+	0x7FFF, 0x03E0, 0x0206, 0x0120, // 21
+	0x7FFF, 0x7EEB, 0x001F, 0x7C00, // 22
+	0x7FFF, 0x3FFF, 0x7E00, 0x001F, // 23
+	0x7FFF, 0x03FF, 0x001F, 0x0000, // 24
+	0x03FF, 0x001F, 0x000C, 0x0000, // 25
+	0x7FFF, 0x033F, 0x0193, 0x0000, // 26
+	0x0000, 0x4200, 0x037F, 0x7FFF, // 27
+	0x7FFF, 0x7E8C, 0x7C00, 0x0000, // 28
+	0x7FFF, 0x1BEF, 0x6180, 0x0000, // 29
+}
+
+// compatCombos lists where the OBJ0, OBJ1 and BG palettes of each palette
+// combination start in compatColors. A few entries in the boot ROM start
+// mid-palette and run into the next one.
+var compatCombos = [...][3]int{
+	{4 * 4, 4 * 4, 29 * 4}, // 0, the default
+	{18 * 4, 18 * 4, 18 * 4},
+	{20 * 4, 20 * 4, 20 * 4},
+	{24 * 4, 24 * 4, 24 * 4},
+	{9 * 4, 9 * 4, 9 * 4},
+	{0 * 4, 0 * 4, 0 * 4}, // 5
+	{27 * 4, 27 * 4, 27 * 4},
+	{5 * 4, 5 * 4, 5 * 4},
+	{12 * 4, 12 * 4, 12 * 4},
+	{26 * 4, 26 * 4, 26 * 4},
+	{16 * 4, 8 * 4, 8 * 4}, // 10
+	{4 * 4, 28 * 4, 28 * 4},
+	{4 * 4, 2 * 4, 2 * 4},
+	{3 * 4, 4 * 4, 4 * 4},
+	{4 * 4, 29 * 4, 29 * 4},
+	{28 * 4, 4 * 4, 28 * 4}, // 15
+	{2 * 4, 17 * 4, 2 * 4},
+	{16 * 4, 16 * 4, 8 * 4},
+	{4 * 4, 4 * 4, 7 * 4},
+	{4 * 4, 4 * 4, 18 * 4},
+	{4 * 4, 4 * 4, 20 * 4}, // 20
+	{19 * 4, 19 * 4, 9 * 4},
+	{4*4 - 1, 4*4 - 1, 11 * 4},
+	{17 * 4, 17 * 4, 2 * 4},
+	{4 * 4, 4 * 4, 2 * 4},
+	{4 * 4, 4 * 4, 3 * 4}, // 25
+	{28 * 4, 28 * 4, 0 * 4},
+	{3 * 4, 3 * 4, 0 * 4},
+	{0 * 4, 0 * 4, 1 * 4},
+	{18 * 4, 22 * 4, 18 * 4},
+	{20 * 4, 22 * 4, 20 * 4}, // 30
+	{24 * 4, 22 * 4, 24 * 4},
+	{16 * 4, 22 * 4, 8 * 4},
+	{17 * 4, 4 * 4, 13 * 4},
+	{28*4 - 1, 0 * 4, 14 * 4},
+	{28*4 - 1, 4 * 4, 15 * 4}, // 35
+	{19 * 4, 22 * 4, 9 * 4},
+	{16 * 4, 28 * 4, 10 * 4},
+	{4 * 4, 23 * 4, 28 * 4},
+	{17 * 4, 22 * 4, 2 * 4},
+	{4 * 4, 0 * 4, 2 * 4}, // 40
+	{4 * 4, 28 * 4, 3 * 4},
+	{28 * 4, 3 * 4, 0 * 4},
+	{3 * 4, 28 * 4, 4 * 4},
+	{21 * 4, 28 * 4, 4 * 4},
+	{3 * 4, 28 * 4, 0 * 4}, // 45
+	{25 * 4, 3 * 4, 28 * 4},
+	{0 * 4, 28 * 4, 8 * 4},
+	{4 * 4, 3 * 4, 28 * 4},
+	{28 * 4, 3 * 4, 6 * 4},
+	{4 * 4, 28 * 4, 29 * 4}, // 50
+}
+
+// compatTitles maps the title checksums the boot ROM knows to a palette
+// combination.
+var compatTitles = map[uint8]uint8{
+	0x01: 42, // DEFENDER/JOUST
+	0x0C: 5,  // MANSELL
+	0x10: 42, // SUPER RC PRO-AM
+	0x14: 13, // POKEMON RED
+	0x15: 3,
+	0x16: 5,  // YAKUMAN
+	0x17: 41, // OTHELLO
+	0x19: 20, // DONKEY KONG
+	0x1D: 21,
+	0x29: 42, // MEGAMAN3
+	0x34: 18, // GAMEBOY GALLERY
+	0x35: 5,  // MARIO'S PICROSS
+	0x36: 35, // BASEBALL
+	0x39: 26, // DYNABLASTER
+	0x3C: 15, // DR.MARIO
+	0x3D: 19, // YOSSY NO TAMAGO
+	0x3E: 30, // YOSSY NO COOKIE
+	0x3F: 0,  // TETRIS PLUS
+	0x43: 26,
+	0x49: 36,
+	0x4B: 25, // DMG FOOTBALL
+	0x4E: 38, // WAVERACE
+	0x52: 42, // STREET FIGHTER 2
+	0x58: 7,  // X
+	0x59: 32,
+	0x5C: 36,
+	0x5D: 42, // BA.TOSHINDEN
+	0x67: 5,
+	0x68: 42, // LOLO2
+	0x69: 31, // TETRIS FLASH
+	0x6B: 39, // DONKEYKONGLAND 3
+	0x6D: 42, // NETTOU KOF 95
+	0x6F: 9,  // POCKETCAMERA
+	0x70: 44, // ZELDA
+	0x71: 14, // TETRIS BLAST
+	0x75: 5,  // PICROSS 2
+	0x86: 33, // DONKEYKONGLAND95
+	0x88: 4,  // ALLEY WAY
+	0x8B: 41, // MYSTIC QUEST
+	0x8C: 10, // RADARMISSION
+	0x90: 25, // WORLD CUP
+	0x92: 5,  // F1RACE
+	0x95: 29, // YOSSY NO PANEPON
+	0x97: 26, // KINGOFTHEZOO
+	0x99: 5,  // KIRAKIRA KIDS
+	0x9A: 25, // ASTEROIDS/MISCMD
+	0x9C: 16, // PINOCCHIO
+	0x9D: 40, // KILLERINSTINCT95
+	0xA2: 45, // STAR WARS-NOA
+	0xA8: 33,
+	0xAA: 14, // POKEMON GREEN
+	0xB7: 5,  // GAME&WATCH
+	0xBD: 25,
+	0xC9: 37, // MARIOLAND2
+	0xCE: 34, // TOPRANKINGTENNIS
+	0xD1: 34, // TENNIS
+	0xDB: 3,  // TETRIS
+	0xE0: 30, // YOSHI'S COOKIE
+	0xE8: 6,  // SPACE INVADERS
+	0xF0: 34,
+	0xF2: 31, // QIX
+	0xF6: 42, // MEGAMAN
+	0xF7: 45, // BOY AND BLOB GB2
+	0xFF: 2,  // BALLOON KID
+}
+
+// compatTitle is a title checksum shared by several titles, told apart by
+// the title's fourth letter.
+type compatTitle struct {
+	checksum uint8
+	letter   uint8
+}
+
+// compatDuplicates maps the shared checksums to a palette combination. A
+// title with one of these checksums and an unlisted letter gets the default.
+var compatDuplicates = map[compatTitle]uint8{
+	{0x0D, 'E'}: 23, // POKEBOM
+	{0x0D, 'R'}: 31, // TETRIS2
+	{0x18, 'I'}: 0,
+	{0x18, 'K'}: 39, // DONKEYKONGLAND
+	{0x27, 'B'}: 36,
+	{0x27, 'N'}: 41, // MAGNETIC SOCCER
+	{0x28, 'A'}: 6,  // GALAXIAN
+	{0x28, 'F'}: 25, // GOLF
+	{0x46, 'E'}: 22, // SUPER MARIOLAND
+	{0x46, 'R'}: 46,
+	{0x61, 'A'}: 41, // VEGAS STAKES
+	{0x61, 'E'}: 11, // POKEMON BLUE
+	{0x66, 'E'}: 18, // GAMEBOY GALLERY2
+	{0x66, 'L'}: 0,  // MILLI/CENTI/PEDE
+	{0x6A, 'I'}: 19, // MARIO & YOSHI
+	{0x6A, 'K'}: 39, // DONKEYKONGLAND 2
+	{0xA5, 'A'}: 6,  // SOLARSTRIKER
+	{0xA5, 'R'}: 27, // BT2RAGNAROKWORLD
+	{0xB3, 'B'}: 36,
+	{0xB3, 'R'}: 29, // TETRIS ATTACK
+	{0xB3, 'U'}: 17, // MOGURANYA
+	{0xBF, ' '}: 24, // KID ICARUS
+	{0xBF, 'C'}: 34, // SOCCER
+	{0xC6, ' '}: 0,  // KEN GRIFFEY JR
+	{0xC6, 'A'}: 32, // GBWARS
+	{0xD3, 'I'}: 47,
+	{0xD3, 'R'}: 12, // KAERUNOTAMENI
+	{0xF4, ' '}: 18, // G&W GALLERY
+	{0xF4, '-'}: 50,
+}
+
+// compatPaletteFor picks the palettes the CGB boot ROM gives a DMG
+// cartridge. Only Nintendo's own titles are looked up, by the sum of the 16
+// title bytes; everything else gets the default combination.
+//
+// Reference: Pan Docs - Power Up Sequence, Compatibility palettes
+// https://gbdev.io/pandocs/Power_Up_Sequence.html#compatibility-palettes
+func compatPaletteFor(cart *Cartridge) compatPalette {
+	combo := uint8(0)
+
+	if cart.OldLicenseeCode == compatLicenseeNintendo ||
+		cart.OldLicenseeCode == oldLicenseeNew && cart.NewLicenseeCode == compatNewLicenseeNintendo {
+		title := cart.rom[headerTitleStart:headerTitleEnd]
+		checksum := uint8(0)
+
+		for _, c := range title {
+			checksum += c
+		}
+
+		var ok bool
+
+		if combo, ok = compatDuplicates[compatTitle{checksum, title[compatDisambiguation]}]; !ok {
+			combo = compatTitles[checksum]
+		}
+	}
+
+	offsets := compatCombos[combo]
+
+	return compatPalette{
+		obj0: [4]uint16(compatColors[offsets[0]:]),
+		obj1: [4]uint16(compatColors[offsets[1]:]),
+		bg:   [4]uint16(compatColors[offsets[2]:]),
+	}
+}
@@ -0,0 +1,85 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// COMPATIBILITY PALETTES
+// =============================================================================
+//
+// For a DMG cartridge licensed by Nintendo, the CGB boot ROM sums the 16
+// title bytes and looks the sum up in a table of palette combinations,
+// checking the fourth letter of the title when several titles share a sum.
+// Anything it does not find gets the default combination.
+//
+// Reference: Pan Docs - Power Up Sequence, Compatibility palettes
+// https://gbdev.io/pandocs/Power_Up_Sequence.html#compatibility-palettes
+
+// newCompatTestROM returns a DMG cartridge with the given title and
+// licensee codes.
+func newCompatTestROM(title string, oldLicensee uint8, newLicensee string) []uint8 {
+	rom := newTestROM(cartROMOnly, 0x00, 0x00)
+	copy(rom[headerTitleStart:headerTitleEnd], make([]uint8, headerTitleEnd-headerTitleStart))
+	copy(rom[headerTitleStart:], title)
+	copy(rom[headerNewLicenseeStart:], newLicensee)
+	rom[headerOldLicensee] = oldLicensee
+	fixTestROMChecksums(rom)
+
+	return rom
+}
+
+func TestCompatPalette_Lookup(t *testing.T) {
+	var (
+		green    = [4]uint16{0x7FFF, 0x1BEF, 0x0200, 0x0000}
+		red      = [4]uint16{0x7FFF, 0x421F, 0x1CF2, 0x0000}
+		blue     = [4]uint16{0x7FFF, 0x7E8C, 0x7C00, 0x0000}
+		standard = compatPalette{bg: [4]uint16{0x7FFF, 0x1BEF, 0x6180, 0x0000}, obj0: red, obj1: red}
+	)
+
+	testCases := []struct {
+		name        string
+		title       string
+		oldLicensee uint8
+		newLicensee string
+		expected    compatPalette
+	}{
+		{"new licensee code", "POKEMON RED", oldLicenseeNew, "01", compatPalette{bg: red, obj0: green, obj1: red}},
+		{"old licensee code", "POKEMON RED", compatLicenseeNintendo, "", compatPalette{bg: red, obj0: green, obj1: red}},
+		{"shared checksum", "POKEMON BLUE", compatLicenseeNintendo, "", compatPalette{bg: blue, obj0: red, obj1: blue}},
+		{"shared checksum, unlisted letter", "POKXMON BLBE", compatLicenseeNintendo, "", standard},
+		{
+			"combination starting mid-palette", "SUPER MARIOLAND", compatLicenseeNintendo, "",
+			compatPalette{
+				bg:   [4]uint16{0x7ED6, 0x4BFF, 0x2175, 0x0000},
+				obj0: [4]uint16{0x0000, 0x7FFF, 0x421F, 0x1CF2},
+				obj1: [4]uint16{0x0000, 0x7FFF, 0x421F, 0x1CF2},
+			},
+		},
+		{"unknown title", "TESTROM", compatLicenseeNintendo, "", standard},
+		{"other licensee", "POKEMON RED", 0x00, "", standard},
+		{"other new licensee", "POKEMON RED", oldLicenseeNew, "08", standard},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cart, err := ParseCartridge(newCompatTestROM(tc.title, tc.oldLicensee, tc.newLicensee))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, compatPaletteFor(cart))
+		})
+	}
+}
+
+func TestCompatPalette_LoadedAtPowerOn(t *testing.T) {
+	g, err := New(newCompatTestROM("POKEMON RED", oldLicenseeNew, "01"), WithModel(ModelCGB))
+	require.NoError(t, err)
+
+	p := g.bus.ppu
+	require.Equal(t, color.RGBA{0xFF, 0x84, 0x84, 0xFF}, p.bgPalettes.color(0, 1), "red background")
+	require.Equal(t, color.RGBA{0x7B, 0xFF, 0x31, 0xFF}, p.objPalettes.color(0, 1), "green OBJ0")
+	require.Equal(t, color.RGBA{0xFF, 0x84, 0x84, 0xFF}, p.objPalettes.color(1, 1), "red OBJ1")
+}
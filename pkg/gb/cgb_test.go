@@ -0,0 +1,314 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newCGBTestBus returns the bus of a CGB that has skipped its boot ROM with
+// no cartridge, which leaves it in CGB mode.
+func newCGBTestBus(t *testing.T, mode RenderMode) *bus {
+	t.Helper()
+
+	c, err := newCPUForModel(ModelCGB, nil)
+	require.NoError(t, err)
+
+	c.bus.attachPPU(mode)
+	require.True(t, c.bus.cgbMode)
+
+	return c.bus
+}
+
+// fillPalettes writes a distinct byte to every entry of both palette RAMs
+// through the auto-incrementing data registers.
+func fillPalettes(b *bus) {
+	b.Write(regBCPS, paletteAutoInc)
+	b.Write(regOCPS, paletteAutoInc)
+
+	for i := range paletteRAMSize {
+		b.Write(regBCPD, uint8(i*7+1))
+		b.Write(regOCPD, uint8(i*5+3))
+	}
+}
+
+// =============================================================================
+// MEMORY BANKS
+// =============================================================================
+//
+// VBK (0xFF4F) selects which of two 8 KiB VRAM banks appears at 0x8000;
+// SVBK (0xFF70) selects which of banks 1-7 appears at 0xD000, with 0
+// selecting 1. Both are fixed outside CGB mode.
+//
+// Reference: Pan Docs - CGB Registers
+// https://gbdev.io/pandocs/CGB_Registers.html
+
+func TestCGB_VRAMBanks(t *testing.T) {
+	b := newCGBTestBus(t, RenderScanline)
+
+	b.Write(0x8000, 0x11)
+	b.Write(regVBK, 0x01)
+	b.Write(0x8000, 0x22)
+
+	require.Equal(t, uint8(0xFF), readReg(b, regVBK))
+	require.Equal(t, uint8(0x22), readReg(b, 0x8000))
+
+	b.Write(regVBK, 0x00)
+	require.Equal(t, uint8(0xFE), readReg(b, regVBK))
+	require.Equal(t, uint8(0x11), readReg(b, 0x8000))
+}
+
+func TestCGB_WRAMBanks(t *testing.T) {
+	b := newCGBTestBus(t, RenderScanline)
+
+	for bank := range uint8(cgbWRAMBanks) {
+		b.Write(regSVBK, bank)
+		b.Write(0xD000, 0xA0+bank)
+	}
+
+	testCases := []struct {
+		svbk uint8
+		want uint8
+	}{
+		{0, 0xA1}, // selecting bank 0 maps bank 1, which the loop wrote twice
+		{1, 0xA1},
+		{2, 0xA2},
+		{7, 0xA7},
+		{0xFA, 0xA2}, // only bits 0-2 count
+	}
+
+	for _, tc := range testCases {
+		b.Write(regSVBK, tc.svbk)
+		require.Equal(t, tc.want, readReg(b, 0xD000), "SVBK=%02X", tc.svbk)
+		require.Equal(t, tc.want, readReg(b, 0xF000), "echo RAM follows the bank")
+	}
+
+	b.Write(0xC000, 0x55)
+	b.Write(regSVBK, 3)
+	require.Equal(t, uint8(0x55), readReg(b, 0xC000), "bank 0 is fixed")
+	require.Equal(t, uint8(0xFB), readReg(b, regSVBK))
+}
+
+func TestCGB_BanksFixedOutsideCGBMode(t *testing.T) {
+	testCases := []struct {
+		name  string
+		model Model
+		cgb   bool // cartridge header CGB flag
+	}{
+		{"DMG", ModelDMG, true},
+		{"CGB running a DMG cartridge", ModelCGB, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := newCPUForModel(tc.model, nil)
+			require.NoError(t, err)
+
+			rom := newTestROM(0x00, 0x00, 0x00)
+
+			if tc.cgb {
+				rom[headerCGBFlag] = cgbFlagSupported
+				fixTestROMChecksums(rom)
+			}
+
+			require.NoError(t, c.bus.LoadROM(rom))
+			c.reset()
+
+			b := c.bus
+			require.False(t, b.cgbMode)
+
+			b.Write(0x8000, 0x11)
+			b.Write(0xD000, 0x33)
+			b.Write(regVBK, 0x01)
+			b.Write(regSVBK, 0x02)
+
+			require.Equal(t, uint8(0x11), readReg(b, 0x8000))
+			require.Equal(t, uint8(0x33), readReg(b, 0xD000))
+		})
+	}
+}
+
+// =============================================================================
+// PALETTES
+// =============================================================================
+//
+// Each of BG and OBJ has 64 bytes of palette RAM: eight palettes of four
+// little-endian RGB555 colors. BCPS/OCPS hold the index (bit 7 enables
+// auto-increment after data writes), BCPD/OCPD the data.
+//
+// Reference: Pan Docs - LCD Color Palettes (CGB only)
+// https://gbdev.io/pandocs/Palettes.html#lcd-color-palettes-cgb-only
+
+func TestCGB_PaletteAutoIncrement(t *testing.T) {
+	b := newCGBTestBus(t, RenderScanline)
+
+	b.Write(regBCPS, paletteAutoInc|0x3E)
+	b.Write(regBCPD, 0x12)
+	b.Write(regBCPD, 0x34)
+	b.Write(regBCPD, 0x56)
+
+	require.Equal(t, uint8(paletteAutoInc|0x41), readReg(b, regBCPS), "index wraps within 0-63")
+
+	b.Write(regBCPS, 0x3E)
+	require.Equal(t, uint8(0x12), readReg(b, regBCPD))
+	require.Equal(t, uint8(0x12), readReg(b, regBCPD), "reads never increment")
+	require.Equal(t, uint8(0x56), b.ppu.bgPalettes.data[0])
+
+	// Without bit 7 every write lands on the same byte
+	b.Write(regOCPS, 0x08)
+	b.Write(regOCPD, 0x1F)
+	b.Write(regOCPD, 0x7C)
+	require.Equal(t, uint8(0x48), readReg(b, regOCPS))
+	require.Equal(t, uint8(0x7C), readReg(b, regOCPD))
+}
+
+func TestCGB_PaletteColor(t *testing.T) {
+	var r paletteRAM
+
+	r.set(2, [4]uint16{0x7FFF, 0x001F, 0x03E0, 0x7C00})
+
+	require.Equal(t, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, r.color(2, 0))
+	require.Equal(t, color.RGBA{0xFF, 0x00, 0x00, 0xFF}, r.color(2, 1))
+	require.Equal(t, color.RGBA{0x00, 0xFF, 0x00, 0xFF}, r.color(2, 2))
+	require.Equal(t, color.RGBA{0x00, 0x00, 0xFF, 0xFF}, r.color(2, 3))
+}
+
+func TestCGB_CompatibilityPalettes(t *testing.T) {
+	c, err := newCPUForModel(ModelCGB, nil)
+	require.NoError(t, err)
+	require.NoError(t, c.bus.LoadROM(newTestROM(0x00, 0x00, 0x00)))
+	c.reset()
+
+	b := c.bus
+	b.Write(regBGP, 0xE4)
+	b.Write(regOBP1, 0xE4)
+	b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcOBJEnable|lcdcTileData)
+	writeTile(b, 0x8000, 1)
+	writeTile(b, 0x8010, 3)
+	writeSprite(b, 0, 8, 0, 0x01, attrPalette)
+
+	b.ppu.Tick(CyclesPerFrame)
+
+	require.Equal(t, color.RGBA{0x7B, 0xFF, 0x31, 0xFF}, pixelAt(b, 0, 0), "BGP shade 1 through BG palette 0")
+	require.Equal(t, color.RGBA{0x00, 0x00, 0x00, 0xFF}, pixelAt(b, 8, 0), "OBP1 shade 3 through OBJ palette 1")
+	require.Equal(t, uint8(opriCoordinates), b.ppu.opri, "DMG sprite priority")
+}
+
+// =============================================================================
+// CGB RENDERING
+// =============================================================================
+//
+// VRAM bank 1 holds an attribute byte behind each tile map entry: palette
+// (bits 0-2), tile bank (bit 3), X flip (bit 5), Y flip (bit 6) and BG
+// priority (bit 7). Sprites take their palette from bits 0-2 and their tile
+// bank from bit 3. Overlapping sprites are ranked by OAM index, and LCDC
+// bit 0 no longer blanks the background but makes sprites always win.
+//
+// Reference: Pan Docs - VRAM Tile Maps, BG Map Attributes
+// https://gbdev.io/pandocs/Tile_Maps.html#bg-map-attributes-cgb-mode-only
+
+func TestCGB_BGAttributes(t *testing.T) {
+	for _, mode := range []RenderMode{RenderScanline, RenderFIFO} {
+		b := newCGBTestBus(t, mode)
+		fillPalettes(b)
+		p := b.ppu
+
+		b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcOBJEnable|lcdcTileData)
+
+		// Tile 1 is color 1 in bank 0 and color 2 in bank 1; tile 2 only has
+		// its top-left pixel set
+		writeTile(b, 0x8010, 1)
+		b.Write(0x8020, 0x80)
+		b.Write(0x8021, 0x80)
+		b.Write(regVBK, 1)
+		writeTile(b, 0x8010, 2)
+
+		// Tile map attributes, in bank 1 behind each map entry
+		b.Write(tileMapLow+0, bgAttrBank|0x03)
+		b.Write(tileMapLow+1, bgAttrFlipX)
+		b.Write(tileMapLow+2, bgAttrFlipY)
+		b.Write(regVBK, 0)
+		b.Write(tileMapLow+0, 0x01)
+		b.Write(tileMapLow+1, 0x02)
+		b.Write(tileMapLow+2, 0x02)
+
+		b.ppu.Tick(CyclesPerFrame)
+
+		require.Equal(t, p.bgPalettes.color(3, 2), pixelAt(b, 0, 0), "bank 1 tile, palette 3")
+		require.Equal(t, p.bgPalettes.color(0, 0), pixelAt(b, 8, 0))
+		require.Equal(t, p.bgPalettes.color(0, 3), pixelAt(b, 15, 0), "X flip")
+		require.Equal(t, p.bgPalettes.color(0, 0), pixelAt(b, 16, 0))
+		require.Equal(t, p.bgPalettes.color(0, 3), pixelAt(b, 16, 7), "Y flip")
+	}
+}
+
+func TestCGB_SpritePriority(t *testing.T) {
+	for _, mode := range []RenderMode{RenderScanline, RenderFIFO} {
+		b := newCGBTestBus(t, mode)
+		fillPalettes(b)
+		p := b.ppu
+
+		writeTile(b, 0x8010, 1)
+		writeTile(b, 0x8020, 3)
+		b.Write(regVBK, 1)
+		writeTile(b, 0x8020, 2)
+
+		// BG color 1 everywhere on the first two map rows; the tile at
+		// column 4 has the BG priority attribute
+		b.Write(tileMapLow+4, bgAttrPriority)
+		b.Write(regVBK, 0)
+
+		for i := range uint16(tileMapWidth * 2) {
+			b.Write(tileMapLow+i, 0x01)
+		}
+
+		// Sprite 0 overlaps sprite 1, which has the smaller X: OAM order wins
+		writeSprite(b, 0, 8, 0, 0x02, 0x05)
+		writeSprite(b, 1, 4, 0, 0x02, attrBank|0x06)
+		writeSprite(b, 2, 32, 0, 0x02, 0x01)
+		writeSprite(b, 3, 48, 0, 0x02, attrBehindBG)
+
+		b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcOBJEnable|lcdcTileData)
+		b.ppu.Tick(CyclesPerFrame)
+
+		require.Equal(t, p.objPalettes.color(6, 2), pixelAt(b, 4, 0), "bank 1 tile, palette 6")
+		require.Equal(t, p.objPalettes.color(5, 3), pixelAt(b, 9, 0), "lower OAM index wins")
+		require.Equal(t, p.bgPalettes.color(0, 1), pixelAt(b, 32, 0), "BG priority attribute")
+		require.Equal(t, p.bgPalettes.color(0, 1), pixelAt(b, 48, 0), "sprite behind BG")
+
+		b.Write(regOPRI, opriCoordinates)
+		b.ppu.Tick(CyclesPerFrame)
+		require.Equal(t, p.objPalettes.color(6, 2), pixelAt(b, 9, 0), "OPRI selects the DMG rule")
+
+		b.Write(regLCDC, lcdcEnable|lcdcOBJEnable|lcdcTileData)
+		b.ppu.Tick(CyclesPerFrame)
+		require.Equal(t, p.bgPalettes.color(0, 1), pixelAt(b, 16, 0), "BG stays on without LCDC bit 0")
+		require.Equal(t, p.objPalettes.color(1, 3), pixelAt(b, 32, 0), "sprites win without LCDC bit 0")
+		require.Equal(t, p.objPalettes.color(0, 3), pixelAt(b, 48, 0))
+	}
+}
+
+// =============================================================================
+// MODE SELECTION
+// =============================================================================
+//
+// The CGB boot ROM stays in CGB mode for cartridges with the header's CGB
+// flag set, and otherwise writes KEY0 (0xFF4C) to switch to DMG
+// compatibility mode before unmapping itself.
+
+func TestCGB_KEY0SelectsCompatibilityMode(t *testing.T) {
+	c, err := newCPUForModel(ModelCGB, testBootROM(ModelCGB))
+	require.NoError(t, err)
+
+	b := c.bus
+	require.True(t, b.cgbMode)
+
+	b.Write(regKEY0, key0DMGCompat)
+	require.False(t, b.cgbMode)
+
+	b.Write(regBOOT, 0x01)
+	b.Write(regKEY0, 0x00)
+	require.False(t, b.cgbMode, "KEY0 is locked once the boot ROM is unmapped")
+}
@@ -2,35 +2,172 @@ package gb
 
 import "fmt"
 
-// Initial CPU register values after boot ROM (DMG).
+// Initial stack pointer and entry point after the boot ROM. The other
+// registers depend on the model, see postBoot.
 const (
-	initA  uint8  = 0x01
-	initF  uint8  = 0xB0
-	initB  uint8  = 0x00
-	initC  uint8  = 0x13
-	initD  uint8  = 0x00
-	initE  uint8  = 0xD8
-	initH  uint8  = 0x01
-	initL  uint8  = 0x4D
 	initSP uint16 = 0xFFFE
 	initPC uint16 = 0x0100
 )
 
 const (
-	lowByteMask    = 0xFF // Mask for extracting the low byte of a 16-bit value
-	flagMask       = 0xF0 // F register lower 4 bits are always 0
-	flagZ          = 0x80 // Zero flag (bit 7)
-	flagN          = 0x40 // Subtract flag (bit 6)
-	flagH          = 0x20 // Half Carry flag (bit 5)
-	flagC          = 0x10 // Carry flag (bit 4)
-	opCode_NOP     = 0x00
-	opCode_LD_B_nr = 0x06
-	opCode_LD_C_nr = 0x0E
-	opCode_LD_D_nr = 0x16
-	opCode_LD_E_nr = 0x1E
-	opCode_LD_H_nr = 0x26
-	opCode_LD_L_nr = 0x2E
-	opCode_LD_A_nr = 0x3E
+	lowByteMask = 0xFF // Mask for extracting the low byte of a 16-bit value
+	flagMask    = 0xF0 // F register lower 4 bits are always 0
+	flagZ       = 0x80 // Zero flag (bit 7)
+	flagN       = 0x40 // Subtract flag (bit 6)
+	flagH       = 0x20 // Half Carry flag (bit 5)
+	flagC       = 0x10 // Carry flag (bit 4)
+)
+
+// Operand indexes used by the regular opcode blocks. Bits 0-2 (source) and
+// bits 3-5 (destination) of an opcode select one of these.
+const (
+	regB   = 0x00
+	regC   = 0x01
+	regD   = 0x02
+	regE   = 0x03
+	regH   = 0x04
+	regL   = 0x05
+	regHLm = 0x06 // (HL): the byte in memory pointed to by HL
+	regA   = 0x07
+)
+
+// Unprefixed opcodes. The LD r,r' block (0x40-0x7F) and the ALU A,r block
+// (0x80-0xBF) are decoded from their operand bits instead of being listed.
+const (
+	opCode_NOP         = 0x00
+	opCode_LD_BC_nnr   = 0x01
+	opCode_LD_BCm_A    = 0x02
+	opCode_INC_BC      = 0x03
+	opCode_INC_B       = 0x04
+	opCode_DEC_B       = 0x05
+	opCode_LD_B_nr     = 0x06
+	opCode_RLCA        = 0x07
+	opCode_LD_nnm_SP   = 0x08
+	opCode_ADD_HL_BC   = 0x09
+	opCode_LD_A_BCm    = 0x0A
+	opCode_DEC_BC      = 0x0B
+	opCode_INC_C       = 0x0C
+	opCode_DEC_C       = 0x0D
+	opCode_LD_C_nr     = 0x0E
+	opCode_RRCA        = 0x0F
+	opCode_STOP        = 0x10
+	opCode_LD_DE_nnr   = 0x11
+	opCode_LD_DEm_A    = 0x12
+	opCode_INC_DE      = 0x13
+	opCode_INC_D       = 0x14
+	opCode_DEC_D       = 0x15
+	opCode_LD_D_nr     = 0x16
+	opCode_RLA         = 0x17
+	opCode_JR_er       = 0x18
+	opCode_ADD_HL_DE   = 0x19
+	opCode_LD_A_DEm    = 0x1A
+	opCode_DEC_DE      = 0x1B
+	opCode_INC_E       = 0x1C
+	opCode_DEC_E       = 0x1D
+	opCode_LD_E_nr     = 0x1E
+	opCode_RRA         = 0x1F
+	opCode_JR_NZ_er    = 0x20
+	opCode_LD_HL_nnr   = 0x21
+	opCode_LD_HLIm_A   = 0x22
+	opCode_INC_HL      = 0x23
+	opCode_INC_H       = 0x24
+	opCode_DEC_H       = 0x25
+	opCode_LD_H_nr     = 0x26
+	opCode_DAA         = 0x27
+	opCode_JR_Z_er     = 0x28
+	opCode_ADD_HL_HL   = 0x29
+	opCode_LD_A_HLIm   = 0x2A
+	opCode_DEC_HL      = 0x2B
+	opCode_INC_L       = 0x2C
+	opCode_DEC_L       = 0x2D
+	opCode_LD_L_nr     = 0x2E
+	opCode_CPL         = 0x2F
+	opCode_JR_NC_er    = 0x30
+	opCode_LD_SP_nnr   = 0x31
+	opCode_LD_HLDm_A   = 0x32
+	opCode_INC_SP      = 0x33
+	opCode_INC_HLm     = 0x34
+	opCode_DEC_HLm     = 0x35
+	opCode_LD_HLm_nr   = 0x36
+	opCode_SCF         = 0x37
+	opCode_JR_C_er     = 0x38
+	opCode_ADD_HL_SP   = 0x39
+	opCode_LD_A_HLDm   = 0x3A
+	opCode_DEC_SP      = 0x3B
+	opCode_INC_A       = 0x3C
+	opCode_DEC_A       = 0x3D
+	opCode_LD_A_nr     = 0x3E
+	opCode_CCF         = 0x3F
+	opCode_LD_r_r_lo   = 0x40
+	opCode_HALT        = 0x76
+	opCode_LD_r_r_hi   = 0x7F
+	opCode_ALU_A_r_lo  = 0x80
+	opCode_ALU_A_r_hi  = 0xBF
+	opCode_RET_NZ      = 0xC0
+	opCode_POP_BC      = 0xC1
+	opCode_JP_NZ_nnr   = 0xC2
+	opCode_JP_nnr      = 0xC3
+	opCode_CALL_NZ_nnr = 0xC4
+	opCode_PUSH_BC     = 0xC5
+	opCode_ADD_A_nr    = 0xC6
+	opCode_RST_00      = 0xC7
+	opCode_RET_Z       = 0xC8
+	opCode_RET         = 0xC9
+	opCode_JP_Z_nnr    = 0xCA
+	opCode_PREFIX_CB   = 0xCB
+	opCode_CALL_Z_nnr  = 0xCC
+	opCode_CALL_nnr    = 0xCD
+	opCode_ADC_A_nr    = 0xCE
+	opCode_RST_08      = 0xCF
+	opCode_RET_NC      = 0xD0
+	opCode_POP_DE      = 0xD1
+	opCode_JP_NC_nnr   = 0xD2
+	opCode_CALL_NC_nnr = 0xD4
+	opCode_PUSH_DE     = 0xD5
+	opCode_SUB_nr      = 0xD6
+	opCode_RST_10      = 0xD7
+	opCode_RET_C       = 0xD8
+	opCode_RETI        = 0xD9
+	opCode_JP_C_nnr    = 0xDA
+	opCode_CALL_C_nnr  = 0xDC
+	opCode_SBC_A_nr    = 0xDE
+	opCode_RST_18      = 0xDF
+	opCode_LDH_nm_A    = 0xE0
+	opCode_POP_HL      = 0xE1
+	opCode_LDH_Cm_A    = 0xE2
+	opCode_PUSH_HL     = 0xE5
+	opCode_AND_nr      = 0xE6
+	opCode_RST_20      = 0xE7
+	opCode_ADD_SP_er   = 0xE8
+	opCode_JP_HL       = 0xE9
+	opCode_LD_nnm_A    = 0xEA
+	opCode_XOR_nr      = 0xEE
+	opCode_RST_28      = 0xEF
+	opCode_LDH_A_nm    = 0xF0
+	opCode_POP_AF      = 0xF1
+	opCode_LDH_A_Cm    = 0xF2
+	opCode_DI          = 0xF3
+	opCode_PUSH_AF     = 0xF5
+	opCode_OR_nr       = 0xF6
+	opCode_RST_30      = 0xF7
+	opCode_LD_HL_SP_er = 0xF8
+	opCode_LD_SP_HL    = 0xF9
+	opCode_LD_A_nnm    = 0xFA
+	opCode_EI          = 0xFB
+	opCode_CP_nr       = 0xFE
+	opCode_RST_38      = 0xFF
+)
+
+// Operations of the ALU A,r block, selected by bits 3-5 of the opcode.
+const (
+	aluADD = 0x00
+	aluADC = 0x01
+	aluSUB = 0x02
+	aluSBC = 0x03
+	aluAND = 0x04
+	aluXOR = 0x05
+	aluOR  = 0x06
+	aluCP  = 0x07
 )
 
 type cpu struct {
@@ -46,26 +183,49 @@ type cpu struct {
 	sp uint16 // stack pointer
 	pc uint16 // program counter
 
-	cycles int
+	ime      bool // interrupt master enable
+	imeDelay bool // EI was executed, IME turns on after the next instruction
+
+	halted  bool // HALT: idle until IE & IF is non-zero
+	haltBug bool // HALT with IME=0 and a pending interrupt: next PC increment is skipped
+	stopped bool // STOP: idle until a joypad line goes low
+
+	stopLines uint8 // P1 lines as last seen in STOP, to catch them falling
+
+	doubleSpeed      bool // CGB only: CPU runs at 8 MHz
+	speedSwitchArmed bool // CGB only: KEY1 bit 0, the next STOP switches speed
+
+	cycles int  // T-cycles of the current Step already ticked on the bus
+	speed  bool // CPU speed the current Step started in
 
 	bus *bus
 }
 
+// newCPU returns a DMG that skips the boot ROM.
 func newCPU() *cpu {
-	return &cpu{
-		a:      initA,
-		f:      initF,
-		b:      initB,
-		c:      initC,
-		d:      initD,
-		e:      initE,
-		h:      initH,
-		l:      initL,
-		sp:     initSP,
-		pc:     initPC,
-		cycles: 0,
-		bus:    newBus(),
+	return newCPUOnBus(newBus())
+}
+
+// newCPUForModel returns a CPU on a fresh bus for the given model. It boots
+// from bootROM, or skips the boot sequence when bootROM is nil.
+func newCPUForModel(model Model, bootROM []uint8) (*cpu, error) {
+	b := newBus()
+	b.model = model
+
+	if err := b.SetBootROM(bootROM); err != nil {
+		return nil, err
 	}
+
+	return newCPUOnBus(b), nil
+}
+
+// newCPUOnBus returns a CPU driving an already configured bus, reset into
+// the bus's model and cartridge.
+func newCPUOnBus(b *bus) *cpu {
+	c := &cpu{bus: b}
+	c.reset()
+
+	return c
 }
 
 func (c *cpu) A() uint8 {
@@ -229,17 +389,334 @@ func (c *cpu) setFlag(value bool, flag uint8) {
 }
 
 func (c *cpu) fetch() (uint8, error) {
-	val, err := c.bus.Read(c.PC())
+	val, err := c.read(c.PC())
 
 	if err != nil {
 		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
 	}
 
-	c.SetPC(c.PC() + 0x01)
+	if c.haltBug {
+		c.haltBug = false
+	} else {
+		c.SetPC(c.PC() + 0x01)
+	}
 
 	return val, nil
 }
 
+func (c *cpu) fetch16() (uint16, error) {
+	low, err := c.fetch()
+
+	if err != nil {
+		return 0, err
+	}
+
+	high, err := c.fetch()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint16(high)<<8 | uint16(low), nil
+}
+
+func (c *cpu) push(value uint16) error {
+	c.SetSP(c.SP() - 1)
+
+	if err := c.write(c.SP(), uint8(value>>8)); err != nil {
+		return fmt.Errorf("failed to push to stack: %v", err)
+	}
+
+	c.SetSP(c.SP() - 1)
+
+	if err := c.write(c.SP(), uint8(value&lowByteMask)); err != nil {
+		return fmt.Errorf("failed to push to stack: %v", err)
+	}
+
+	return nil
+}
+
+func (c *cpu) pop() (uint16, error) {
+	low, err := c.read(c.SP())
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to pop from stack: %v", err)
+	}
+
+	c.SetSP(c.SP() + 1)
+
+	high, err := c.read(c.SP())
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to pop from stack: %v", err)
+	}
+
+	c.SetSP(c.SP() + 1)
+
+	return uint16(high)<<8 | uint16(low), nil
+}
+
+// reg8 reads the 8-bit operand selected by index (regB..regA), going through
+// the bus for (HL).
+func (c *cpu) reg8(index uint8) (uint8, error) {
+	switch index {
+	case regB:
+		return c.B(), nil
+	case regC:
+		return c.C(), nil
+	case regD:
+		return c.D(), nil
+	case regE:
+		return c.E(), nil
+	case regH:
+		return c.H(), nil
+	case regL:
+		return c.L(), nil
+	case regHLm:
+		value, err := c.read(c.HL())
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to read (HL): %v", err)
+		}
+
+		return value, nil
+	default:
+		return c.A(), nil
+	}
+}
+
+// setReg8 writes the 8-bit operand selected by index (regB..regA), going
+// through the bus for (HL).
+func (c *cpu) setReg8(index uint8, value uint8) error {
+	switch index {
+	case regB:
+		c.SetB(value)
+	case regC:
+		c.SetC(value)
+	case regD:
+		c.SetD(value)
+	case regE:
+		c.SetE(value)
+	case regH:
+		c.SetH(value)
+	case regL:
+		c.SetL(value)
+	case regHLm:
+		if err := c.write(c.HL(), value); err != nil {
+			return fmt.Errorf("failed to write (HL): %v", err)
+		}
+	default:
+		c.SetA(value)
+	}
+
+	return nil
+}
+
+// reg16 reads the register pair selected by index: BC, DE, HL or SP.
+func (c *cpu) reg16(index uint8) uint16 {
+	switch index {
+	case 0:
+		return c.BC()
+	case 1:
+		return c.DE()
+	case 2:
+		return c.HL()
+	default:
+		return c.SP()
+	}
+}
+
+// setReg16 writes the register pair selected by index: BC, DE, HL or SP.
+func (c *cpu) setReg16(index uint8, value uint16) {
+	switch index {
+	case 0:
+		c.SetBC(value)
+	case 1:
+		c.SetDE(value)
+	case 2:
+		c.SetHL(value)
+	default:
+		c.SetSP(value)
+	}
+}
+
+// condition evaluates the NZ/Z/NC/C condition encoded in bits 3-4 of opCode.
+func (c *cpu) condition(opCode uint8) bool {
+	switch opCode >> 3 & 0x03 {
+	case 0:
+		return !c.FlagZ()
+	case 1:
+		return c.FlagZ()
+	case 2:
+		return !c.FlagC()
+	default:
+		return c.FlagC()
+	}
+}
+
+func (c *cpu) inc8(value uint8) uint8 {
+	result := value + 1
+
+	c.SetFlagZ(result == 0)
+	c.SetFlagN(false)
+	c.SetFlagH(value&0x0F == 0x0F)
+
+	return result
+}
+
+func (c *cpu) dec8(value uint8) uint8 {
+	result := value - 1
+
+	c.SetFlagZ(result == 0)
+	c.SetFlagN(true)
+	c.SetFlagH(value&0x0F == 0x00)
+
+	return result
+}
+
+// alu applies one of the eight aluXXX operations between A and value.
+func (c *cpu) alu(op uint8, value uint8) {
+	a := c.A()
+	carry := uint8(0)
+
+	if (op == aluADC || op == aluSBC) && c.FlagC() {
+		carry = 1
+	}
+
+	switch op {
+	case aluADD, aluADC:
+		result := uint16(a) + uint16(value) + uint16(carry)
+
+		c.SetA(uint8(result))
+		c.SetFlagZ(uint8(result) == 0)
+		c.SetFlagN(false)
+		c.SetFlagH(a&0x0F+value&0x0F+carry > 0x0F)
+		c.SetFlagC(result > 0xFF)
+	case aluSUB, aluSBC, aluCP:
+		result := int(a) - int(value) - int(carry)
+
+		if op != aluCP {
+			c.SetA(uint8(result))
+		}
+
+		c.SetFlagZ(uint8(result) == 0)
+		c.SetFlagN(true)
+		c.SetFlagH(int(a&0x0F)-int(value&0x0F)-int(carry) < 0)
+		c.SetFlagC(result < 0)
+	case aluAND:
+		c.SetA(a & value)
+		c.SetF(flagH)
+		c.SetFlagZ(c.A() == 0)
+	case aluXOR:
+		c.SetA(a ^ value)
+		c.SetF(0)
+		c.SetFlagZ(c.A() == 0)
+	case aluOR:
+		c.SetA(a | value)
+		c.SetF(0)
+		c.SetFlagZ(c.A() == 0)
+	}
+}
+
+func (c *cpu) addHL(value uint16) {
+	hl := c.HL()
+	result := uint32(hl) + uint32(value)
+
+	c.SetHL(uint16(result))
+	c.SetFlagN(false)
+	c.SetFlagH(hl&0x0FFF+value&0x0FFF > 0x0FFF)
+	c.SetFlagC(result > 0xFFFF)
+}
+
+// addSP returns SP plus a signed 8-bit offset and sets the flags shared by
+// ADD SP,e and LD HL,SP+e: carries come from the unsigned low byte.
+func (c *cpu) addSP(offset uint8) uint16 {
+	sp := c.SP()
+	result := sp + uint16(int8(offset))
+
+	c.SetFlagZ(false)
+	c.SetFlagN(false)
+	c.SetFlagH(sp&0x0F+uint16(offset&0x0F) > 0x0F)
+	c.SetFlagC(sp&0xFF+uint16(offset) > 0xFF)
+
+	return result
+}
+
+func (c *cpu) rlc(value uint8) uint8 {
+	result := value<<1 | value>>7
+
+	c.SetF(0)
+	c.SetFlagZ(result == 0)
+	c.SetFlagC(value&0x80 != 0)
+
+	return result
+}
+
+func (c *cpu) rrc(value uint8) uint8 {
+	result := value>>1 | value<<7
+
+	c.SetF(0)
+	c.SetFlagZ(result == 0)
+	c.SetFlagC(value&0x01 != 0)
+
+	return result
+}
+
+func (c *cpu) rl(value uint8) uint8 {
+	result := value << 1
+
+	if c.FlagC() {
+		result |= 0x01
+	}
+
+	c.SetF(0)
+	c.SetFlagZ(result == 0)
+	c.SetFlagC(value&0x80 != 0)
+
+	return result
+}
+
+func (c *cpu) rr(value uint8) uint8 {
+	result := value >> 1
+
+	if c.FlagC() {
+		result |= 0x80
+	}
+
+	c.SetF(0)
+	c.SetFlagZ(result == 0)
+	c.SetFlagC(value&0x01 != 0)
+
+	return result
+}
+
+// daa adjusts A back into packed BCD after an addition or subtraction.
+func (c *cpu) daa() {
+	a := c.A()
+	adjust := uint8(0)
+	carry := c.FlagC()
+
+	if c.FlagH() || (!c.FlagN() && a&0x0F > 0x09) {
+		adjust |= 0x06
+	}
+
+	if carry || (!c.FlagN() && a > 0x99) {
+		adjust |= 0x60
+		carry = true
+	}
+
+	if c.FlagN() {
+		a -= adjust
+	} else {
+		a += adjust
+	}
+
+	c.SetA(a)
+	c.SetFlagZ(a == 0)
+	c.SetFlagH(false)
+	c.SetFlagC(carry)
+}
+
 func (c *cpu) exec_LD_B_nr() (int, error) {
 	immediateValue, err := c.fetch()
 
@@ -324,6 +801,415 @@ func (c *cpu) exec_LD_A_nr() (int, error) {
 	return 8, nil
 }
 
+func (c *cpu) exec_LD_HLm_nr() (int, error) {
+	immediateValue, err := c.fetch()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if err := c.setReg8(regHLm, immediateValue); err != nil {
+		return 0, err
+	}
+
+	return 12, nil
+}
+
+func (c *cpu) exec_LD_r_r(opCode uint8) (int, error) {
+	src := opCode & 0x07
+	dst := opCode >> 3 & 0x07
+
+	value, err := c.reg8(src)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.setReg8(dst, value); err != nil {
+		return 0, err
+	}
+
+	if src == regHLm || dst == regHLm {
+		return 8, nil
+	}
+
+	return 4, nil
+}
+
+func (c *cpu) exec_LD_rr_nnr(opCode uint8) (int, error) {
+	immediateValue, err := c.fetch16()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	c.setReg16(opCode>>4&0x03, immediateValue)
+
+	return 12, nil
+}
+
+func (c *cpu) exec_LD_mem_A(addr uint16) (int, error) {
+	if err := c.write(addr, c.A()); err != nil {
+		return 0, fmt.Errorf("failed to write A to 0x%04X: %v", addr, err)
+	}
+
+	return 8, nil
+}
+
+func (c *cpu) exec_LD_A_mem(addr uint16) (int, error) {
+	value, err := c.read(addr)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read A from 0x%04X: %v", addr, err)
+	}
+
+	c.SetA(value)
+
+	return 8, nil
+}
+
+func (c *cpu) exec_LD_nnm_SP() (int, error) {
+	addr, err := c.fetch16()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if err := c.write(addr, uint8(c.SP()&lowByteMask)); err != nil {
+		return 0, fmt.Errorf("failed to write SP to 0x%04X: %v", addr, err)
+	}
+
+	if err := c.write(addr+1, uint8(c.SP()>>8)); err != nil {
+		return 0, fmt.Errorf("failed to write SP to 0x%04X: %v", addr+1, err)
+	}
+
+	return 20, nil
+}
+
+func (c *cpu) exec_LD_nnm_A() (int, error) {
+	addr, err := c.fetch16()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if _, err := c.exec_LD_mem_A(addr); err != nil {
+		return 0, err
+	}
+
+	return 16, nil
+}
+
+func (c *cpu) exec_LD_A_nnm() (int, error) {
+	addr, err := c.fetch16()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if _, err := c.exec_LD_A_mem(addr); err != nil {
+		return 0, err
+	}
+
+	return 16, nil
+}
+
+func (c *cpu) exec_LDH_nm_A() (int, error) {
+	offset, err := c.fetch()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if _, err := c.exec_LD_mem_A(0xFF00 | uint16(offset)); err != nil {
+		return 0, err
+	}
+
+	return 12, nil
+}
+
+func (c *cpu) exec_LDH_A_nm() (int, error) {
+	offset, err := c.fetch()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if _, err := c.exec_LD_A_mem(0xFF00 | uint16(offset)); err != nil {
+		return 0, err
+	}
+
+	return 12, nil
+}
+
+func (c *cpu) exec_LDH_Cm_A() (int, error) {
+	return c.exec_LD_mem_A(0xFF00 | uint16(c.C()))
+}
+
+func (c *cpu) exec_LDH_A_Cm() (int, error) {
+	return c.exec_LD_A_mem(0xFF00 | uint16(c.C()))
+}
+
+func (c *cpu) exec_LD_HL_SP_er() (int, error) {
+	offset, err := c.fetch()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	c.SetHL(c.addSP(offset))
+
+	return 12, nil
+}
+
+func (c *cpu) exec_ADD_SP_er() (int, error) {
+	offset, err := c.fetch()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	c.SetSP(c.addSP(offset))
+
+	return 16, nil
+}
+
+func (c *cpu) exec_INC_r(opCode uint8) (int, error) {
+	index := opCode >> 3 & 0x07
+
+	value, err := c.reg8(index)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.setReg8(index, c.inc8(value)); err != nil {
+		return 0, err
+	}
+
+	if index == regHLm {
+		return 12, nil
+	}
+
+	return 4, nil
+}
+
+func (c *cpu) exec_DEC_r(opCode uint8) (int, error) {
+	index := opCode >> 3 & 0x07
+
+	value, err := c.reg8(index)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.setReg8(index, c.dec8(value)); err != nil {
+		return 0, err
+	}
+
+	if index == regHLm {
+		return 12, nil
+	}
+
+	return 4, nil
+}
+
+func (c *cpu) exec_ALU_A_r(opCode uint8) (int, error) {
+	src := opCode & 0x07
+
+	value, err := c.reg8(src)
+
+	if err != nil {
+		return 0, err
+	}
+
+	c.alu(opCode>>3&0x07, value)
+
+	if src == regHLm {
+		return 8, nil
+	}
+
+	return 4, nil
+}
+
+func (c *cpu) exec_ALU_A_nr(opCode uint8) (int, error) {
+	immediateValue, err := c.fetch()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	c.alu(opCode>>3&0x07, immediateValue)
+
+	return 8, nil
+}
+
+func (c *cpu) exec_JR_er(taken bool) (int, error) {
+	offset, err := c.fetch()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if !taken {
+		return 8, nil
+	}
+
+	c.SetPC(c.PC() + uint16(int8(offset)))
+
+	return 12, nil
+}
+
+func (c *cpu) exec_JP_nnr(taken bool) (int, error) {
+	addr, err := c.fetch16()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if !taken {
+		return 12, nil
+	}
+
+	c.SetPC(addr)
+
+	return 16, nil
+}
+
+func (c *cpu) exec_CALL_nnr(taken bool) (int, error) {
+	addr, err := c.fetch16()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if !taken {
+		return 12, nil
+	}
+
+	if err := c.push(c.PC()); err != nil {
+		return 0, err
+	}
+
+	c.SetPC(addr)
+
+	return 24, nil
+}
+
+func (c *cpu) exec_RET() (int, error) {
+	addr, err := c.pop()
+
+	if err != nil {
+		return 0, err
+	}
+
+	c.SetPC(addr)
+
+	return 16, nil
+}
+
+func (c *cpu) exec_RET_cc(opCode uint8) (int, error) {
+	if !c.condition(opCode) {
+		return 8, nil
+	}
+
+	if _, err := c.exec_RET(); err != nil {
+		return 0, err
+	}
+
+	return 20, nil
+}
+
+func (c *cpu) exec_RST(opCode uint8) (int, error) {
+	if err := c.push(c.PC()); err != nil {
+		return 0, err
+	}
+
+	c.SetPC(uint16(opCode & 0x38))
+
+	return 16, nil
+}
+
+func (c *cpu) exec_PUSH_rr(opCode uint8) (int, error) {
+	index := opCode >> 4 & 0x03
+	value := c.reg16(index)
+
+	if index == 3 {
+		value = c.AF()
+	}
+
+	if err := c.push(value); err != nil {
+		return 0, err
+	}
+
+	return 16, nil
+}
+
+func (c *cpu) exec_POP_rr(opCode uint8) (int, error) {
+	index := opCode >> 4 & 0x03
+
+	value, err := c.pop()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if index == 3 {
+		c.SetAF(value)
+	} else {
+		c.setReg16(index, value)
+	}
+
+	return 12, nil
+}
+
+// exec_HALT puts the CPU to sleep until an interrupt is pending. When IME is
+// off and an interrupt is already pending the CPU does not halt at all;
+// instead the byte after HALT is read twice (the "HALT bug").
+func (c *cpu) exec_HALT() (int, error) {
+	if !c.ime && c.bus.interrupts.pending() != 0 {
+		c.haltBug = true
+
+		return 4, nil
+	}
+
+	c.halted = true
+
+	return 4, nil
+}
+
+// exec_STOP either performs an armed CGB speed switch or enters STOP mode,
+// which only joypad input ends. STOP is followed by a padding byte. The
+// speed switch resets DIV and keeps the CPU idle for 2050 M-cycles, with
+// the system counter held at zero until the clock settles; STOP mode holds
+// the system counter too, so DIV and TIMA freeze until it ends.
+func (c *cpu) exec_STOP() (int, error) {
+	if _, err := c.fetch(); err != nil {
+		return 0, fmt.Errorf("failed to read immediate value at PC+1: %v", err)
+	}
+
+	if c.speedSwitchArmed {
+		c.speedSwitchArmed = false
+		c.doubleSpeed = !c.doubleSpeed
+		c.bus.timer.Write(regDIV, 0)
+
+		// Idle out the rest of the switch here, while the timer is held
+		cycles := 4 + speedSwitchMCycles*4
+		c.bus.timer.stopped = true
+		c.bus.tick(cycles-c.cycles, c.speed)
+		c.bus.timer.stopped = false
+		c.cycles = cycles
+
+		return cycles, nil
+	}
+
+	c.stopped = true
+	c.stopLines = c.bus.joypad.lines()
+	c.bus.timer.stopped = true
+
+	return 4, nil
+}
+
 func (c *cpu) exec(opCode uint8) (int, error) {
 	switch opCode {
 	case opCode_NOP:
@@ -342,8 +1228,178 @@ func (c *cpu) exec(opCode uint8) (int, error) {
 		return c.exec_LD_L_nr()
 	case opCode_LD_A_nr:
 		return c.exec_LD_A_nr()
+	case opCode_LD_HLm_nr:
+		return c.exec_LD_HLm_nr()
+	case opCode_LD_BC_nnr, opCode_LD_DE_nnr, opCode_LD_HL_nnr, opCode_LD_SP_nnr:
+		return c.exec_LD_rr_nnr(opCode)
+	case opCode_LD_BCm_A:
+		return c.exec_LD_mem_A(c.BC())
+	case opCode_LD_DEm_A:
+		return c.exec_LD_mem_A(c.DE())
+	case opCode_LD_HLIm_A:
+		hl := c.HL()
+		c.SetHL(hl + 1)
+
+		return c.exec_LD_mem_A(hl)
+	case opCode_LD_HLDm_A:
+		hl := c.HL()
+		c.SetHL(hl - 1)
+
+		return c.exec_LD_mem_A(hl)
+	case opCode_LD_A_BCm:
+		return c.exec_LD_A_mem(c.BC())
+	case opCode_LD_A_DEm:
+		return c.exec_LD_A_mem(c.DE())
+	case opCode_LD_A_HLIm:
+		hl := c.HL()
+		c.SetHL(hl + 1)
+
+		return c.exec_LD_A_mem(hl)
+	case opCode_LD_A_HLDm:
+		hl := c.HL()
+		c.SetHL(hl - 1)
+
+		return c.exec_LD_A_mem(hl)
+	case opCode_LD_nnm_SP:
+		return c.exec_LD_nnm_SP()
+	case opCode_LD_nnm_A:
+		return c.exec_LD_nnm_A()
+	case opCode_LD_A_nnm:
+		return c.exec_LD_A_nnm()
+	case opCode_LDH_nm_A:
+		return c.exec_LDH_nm_A()
+	case opCode_LDH_A_nm:
+		return c.exec_LDH_A_nm()
+	case opCode_LDH_Cm_A:
+		return c.exec_LDH_Cm_A()
+	case opCode_LDH_A_Cm:
+		return c.exec_LDH_A_Cm()
+	case opCode_LD_SP_HL:
+		c.SetSP(c.HL())
+
+		return 8, nil
+	case opCode_LD_HL_SP_er:
+		return c.exec_LD_HL_SP_er()
+	case opCode_INC_B, opCode_INC_C, opCode_INC_D, opCode_INC_E,
+		opCode_INC_H, opCode_INC_L, opCode_INC_HLm, opCode_INC_A:
+		return c.exec_INC_r(opCode)
+	case opCode_DEC_B, opCode_DEC_C, opCode_DEC_D, opCode_DEC_E,
+		opCode_DEC_H, opCode_DEC_L, opCode_DEC_HLm, opCode_DEC_A:
+		return c.exec_DEC_r(opCode)
+	case opCode_INC_BC, opCode_INC_DE, opCode_INC_HL, opCode_INC_SP:
+		c.setReg16(opCode>>4&0x03, c.reg16(opCode>>4&0x03)+1)
+
+		return 8, nil
+	case opCode_DEC_BC, opCode_DEC_DE, opCode_DEC_HL, opCode_DEC_SP:
+		c.setReg16(opCode>>4&0x03, c.reg16(opCode>>4&0x03)-1)
+
+		return 8, nil
+	case opCode_ADD_HL_BC, opCode_ADD_HL_DE, opCode_ADD_HL_HL, opCode_ADD_HL_SP:
+		c.addHL(c.reg16(opCode >> 4 & 0x03))
+
+		return 8, nil
+	case opCode_ADD_SP_er:
+		return c.exec_ADD_SP_er()
+	case opCode_ADD_A_nr, opCode_ADC_A_nr, opCode_SUB_nr, opCode_SBC_A_nr,
+		opCode_AND_nr, opCode_XOR_nr, opCode_OR_nr, opCode_CP_nr:
+		return c.exec_ALU_A_nr(opCode)
+	case opCode_RLCA:
+		c.SetA(c.rlc(c.A()))
+		c.SetFlagZ(false)
+
+		return 4, nil
+	case opCode_RRCA:
+		c.SetA(c.rrc(c.A()))
+		c.SetFlagZ(false)
+
+		return 4, nil
+	case opCode_RLA:
+		c.SetA(c.rl(c.A()))
+		c.SetFlagZ(false)
+
+		return 4, nil
+	case opCode_RRA:
+		c.SetA(c.rr(c.A()))
+		c.SetFlagZ(false)
+
+		return 4, nil
+	case opCode_DAA:
+		c.daa()
+
+		return 4, nil
+	case opCode_CPL:
+		c.SetA(^c.A())
+		c.SetFlagN(true)
+		c.SetFlagH(true)
+
+		return 4, nil
+	case opCode_SCF:
+		c.SetFlagN(false)
+		c.SetFlagH(false)
+		c.SetFlagC(true)
+
+		return 4, nil
+	case opCode_CCF:
+		c.SetFlagN(false)
+		c.SetFlagH(false)
+		c.SetFlagC(!c.FlagC())
+
+		return 4, nil
+	case opCode_JR_er:
+		return c.exec_JR_er(true)
+	case opCode_JR_NZ_er, opCode_JR_Z_er, opCode_JR_NC_er, opCode_JR_C_er:
+		return c.exec_JR_er(c.condition(opCode))
+	case opCode_JP_nnr:
+		return c.exec_JP_nnr(true)
+	case opCode_JP_NZ_nnr, opCode_JP_Z_nnr, opCode_JP_NC_nnr, opCode_JP_C_nnr:
+		return c.exec_JP_nnr(c.condition(opCode))
+	case opCode_JP_HL:
+		c.SetPC(c.HL())
+
+		return 4, nil
+	case opCode_CALL_nnr:
+		return c.exec_CALL_nnr(true)
+	case opCode_CALL_NZ_nnr, opCode_CALL_Z_nnr, opCode_CALL_NC_nnr, opCode_CALL_C_nnr:
+		return c.exec_CALL_nnr(c.condition(opCode))
+	case opCode_RET:
+		return c.exec_RET()
+	case opCode_RET_NZ, opCode_RET_Z, opCode_RET_NC, opCode_RET_C:
+		return c.exec_RET_cc(opCode)
+	case opCode_RETI:
+		c.ime = true
+
+		return c.exec_RET()
+	case opCode_RST_00, opCode_RST_08, opCode_RST_10, opCode_RST_18,
+		opCode_RST_20, opCode_RST_28, opCode_RST_30, opCode_RST_38:
+		return c.exec_RST(opCode)
+	case opCode_PUSH_BC, opCode_PUSH_DE, opCode_PUSH_HL, opCode_PUSH_AF:
+		return c.exec_PUSH_rr(opCode)
+	case opCode_POP_BC, opCode_POP_DE, opCode_POP_HL, opCode_POP_AF:
+		return c.exec_POP_rr(opCode)
+	case opCode_DI:
+		c.ime = false
+		c.imeDelay = false
+
+		return 4, nil
+	case opCode_EI:
+		c.imeDelay = true
+
+		return 4, nil
+	case opCode_PREFIX_CB:
+		return c.exec_PREFIX_CB()
+	case opCode_HALT:
+		return c.exec_HALT()
+	case opCode_STOP:
+		return c.exec_STOP()
+	}
+
+	switch {
+	case opCode >= opCode_LD_r_r_lo && opCode <= opCode_LD_r_r_hi:
+		return c.exec_LD_r_r(opCode)
+	case opCode >= opCode_ALU_A_r_lo && opCode <= opCode_ALU_A_r_hi:
+		return c.exec_ALU_A_r(opCode)
 	default:
-		return 0, fmt.Errorf("unimplemented opcode: 0x%02X", opCode)
+		return 0, fmt.Errorf("illegal opcode: 0x%02X", opCode)
 	}
 }
 
@@ -353,19 +1409,112 @@ func debug(v uint) {
 	fmt.Printf("0x%04X\n", v) // 16-bit: 0x0100, 0xffff
 }
 
+// Step runs one instruction (or one idle step while halted or stopped, or
+// one interrupt dispatch) and advances the peripherals by the cycles taken,
+// including any the CPU spent halted for a VRAM DMA. The cycles are CPU
+// cycles: in double speed they last half as long, and schedulers that count
+// real time must convert them with dots.
 func (c *cpu) Step() (int, error) {
+	c.speed = c.doubleSpeed
+	c.cycles = 0
+	cycles, err := c.step()
+
+	// Memory accesses already ticked the M-cycles up to them; the rest of the
+	// instruction follows
+	if rest := cycles - c.cycles; rest > 0 {
+		c.bus.tick(rest, c.speed)
+		c.cycles += rest
+	}
+
+	// The instruction may have started a general-purpose DMA, and an HBlank
+	// DMA block may be copied while the peripherals catch up
+	for stall := c.bus.hdma.stall(c.speed); stall > 0; stall = c.bus.hdma.stall(c.speed) {
+		c.bus.tick(stall, c.speed)
+		c.cycles += stall
+	}
+
+	return c.cycles, err
+}
+
+// read accesses memory at the end of an M-cycle: the peripherals advance
+// through the M-cycle first, so a register read sees the state the CPU
+// would on hardware rather than the one at the start of the instruction.
+func (c *cpu) read(addr uint16) (uint8, error) {
+	c.tickAccess()
+
+	return c.bus.Read(addr)
+}
+
+// write accesses memory at the end of an M-cycle, like read.
+func (c *cpu) write(addr uint16, value uint8) error {
+	c.tickAccess()
+
+	return c.bus.Write(addr, value)
+}
+
+func (c *cpu) tickAccess() {
+	c.bus.tick(4, c.speed)
+	c.cycles += 4
+}
+
+func (c *cpu) step() (int, error) {
+	pending := c.bus.interrupts.pending()
+
+	// Only a selected P1 line falling while stopped ends STOP; a joypad
+	// interrupt flag left pending from before does not.
+	if c.stopped {
+		lines := c.bus.joypad.lines()
+		fell := c.stopLines&^lines != 0
+		c.stopLines = lines
+
+		if !fell {
+			return 4, nil
+		}
+
+		c.stopped = false
+		c.bus.timer.stopped = false
+	}
+
+	// A halted CPU keeps the clock running and wakes up on any pending
+	// interrupt, whether or not IME allows it to be serviced.
+	if c.halted {
+		if pending == 0 {
+			return 4, nil
+		}
+
+		c.halted = false
+	}
+
+	if c.ime && pending != 0 {
+		cycles, err := c.serviceInterrupt(pending)
+
+		if err != nil {
+			return cycles, fmt.Errorf("failed to service interrupt: %v", err)
+		}
+
+		return cycles, nil
+	}
+
+	enableIME := c.imeDelay
+
 	opCode, err := c.fetch()
 
 	if err != nil {
-		return c.cycles, fmt.Errorf("failed to read opcode at PC: %v", err)
+		return 0, fmt.Errorf("failed to read opcode at PC: %v", err)
 	}
 
 	cycles, err := c.exec(opCode)
-	c.cycles = cycles
 
 	if err != nil {
-		return c.cycles, fmt.Errorf("failed to read opcode at PC: %v", err)
+		return cycles, fmt.Errorf("failed to read opcode at PC: %v", err)
+	}
+
+	// EI takes effect only once the instruction following it has run, unless
+	// a DI in between cancelled it.
+	if enableIME && c.imeDelay {
+		c.ime = true
+		c.imeDelay = false
 	}
 
-	return c.cycles, nil
+	return cycles, nil
 }
@@ -0,0 +1,132 @@
+package gb
+
+import "fmt"
+
+// Operations of the CB-prefixed page. Bits 6-7 of the second opcode byte pick
+// the group; for the rotate/shift group bits 3-5 pick the operation and for
+// BIT/RES/SET they pick the bit number. Bits 0-2 always pick the operand.
+const (
+	cbGroupShift = 0x00
+	cbGroupBIT   = 0x01
+	cbGroupRES   = 0x02
+	cbGroupSET   = 0x03
+
+	cbRLC  = 0x00
+	cbRRC  = 0x01
+	cbRL   = 0x02
+	cbRR   = 0x03
+	cbSLA  = 0x04
+	cbSRA  = 0x05
+	cbSWAP = 0x06
+	cbSRL  = 0x07
+)
+
+func (c *cpu) sla(value uint8) uint8 {
+	result := value << 1
+
+	c.SetF(0)
+	c.SetFlagZ(result == 0)
+	c.SetFlagC(value&0x80 != 0)
+
+	return result
+}
+
+func (c *cpu) sra(value uint8) uint8 {
+	result := value>>1 | value&0x80
+
+	c.SetF(0)
+	c.SetFlagZ(result == 0)
+	c.SetFlagC(value&0x01 != 0)
+
+	return result
+}
+
+func (c *cpu) swap(value uint8) uint8 {
+	result := value<<4 | value>>4
+
+	c.SetF(0)
+	c.SetFlagZ(result == 0)
+
+	return result
+}
+
+func (c *cpu) srl(value uint8) uint8 {
+	result := value >> 1
+
+	c.SetF(0)
+	c.SetFlagZ(result == 0)
+	c.SetFlagC(value&0x01 != 0)
+
+	return result
+}
+
+func (c *cpu) shift(op uint8, value uint8) uint8 {
+	switch op {
+	case cbRLC:
+		return c.rlc(value)
+	case cbRRC:
+		return c.rrc(value)
+	case cbRL:
+		return c.rl(value)
+	case cbRR:
+		return c.rr(value)
+	case cbSLA:
+		return c.sla(value)
+	case cbSRA:
+		return c.sra(value)
+	case cbSWAP:
+		return c.swap(value)
+	default:
+		return c.srl(value)
+	}
+}
+
+// exec_PREFIX_CB fetches the second opcode byte and runs the CB-page
+// instruction it selects. The returned cycles include the prefix fetch.
+func (c *cpu) exec_PREFIX_CB() (int, error) {
+	opCode, err := c.fetch()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read CB opcode at PC+1: %v", err)
+	}
+
+	index := opCode & 0x07
+	bit := opCode >> 3 & 0x07
+
+	value, err := c.reg8(index)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var result uint8
+
+	switch opCode >> 6 {
+	case cbGroupBIT:
+		c.SetFlagZ(value&(1<<bit) == 0)
+		c.SetFlagN(false)
+		c.SetFlagH(true)
+
+		if index == regHLm {
+			return 12, nil
+		}
+
+		return 8, nil
+	case cbGroupRES:
+		result = value &^ (1 << bit)
+	case cbGroupSET:
+		result = value | 1<<bit
+	default:
+		result = c.shift(bit, value)
+	}
+
+	if err := c.setReg8(index, result); err != nil {
+		return 0, err
+	}
+
+	if index == regHLm {
+		return 16, nil
+	}
+
+	return 8, nil
+}
@@ -0,0 +1,135 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// CB PREFIX - SECOND OPCODE PAGE
+// =============================================================================
+//
+// Opcode 0xCB is a prefix: the next byte selects one of 256 instructions.
+//
+//   0x00-0x3F: RLC, RRC, RL, RR, SLA, SRA, SWAP, SRL
+//   0x40-0x7F: BIT b, r
+//   0x80-0xBF: RES b, r
+//   0xC0-0xFF: SET b, r
+//
+// The low 3 bits select the operand: B, C, D, E, H, L, (HL), A.
+//
+// Cycles: 8 for registers, 12 for BIT b,(HL), 16 for the other (HL) forms.
+//
+// Reference: Pan Docs - CPU Instruction Set
+// https://gbdev.io/pandocs/CPU_Instruction_Set.html
+
+func TestCPU_CB_ShiftsAndRotates(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opCode   uint8
+		value    uint8
+		f        uint8
+		expected uint8
+		flags    uint8
+	}{
+		{"RLC B", 0x00, 0x85, 0x00, 0x0B, flagC},
+		{"RRC B", 0x08, 0x01, 0x00, 0x80, flagC},
+		{"RL B with carry in", 0x10, 0x80, flagC, 0x01, flagC},
+		{"RL B to zero", 0x10, 0x80, 0x00, 0x00, flagZ | flagC},
+		{"RR B with carry in", 0x18, 0x01, flagC, 0x80, flagC},
+		{"SLA B", 0x20, 0xC0, 0x00, 0x80, flagC},
+		{"SRA B keeps sign", 0x28, 0x81, 0x00, 0xC0, flagC},
+		{"SWAP B", 0x30, 0xF1, flagC, 0x1F, 0x00},
+		{"SWAP B zero", 0x30, 0x00, 0x00, 0x00, flagZ},
+		{"SRL B", 0x38, 0x01, 0x00, 0x00, flagZ | flagC},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu := newCPU()
+			cpu.SetPC(0x0000)
+			cpu.SetB(tc.value)
+			cpu.SetF(tc.f)
+			cpu.bus.LoadROM([]uint8{0xCB, tc.opCode})
+
+			cycles, err := cpu.Step()
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, cpu.B())
+			require.Equal(t, tc.flags, cpu.F())
+			require.Equal(t, 8, cycles)
+			require.Equal(t, uint16(0x0002), cpu.PC(), "PC should skip both bytes")
+		})
+	}
+}
+
+func TestCPU_CB_BIT(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetA(0x80)
+	cpu.SetF(flagC | flagN)
+
+	// BIT 7, A ; BIT 0, A
+	cpu.bus.LoadROM([]uint8{0xCB, 0x7F, 0xCB, 0x47})
+
+	cpu.Step()
+	require.False(t, cpu.FlagZ(), "Z flag should be clear when the bit is set")
+	require.False(t, cpu.FlagN(), "N flag should be cleared")
+	require.True(t, cpu.FlagH(), "H flag should be set")
+	require.True(t, cpu.FlagC(), "C flag should be unchanged")
+
+	cpu.Step()
+	require.True(t, cpu.FlagZ(), "Z flag should be set when the bit is clear")
+	require.Equal(t, uint8(0x80), cpu.A(), "BIT should not modify the operand")
+}
+
+func TestCPU_CB_RES_SET(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetE(0xFF)
+	cpu.SetL(0x00)
+	cpu.SetF(0xF0)
+
+	// RES 3, E ; SET 6, L
+	cpu.bus.LoadROM([]uint8{0xCB, 0x9B, 0xCB, 0xF5})
+
+	cpu.Step()
+	cpu.Step()
+
+	require.Equal(t, uint8(0xF7), cpu.E())
+	require.Equal(t, uint8(0x40), cpu.L())
+	require.Equal(t, uint8(0xF0), cpu.F(), "RES and SET should not touch flags")
+}
+
+func TestCPU_CB_HLm_Cycles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opCode   uint8
+		expected uint8
+		cycles   int
+	}{
+		{"RLC (HL)", 0x06, 0x02, 16},
+		{"BIT 0, (HL)", 0x46, 0x01, 12},
+		{"RES 0, (HL)", 0x86, 0x00, 16},
+		{"SET 7, (HL)", 0xFE, 0x81, 16},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu := newCPU()
+			cpu.SetPC(0x0000)
+			cpu.SetHL(0xC000)
+			cpu.bus.Write(0xC000, 0x01)
+			cpu.bus.LoadROM([]uint8{0xCB, tc.opCode})
+
+			cycles, err := cpu.Step()
+			require.NoError(t, err)
+
+			value, _ := cpu.bus.Read(0xC000)
+			require.Equal(t, tc.expected, value)
+			require.Equal(t, tc.cycles, cycles)
+		})
+	}
+}
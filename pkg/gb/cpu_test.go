@@ -1442,8 +1442,8 @@ func TestCPU_PUSH_BC(t *testing.T) {
 	require.Equal(t, 16, cycles, "PUSH BC should take 16 cycles")
 
 	// Check stack contents
-	_, lowByte := cpu.bus.Read(0xFFFC)
-	_, highByte := cpu.bus.Read(0xFFFD)
+	lowByte, _ := cpu.bus.Read(0xFFFC)
+	highByte, _ := cpu.bus.Read(0xFFFD)
 	require.Equal(t, uint8(0x34), lowByte, "Low byte should be at SP")
 	require.Equal(t, uint8(0x12), highByte, "High byte should be at SP+1")
 }
@@ -1503,3 +1503,540 @@ func TestCPU_POP_AF_MasksLowerNibble(t *testing.T) {
 	require.Equal(t, uint8(0x12), cpu.A(), "A should be popped")
 	require.Equal(t, uint8(0xF0), cpu.F(), "F should have lower nibble masked")
 }
+
+// =============================================================================
+// LD r, (HL) / LD (HL), n - MEMORY OPERANDS THROUGH HL
+// =============================================================================
+//
+// Operand index 6 in the regular opcode blocks means "the byte at (HL)".
+// Going through memory costs an extra 4 cycles (8 for LD (HL), n).
+//
+// Opcodes:
+//   0x46: LD B, (HL) (8 cycles)
+//   0x70: LD (HL), B (8 cycles)
+//   0x36: LD (HL), n (12 cycles)
+
+func TestCPU_LD_r_HLm(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetHL(0xC010)
+	cpu.bus.Write(0xC010, 0x5A)
+
+	// LD B, (HL)
+	cpu.bus.LoadROM([]uint8{0x46})
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	require.Equal(t, uint8(0x5A), cpu.B(), "B should be loaded from (HL)")
+	require.Equal(t, 8, cycles, "LD B, (HL) should take 8 cycles")
+}
+
+func TestCPU_LD_HLm_n(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetHL(0xC020)
+
+	// LD (HL), 0x77
+	cpu.bus.LoadROM([]uint8{0x36, 0x77})
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	value, _ := cpu.bus.Read(0xC020)
+	require.Equal(t, uint8(0x77), value, "(HL) should hold the immediate value")
+	require.Equal(t, 12, cycles, "LD (HL), n should take 12 cycles")
+}
+
+func TestCPU_LD_HLI_A_And_LD_A_HLD(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetHL(0xC000)
+	cpu.SetA(0x99)
+
+	// LD (HL+), A ; LD A, (HL-)
+	cpu.bus.LoadROM([]uint8{0x22, 0x3A})
+	cpu.bus.Write(0xC001, 0x11)
+
+	cpu.Step()
+	require.Equal(t, uint16(0xC001), cpu.HL(), "HL should be incremented")
+
+	value, _ := cpu.bus.Read(0xC000)
+	require.Equal(t, uint8(0x99), value, "A should be stored at the old HL")
+
+	cpu.Step()
+	require.Equal(t, uint16(0xC000), cpu.HL(), "HL should be decremented")
+	require.Equal(t, uint8(0x11), cpu.A(), "A should be loaded from the old HL")
+}
+
+// =============================================================================
+// ALU A, r / ALU A, n - 8-BIT ARITHMETIC AND LOGIC
+// =============================================================================
+//
+// Opcodes 0x80-0xBF combine A with a register or (HL); 0xC6, 0xCE, ..., 0xFE
+// combine A with an immediate. The operation is encoded in bits 3-5:
+// ADD, ADC, SUB, SBC, AND, XOR, OR, CP.
+//
+// Cycles: 4 (register), 8 ((HL) or immediate)
+
+func TestCPU_ALU_Flags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		program  []uint8
+		a        uint8
+		b        uint8
+		f        uint8
+		expected uint8
+		flags    uint8
+		cycles   int
+	}{
+		{"ADD A, B half carry", []uint8{0x80}, 0x0F, 0x01, 0x00, 0x10, flagH, 4},
+		{"ADD A, B carry and zero", []uint8{0x80}, 0xF0, 0x10, 0x00, 0x00, flagZ | flagC, 4},
+		{"ADC A, B uses carry", []uint8{0x88}, 0x0E, 0x01, flagC, 0x10, flagH, 4},
+		{"SUB B equal", []uint8{0x90}, 0x3E, 0x3E, 0x00, 0x00, flagZ | flagN, 4},
+		{"SUB B borrow", []uint8{0x90}, 0x10, 0x20, 0x00, 0xF0, flagN | flagC, 4},
+		{"SBC A, B uses carry", []uint8{0x98}, 0x10, 0x0F, flagC, 0x00, flagZ | flagN | flagH, 4},
+		{"AND B sets H", []uint8{0xA0}, 0xF0, 0x0F, 0x00, 0x00, flagZ | flagH, 4},
+		{"OR B", []uint8{0xB0}, 0xF0, 0x0F, flagC, 0xFF, 0x00, 4},
+		{"CP B keeps A", []uint8{0xB8}, 0x42, 0x42, 0x00, 0x42, flagZ | flagN, 4},
+		{"ADD A, n", []uint8{0xC6, 0x01}, 0xFF, 0x00, 0x00, 0x00, flagZ | flagH | flagC, 8},
+		{"CP n borrow", []uint8{0xFE, 0x50}, 0x40, 0x00, 0x00, 0x40, flagN | flagC, 8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu := newCPU()
+			cpu.SetPC(0x0000)
+			cpu.SetA(tc.a)
+			cpu.SetB(tc.b)
+			cpu.SetF(tc.f)
+			cpu.bus.LoadROM(tc.program)
+
+			cycles, err := cpu.Step()
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, cpu.A(), "A should hold the result")
+			require.Equal(t, tc.flags, cpu.F(), "flags should match")
+			require.Equal(t, tc.cycles, cycles)
+		})
+	}
+}
+
+func TestCPU_INC_HLm(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetHL(0xC000)
+	cpu.bus.Write(0xC000, 0xFF)
+
+	// INC (HL)
+	cpu.bus.LoadROM([]uint8{0x34})
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	value, _ := cpu.bus.Read(0xC000)
+	require.Equal(t, uint8(0x00), value, "(HL) should wrap to 0x00")
+	require.True(t, cpu.FlagZ(), "Z flag should be set")
+	require.True(t, cpu.FlagH(), "H flag should be set")
+	require.Equal(t, 12, cycles, "INC (HL) should take 12 cycles")
+}
+
+// =============================================================================
+// DAA - DECIMAL ADJUST ACCUMULATOR
+// =============================================================================
+//
+// Corrects A into packed BCD after an ADD/ADC/SUB/SBC, using N, H and C to
+// know which operation ran.
+//
+// Opcode: 0x27
+// Cycles: 4
+// Flags: Z - 0 C
+
+func TestCPU_DAA(t *testing.T) {
+	testCases := []struct {
+		name     string
+		program  []uint8
+		a        uint8
+		b        uint8
+		expected uint8
+		carry    bool
+	}{
+		{"0x15 + 0x27 = 0x42", []uint8{0x80, 0x27}, 0x15, 0x27, 0x42, false},
+		{"0x99 + 0x01 = 0x00 carry", []uint8{0x80, 0x27}, 0x99, 0x01, 0x00, true},
+		{"0x42 - 0x15 = 0x27", []uint8{0x90, 0x27}, 0x42, 0x15, 0x27, false},
+		{"0x10 - 0x20 = 0x90 borrow", []uint8{0x90, 0x27}, 0x10, 0x20, 0x90, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu := newCPU()
+			cpu.SetPC(0x0000)
+			cpu.SetA(tc.a)
+			cpu.SetB(tc.b)
+			cpu.bus.LoadROM(tc.program)
+
+			cpu.Step()
+			cycles, err := cpu.Step()
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, cpu.A())
+			require.Equal(t, tc.carry, cpu.FlagC())
+			require.False(t, cpu.FlagH(), "H flag should be cleared by DAA")
+			require.Equal(t, 4, cycles, "DAA should take 4 cycles")
+		})
+	}
+}
+
+// =============================================================================
+// 16-BIT ARITHMETIC
+// =============================================================================
+//
+// Opcodes:
+//   0x09/0x19/0x29/0x39: ADD HL, rr (8 cycles, flags - 0 H C from bits 11/15)
+//   0xE8: ADD SP, e (16 cycles, flags 0 0 H C from the low byte)
+//   0xF8: LD HL, SP+e (12 cycles, same flags as ADD SP, e)
+
+func TestCPU_ADD_HL_BC(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetHL(0x0FFF)
+	cpu.SetBC(0x0001)
+	cpu.SetF(flagZ)
+
+	// ADD HL, BC
+	cpu.bus.LoadROM([]uint8{0x09})
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	require.Equal(t, uint16(0x1000), cpu.HL())
+	require.True(t, cpu.FlagZ(), "Z flag should be unchanged")
+	require.True(t, cpu.FlagH(), "H flag should be set on carry from bit 11")
+	require.False(t, cpu.FlagC())
+	require.Equal(t, 8, cycles, "ADD HL, BC should take 8 cycles")
+}
+
+func TestCPU_ADD_SP_e(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetSP(0xFFF8)
+
+	// ADD SP, -1
+	cpu.bus.LoadROM([]uint8{0xE8, 0xFF})
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	require.Equal(t, uint16(0xFFF7), cpu.SP())
+	require.Equal(t, uint8(flagH|flagC), cpu.F(), "carries come from the low byte")
+	require.Equal(t, 16, cycles, "ADD SP, e should take 16 cycles")
+}
+
+func TestCPU_LD_HL_SP_e(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetSP(0xFFF8)
+
+	// LD HL, SP+2
+	cpu.bus.LoadROM([]uint8{0xF8, 0x02})
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	require.Equal(t, uint16(0xFFFA), cpu.HL())
+	require.Equal(t, uint16(0xFFF8), cpu.SP(), "SP should be unchanged")
+	require.Equal(t, 12, cycles, "LD HL, SP+e should take 12 cycles")
+}
+
+// =============================================================================
+// CONDITIONAL CONTROL FLOW
+// =============================================================================
+//
+// JR/JP/CALL/RET cc only take the branch when NZ, Z, NC or C holds, and cost
+// fewer cycles when they fall through.
+//
+//   JR cc, e:   12 taken / 8 not taken
+//   JP cc, nn:  16 taken / 12 not taken
+//   CALL cc, nn: 24 taken / 12 not taken
+//   RET cc:     20 taken / 8 not taken
+
+func TestCPU_ConditionalBranches_Cycles(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opCode uint8
+		f      uint8
+		pc     uint16
+		cycles int
+	}{
+		{"JR NZ taken", 0x20, 0x00, 0x0007, 12},
+		{"JR NZ not taken", 0x20, flagZ, 0x0002, 8},
+		{"JP C taken", 0xDA, flagC, 0x0005, 16},
+		{"JP C not taken", 0xDA, 0x00, 0x0003, 12},
+		{"CALL Z taken", 0xCC, flagZ, 0x0005, 24},
+		{"CALL Z not taken", 0xCC, 0x00, 0x0003, 12},
+		{"RET NC taken", 0xD0, 0x00, 0x0150, 20},
+		{"RET NC not taken", 0xD0, flagC, 0x0001, 8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu := newCPU()
+			cpu.SetPC(0x0000)
+			cpu.SetSP(0xFFFC)
+			cpu.SetF(tc.f)
+			cpu.bus.Write(0xFFFC, 0x50)
+			cpu.bus.Write(0xFFFD, 0x01)
+			cpu.bus.LoadROM([]uint8{tc.opCode, 0x05, 0x00})
+
+			cycles, err := cpu.Step()
+			require.NoError(t, err)
+
+			require.Equal(t, tc.pc, cpu.PC())
+			require.Equal(t, tc.cycles, cycles)
+		})
+	}
+}
+
+// =============================================================================
+// RST n - RESTART
+// =============================================================================
+//
+// Push PC and jump to one of the fixed vectors 0x00, 0x08, ..., 0x38.
+//
+// Cycles: 16
+
+func TestCPU_RST_38(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetSP(0xFFFE)
+
+	// RST 38h
+	cpu.bus.LoadROM([]uint8{0xFF})
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	require.Equal(t, uint16(0x0038), cpu.PC())
+	require.Equal(t, uint16(0xFFFC), cpu.SP())
+	require.Equal(t, 16, cycles, "RST should take 16 cycles")
+
+	lowByte, _ := cpu.bus.Read(0xFFFC)
+	require.Equal(t, uint8(0x01), lowByte, "return address should be pushed")
+}
+
+// =============================================================================
+// LDH - HIGH PAGE LOADS
+// =============================================================================
+//
+// Opcodes:
+//   0xE0: LDH (n), A (12 cycles)
+//   0xF0: LDH A, (n) (12 cycles)
+//   0xE2: LD (C), A  (8 cycles)
+//   0xF2: LD A, (C)  (8 cycles)
+
+func TestCPU_LDH(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetA(0x3C)
+	cpu.SetC(0x81)
+
+	// LDH (0x80), A ; LD A, (C)
+	cpu.bus.LoadROM([]uint8{0xE0, 0x80, 0xF2})
+	cpu.bus.Write(0xFF81, 0x7E)
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	value, _ := cpu.bus.Read(0xFF80)
+	require.Equal(t, uint8(0x3C), value)
+	require.Equal(t, 12, cycles, "LDH (n), A should take 12 cycles")
+
+	cycles, err = cpu.Step()
+	require.NoError(t, err)
+
+	require.Equal(t, uint8(0x7E), cpu.A())
+	require.Equal(t, 8, cycles, "LD A, (C) should take 8 cycles")
+}
+
+func TestCPU_IllegalOpcode_Gives_Error(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.bus.LoadROM([]uint8{0xD3})
+
+	_, err := cpu.Step()
+
+	require.Error(t, err)
+}
+
+// =============================================================================
+// HALT - WAIT FOR INTERRUPT
+// =============================================================================
+//
+// HALT stops instruction execution until IE & IF is non-zero. The clock keeps
+// running (Step returns 4 cycles per idle step) and the CPU wakes up even
+// when IME is off; it only jumps to a handler if IME is on.
+//
+// HALT bug: if IME is off and an interrupt is already pending when HALT
+// executes, the CPU does not halt and the next byte is read twice.
+//
+// Opcode: 0x76
+// Cycles: 4
+
+func TestCPU_HALT_IdlesUntilInterruptPending(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.bus.Write(0xFFFF, interruptTimer)
+
+	// HALT ; INC A
+	cpu.bus.LoadROM([]uint8{0x76, 0x3C})
+	cpu.SetA(0x00)
+
+	cpu.Step()
+	require.True(t, cpu.halted)
+
+	for range 3 {
+		cycles, err := cpu.Step()
+		require.NoError(t, err)
+		require.Equal(t, 4, cycles, "a halted CPU should keep clocking 4 cycles")
+		require.Equal(t, uint16(0x0001), cpu.PC())
+	}
+
+	// IME is off: the CPU wakes up and continues after HALT
+	cpu.bus.Write(0xFF0F, interruptTimer)
+	cpu.Step()
+
+	require.False(t, cpu.halted)
+	require.Equal(t, uint8(0x01), cpu.A(), "INC A should run after waking up")
+}
+
+func TestCPU_HALT_WakesIntoHandlerWithIME(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetSP(0xFFFE)
+	cpu.ime = true
+	cpu.bus.Write(0xFFFF, interruptVBlank)
+
+	// HALT
+	cpu.bus.LoadROM([]uint8{0x76})
+
+	cpu.Step()
+	cpu.bus.Write(0xFF0F, interruptVBlank)
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	require.Equal(t, 20, cycles)
+	require.Equal(t, uint16(0x0040), cpu.PC())
+}
+
+func TestCPU_HALT_Bug_ReadsNextByteTwice(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetA(0x00)
+	cpu.bus.Write(0xFFFF, interruptVBlank)
+	cpu.bus.Write(0xFF0F, interruptVBlank)
+
+	// HALT ; INC A ; NOP
+	cpu.bus.LoadROM([]uint8{0x76, 0x3C, 0x00})
+
+	cpu.Step()
+	require.False(t, cpu.halted, "HALT should not halt with IME=0 and a pending interrupt")
+
+	cpu.Step()
+	require.Equal(t, uint16(0x0001), cpu.PC(), "PC should not advance past INC A")
+
+	cpu.Step()
+	require.Equal(t, uint8(0x02), cpu.A(), "INC A should run twice")
+	require.Equal(t, uint16(0x0002), cpu.PC())
+}
+
+// =============================================================================
+// STOP - VERY LOW POWER MODE
+// =============================================================================
+//
+// STOP halts the CPU until a joypad line goes low. On CGB, if KEY1 has the
+// speed switch armed, STOP switches CPU speed instead.
+//
+// Opcode: 0x10 0x00
+// Cycles: 4
+
+func TestCPU_STOP_WaitsForJoypad(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+
+	// STOP ; INC A
+	cpu.bus.LoadROM([]uint8{0x10, 0x00, 0x3C})
+	cpu.SetA(0x00)
+
+	cpu.Step()
+	require.True(t, cpu.stopped)
+	require.Equal(t, uint16(0x0002), cpu.PC(), "STOP should skip its padding byte")
+
+	// Other interrupts do not end STOP
+	cpu.bus.Write(0xFFFF, 0x1F)
+	cpu.bus.Write(0xFF0F, interruptTimer)
+	cpu.Step()
+	require.True(t, cpu.stopped)
+
+	cpu.bus.Write(regP1, p1SelectDPad)
+	cpu.bus.joypad.Press(ButtonA)
+	cpu.Step()
+	require.False(t, cpu.stopped)
+	require.Equal(t, uint8(0x01), cpu.A())
+}
+
+func TestCPU_STOP_IgnoresStaleJoypadFlag(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.bus.Write(regP1, p1SelectDPad)
+
+	// STOP ; INC A
+	cpu.bus.LoadROM([]uint8{0x10, 0x00, 0x3C})
+	cpu.SetA(0x00)
+
+	// A joypad interrupt requested before STOP and never serviced
+	cpu.bus.Write(0xFF0F, interruptJoypad)
+
+	cpu.Step()
+	cpu.Step()
+	require.True(t, cpu.stopped, "a stale IF bit must not end STOP")
+
+	cpu.bus.joypad.Press(ButtonStart)
+	cpu.Step()
+	require.False(t, cpu.stopped)
+	require.Equal(t, uint8(0x01), cpu.A())
+}
+
+func TestCPU_STOP_HoldsTimer(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+
+	// STOP
+	cpu.bus.LoadROM([]uint8{0x10, 0x00})
+	cpu.Step()
+
+	div, _ := cpu.bus.Read(regDIV)
+
+	for range 1000 {
+		cpu.Step()
+	}
+
+	after, _ := cpu.bus.Read(regDIV)
+	require.True(t, cpu.stopped)
+	require.Equal(t, div, after, "DIV is frozen in STOP mode")
+}
+
+func TestCPU_STOP_SwitchesSpeedWhenArmed(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.speedSwitchArmed = true
+
+	// STOP
+	cpu.bus.LoadROM([]uint8{0x10, 0x00})
+
+	cpu.Step()
+
+	require.False(t, cpu.stopped)
+	require.True(t, cpu.doubleSpeed)
+	require.False(t, cpu.speedSwitchArmed)
+}
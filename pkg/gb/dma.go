@@ -0,0 +1,112 @@
+package gb
+
+const (
+	dmaLength        = Size160b
+	dmaCyclesPerByte = 4 // one byte per M-cycle
+	dmaStartupCycles = 4 // one M-cycle before the first byte
+	dmaEchoOffset    = 0x2000
+	dmaEchoStart     = 0xE000
+	dmaConflictEnd   = ioStart
+)
+
+// dma is the OAM DMA controller behind 0xFF46. Writing a page number copies
+// 160 bytes from page<<8 into OAM at one byte per M-cycle. While it runs the
+// CPU only sees HRAM, the I/O registers and IE; every other read returns the
+// byte the DMA is moving and writes are lost, which is why games run their
+// sprite routine from HRAM.
+//
+// The first byte moves one M-cycle after the write. During that start-up
+// cycle the bus is still free, unless a transfer being restarted holds it.
+//
+// Reference: Pan Docs - OAM DMA Transfer
+// https://gbdev.io/pandocs/OAM_DMA_Transfer.html
+type dma struct {
+	bus *bus
+
+	page    uint8 // last value written, reads back
+	active  bool
+	source  uint16
+	index   int
+	cycles  int   // T-cycles not yet spent on a whole byte
+	current uint8 // byte last read from the source, seen by conflicting reads
+
+	startup    int  // T-cycles left before the first byte moves
+	restarting bool // the transfer replaced a running one, which keeps the bus
+}
+
+func newDMA(b *bus) *dma {
+	return &dma{bus: b}
+}
+
+func (d *dma) Read(_ uint16) uint8 {
+	return d.page
+}
+
+// Write starts a transfer, restarting one that is already running.
+func (d *dma) Write(_ uint16, value uint8) {
+	d.restarting = d.active && d.startup == 0 || d.restarting
+	d.page = value
+	d.active = true
+	d.startup = dmaStartupCycles
+	d.source = uint16(value) << 8
+	d.index = 0
+	d.cycles = 0
+}
+
+// Tick copies one byte for every M-cycle that passed after the start-up
+// cycle.
+func (d *dma) Tick(cycles int) {
+	if !d.active {
+		return
+	}
+
+	d.cycles += cycles
+
+	if d.startup > 0 {
+		spent := min(d.startup, d.cycles)
+		d.startup -= spent
+		d.cycles -= spent
+
+		if d.startup == 0 {
+			d.restarting = false
+		}
+	}
+
+	for d.active && d.cycles >= dmaCyclesPerByte {
+		d.cycles -= dmaCyclesPerByte
+		d.current = d.read(d.source + uint16(d.index))
+		d.bus.oam[d.index] = d.current
+		d.index++
+
+		if d.index == dmaLength {
+			d.active = false
+		}
+	}
+}
+
+// read fetches a source byte. Pages 0xE0-0xFF sit on the same external bus
+// as WRAM, so they copy from WRAM the way echo RAM does.
+func (d *dma) read(addr uint16) uint8 {
+	if addr >= dmaEchoStart {
+		addr -= dmaEchoOffset
+	}
+
+	value, _ := d.bus.read(addr)
+
+	return value
+}
+
+// blocks reports whether the CPU loses access to addr during a transfer.
+func (d *dma) blocks(addr uint16) bool {
+	return d.active && (d.startup == 0 || d.restarting) && addr < dmaConflictEnd
+}
+
+// conflict is what a blocked CPU read returns: OAM is busy and reads 0xFF,
+// anything else sees the byte on the bus.
+func (d *dma) conflict(addr uint16) uint8 {
+	if addr >= 0xFE00 {
+		return 0xFF
+	}
+
+	return d.current
+}
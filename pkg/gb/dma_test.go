@@ -0,0 +1,165 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// OAM DMA
+// =============================================================================
+//
+// Writing XX to 0xFF46 copies XX00-XX9F into OAM, one byte per M-cycle, for
+// 160 M-cycles (640 T-cycles) after a one M-cycle start-up delay. Once the
+// copy runs the CPU can only use HRAM.
+//
+// Reference: Pan Docs - OAM DMA Transfer
+// https://gbdev.io/pandocs/OAM_DMA_Transfer.html
+
+func TestDMA_Sources(t *testing.T) {
+	testCases := []struct {
+		name  string
+		page  uint8
+		fill  uint16 // where the pattern is written
+		isROM bool
+	}{
+		{"ROM", 0x00, 0x0000, true},
+		{"VRAM", 0x80, 0x8000, false},
+		{"WRAM", 0xC1, 0xC100, false},
+		{"echo RAM", 0xE1, 0xC100, false},
+		{"echo beyond FE00", 0xFE, 0xDE00, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBus()
+
+			if tc.isROM {
+				rom := make([]uint8, dmaLength)
+
+				for i := range dmaLength {
+					rom[int(tc.fill)+i] = uint8(i + 1)
+				}
+
+				require.NoError(t, b.LoadROM(rom))
+			} else {
+				for i := range uint16(dmaLength) {
+					b.Write(tc.fill+i, uint8(i+1))
+				}
+			}
+
+			b.Write(regDMA, tc.page)
+			b.tick(dmaStartupCycles+dmaLength*dmaCyclesPerByte, false)
+
+			for i := range dmaLength {
+				require.Equal(t, uint8(i+1), b.oam[i], "OAM byte %d", i)
+			}
+
+			page, _ := b.Read(regDMA)
+			require.Equal(t, tc.page, page, "the DMA register reads back")
+		})
+	}
+}
+
+func TestDMA_TakesOneMCyclePerByte(t *testing.T) {
+	b := newBus()
+
+	for i := range uint16(dmaLength) {
+		b.Write(0xC000+i, 0xAA)
+	}
+
+	b.Write(regDMA, 0xC0)
+	b.tick(dmaStartupCycles, false)
+	require.Equal(t, uint8(0x00), b.oam[0], "the start-up M-cycle copies nothing")
+
+	b.tick(2, false)
+	require.Equal(t, uint8(0x00), b.oam[0], "half an M-cycle copies nothing")
+
+	b.tick(2, false)
+	require.Equal(t, uint8(0xAA), b.oam[0])
+	require.Equal(t, uint8(0x00), b.oam[1])
+
+	b.tick((dmaLength-2)*dmaCyclesPerByte+3, false)
+	require.True(t, b.dma.active)
+	require.Equal(t, uint8(0x00), b.oam[dmaLength-1])
+
+	b.tick(1, false)
+	require.False(t, b.dma.active)
+	require.Equal(t, uint8(0xAA), b.oam[dmaLength-1])
+}
+
+func TestDMA_BusConflicts(t *testing.T) {
+	b := newBus()
+	b.Write(0xC000, 0x11)
+	b.Write(0xC001, 0x22)
+	b.Write(0xD000, 0x33)
+	b.Write(0xFF80, 0x44)
+
+	b.oam[0] = 0x66
+	b.Write(regDMA, 0xC0)
+
+	value, _ := b.Read(0xFE00)
+	require.Equal(t, uint8(0x66), value, "OAM is accessible during start-up")
+
+	b.tick(dmaStartupCycles+dmaCyclesPerByte*2, false)
+
+	value, _ = b.Read(0xD000)
+	require.Equal(t, uint8(0x22), value, "WRAM reads see the byte the DMA is moving")
+
+	value, _ = b.Read(0x0000)
+	require.Equal(t, uint8(0x22), value, "so do ROM reads")
+
+	value, _ = b.Read(0xFE00)
+	require.Equal(t, uint8(0xFF), value, "OAM is busy")
+
+	value, _ = b.Read(0xFF80)
+	require.Equal(t, uint8(0x44), value, "HRAM stays accessible")
+
+	b.Write(0xD000, 0x55)
+	b.tick(dmaLength*dmaCyclesPerByte, false)
+
+	value, _ = b.Read(0xD000)
+	require.Equal(t, uint8(0x33), value, "writes during DMA are lost")
+}
+
+func TestDMA_RestartKeepsTheBus(t *testing.T) {
+	b := newBus()
+	b.Write(regDMA, 0xC0)
+	b.tick(dmaStartupCycles+dmaCyclesPerByte, false)
+
+	b.Write(regDMA, 0xC1)
+
+	value, _ := b.Read(0xFE00)
+	require.Equal(t, uint8(0xFF), value, "the running transfer holds OAM through the restart")
+}
+
+func TestDMA_ClockedByCPUStep(t *testing.T) {
+	cpu := newCPU()
+
+	for i := range uint16(dmaLength) {
+		cpu.bus.Write(0xC000+i, uint8(i))
+	}
+
+	// The usual HRAM routine: LD A,$C0 ; LDH ($46),A ; LD A,$28 ;
+	// loop: DEC A ; JR NZ,loop ; NOP
+	program := []uint8{0x3E, 0xC0, 0xE0, 0x46, 0x3E, 0x28, 0x3D, 0x20, 0xFD, 0x00}
+
+	for i, op := range program {
+		cpu.bus.Write(0xFF80+uint16(i), op)
+	}
+
+	cpu.SetPC(0xFF80)
+
+	for cpu.PC() != 0xFF80+uint16(len(program)-1) {
+		_, err := cpu.Step()
+		require.NoError(t, err)
+	}
+
+	require.False(t, cpu.bus.dma.active)
+
+	for i := range dmaLength {
+		require.Equal(t, uint8(i), cpu.bus.oam[i])
+	}
+}
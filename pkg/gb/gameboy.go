@@ -0,0 +1,365 @@
+package gb
+
+import (
+	"fmt"
+	"image"
+	"time"
+)
+
+// GameBoy is a complete machine: the CPU, the bus and every peripheral
+// behind it, wired for one model and one cartridge. Frontends and test
+// harnesses drive it with RunFrame or RunCycles, feed input through Joypad
+// and read the picture back from Framebuffer.
+type GameBoy struct {
+	cfg config
+	rom []uint8
+
+	cpu   *cpu
+	bus   *bus
+	saver *batterySaver // nil without WithSaveFile
+}
+
+// config collects the options before the machine is built, since the model
+// and the cartridge decide how it starts up.
+type config struct {
+	model      Model
+	modelSet   bool
+	bootROM    []uint8
+	mapper     Mapper
+	sink       AudioSink
+	sampleRate int
+	peer       LinkPeer
+	renderMode RenderMode
+	clock      Clock
+	savePath   string
+	saveEvery  time.Duration
+}
+
+// Option configures a GameBoy built by New.
+type Option func(*config)
+
+// WithModel selects the hardware to emulate. Without it, cartridges with
+// CGB support run on ModelCGB, DMG cartridges that support SGB functions on
+// ModelSGB and everything else on ModelDMG.
+func WithModel(model Model) Option {
+	return func(c *config) {
+		c.model = model
+		c.modelSet = true
+	}
+}
+
+// WithBootROM runs the given boot ROM image at power on instead of starting
+// the cartridge directly in the model's post-boot state.
+func WithBootROM(image []uint8) Option {
+	return func(c *config) {
+		c.bootROM = image
+	}
+}
+
+// WithMapper replaces the memory bank controller picked from the cartridge
+// header, for cartridges this package does not emulate. The header still
+// decides CGB and SGB support.
+func WithMapper(m Mapper) Option {
+	return func(c *config) {
+		c.mapper = m
+	}
+}
+
+// WithAudioSink streams audio to sink at sampleRate.
+func WithAudioSink(sink AudioSink, sampleRate int) Option {
+	return func(c *config) {
+		c.sink = sink
+		c.sampleRate = sampleRate
+	}
+}
+
+// WithLinkPeer plugs a peer into the link port.
+func WithLinkPeer(peer LinkPeer) Option {
+	return func(c *config) {
+		c.peer = peer
+	}
+}
+
+// WithRenderMode selects how the PPU draws mode 3. The default is
+// RenderScanline.
+func WithRenderMode(mode RenderMode) Option {
+	return func(c *config) {
+		c.renderMode = mode
+	}
+}
+
+// WithClock sets the time source of the cartridge real-time clock. The
+// default is the host clock.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithSaveFile keeps battery-backed cartridge RAM in the .sav file at path:
+// it is loaded by New, written by Close and, with a non-zero interval,
+// written from RunFrame at most once per interval when it changed. See
+// SavePath for the usual location.
+func WithSaveFile(path string, interval time.Duration) Option {
+	return func(c *config) {
+		c.savePath = path
+		c.saveEvery = interval
+	}
+}
+
+// New builds a GameBoy running rom and resets it. Unless WithModel says
+// otherwise, the model is picked from the cartridge header: the CGB flag
+// first, then the SGB flag.
+func New(rom []uint8, opts ...Option) (*GameBoy, error) {
+	cfg := config{clock: systemClock{}, sampleRate: DefaultSampleRate}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	g := &GameBoy{cfg: cfg, rom: rom}
+
+	if err := g.powerOn(); err != nil {
+		return nil, err
+	}
+
+	if cfg.savePath != "" {
+		g.saver = newBatterySaver(g.bus, cfg.savePath, cfg.saveEvery)
+
+		if err := g.saver.Load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
+// powerOn builds a fresh bus with every peripheral in its power-on state,
+// loads the cartridge and resets a new CPU on it.
+func (g *GameBoy) powerOn() error {
+	cfg := g.cfg
+
+	b := newBus()
+	b.clock = cfg.clock
+	b.attachPPU(cfg.renderMode)
+
+	if err := b.LoadROM(g.rom); err != nil {
+		return err
+	}
+
+	b.model = cfg.model
+
+	if !cfg.modelSet && b.cartridge != nil {
+		switch {
+		case b.cartridge.IsCGB():
+			b.model = ModelCGB
+		case b.cartridge.SupportsSGB():
+			b.model = ModelSGB
+		}
+	}
+
+	if err := b.SetBootROM(cfg.bootROM); err != nil {
+		return err
+	}
+
+	if cfg.mapper != nil {
+		b.mapper = cfg.mapper
+	}
+
+	b.apu.SetSink(cfg.sink, cfg.sampleRate)
+	b.serial.SetPeer(cfg.peer)
+
+	g.bus = b
+	g.cpu = newCPUOnBus(b)
+
+	return nil
+}
+
+// Reset switches the machine off and on again: the CPU, memory and every
+// peripheral go back to their power-on state and the cartridge's bank
+// registers are cleared. The cartridge RAM and clock, the held buttons, the
+// audio sink and the link peer stay. A mapper given with WithMapper is kept
+// as it is.
+func (g *GameBoy) Reset() error {
+	old := g.bus
+	old.apu.Flush()
+
+	var ram []uint8
+
+	if m, ok := old.mapper.(batteryBacked); ok && g.cfg.mapper == nil {
+		ram = m.SaveData()
+	}
+
+	if err := g.powerOn(); err != nil {
+		return err
+	}
+
+	if ram != nil {
+		if err := g.bus.mapper.(batteryBacked).LoadSaveData(ram); err != nil {
+			return err
+		}
+	}
+
+	g.bus.joypad.SetState(old.joypad.State())
+
+	if g.saver != nil {
+		g.saver.bus = g.bus
+	}
+
+	return nil
+}
+
+// Close hands buffered audio to the sink and writes the save file, if
+// there is one. Call it when the emulator exits.
+func (g *GameBoy) Close() error {
+	g.bus.apu.Flush()
+
+	if g.saver == nil {
+		return nil
+	}
+
+	return g.saver.Flush()
+}
+
+// RunCycles runs at least the given number of T-cycles at normal speed, so
+// a second of emulation is always ClockRate cycles, even in double speed.
+// It returns the number of cycles actually run, which overshoots by at most
+// one instruction.
+func (g *GameBoy) RunCycles(cycles int) (int, error) {
+	elapsed := 0
+
+	for elapsed < cycles {
+		n, err := g.step()
+
+		if err != nil {
+			return elapsed, err
+		}
+
+		elapsed += n
+	}
+
+	return elapsed, nil
+}
+
+// RunFrame runs until the PPU completes a frame. With the LCD off no frame
+// ever completes, so it stops after a frame's worth of cycles instead. It
+// then writes the save file if its interval has elapsed.
+func (g *GameBoy) RunFrame() error {
+	frames := g.bus.ppu.Frames()
+
+	for elapsed := 0; elapsed < CyclesPerFrame && g.bus.ppu.Frames() == frames; {
+		n, err := g.step()
+
+		if err != nil {
+			return err
+		}
+
+		elapsed += n
+	}
+
+	if g.saver == nil {
+		return nil
+	}
+
+	return g.saver.MaybeFlush()
+}
+
+// step runs one instruction and returns the time it took in normal-speed
+// T-cycles.
+func (g *GameBoy) step() (int, error) {
+	doubleSpeed := g.cpu.DoubleSpeed()
+	n, err := g.cpu.Step()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to step at PC=0x%04X: %w", g.cpu.PC(), err)
+	}
+
+	return dots(n, doubleSpeed), nil
+}
+
+// Model returns the hardware being emulated.
+func (g *GameBoy) Model() Model {
+	return g.bus.model
+}
+
+// DoubleSpeed reports whether a CGB is running its CPU at 8 MiHz.
+func (g *GameBoy) DoubleSpeed() bool {
+	return g.cpu.DoubleSpeed()
+}
+
+// Cartridge returns the parsed header of the running cartridge, or nil for
+// a ROM too small to have one.
+func (g *GameBoy) Cartridge() *Cartridge {
+	return g.bus.cartridge
+}
+
+// Framebuffer returns the picture as of the last completed frame. On the
+// Super Game Boy it is the SGBWidth x SGBHeight picture with the border;
+// otherwise it is the ScreenWidth x ScreenHeight LCD. The image is reused
+// from frame to frame.
+func (g *GameBoy) Framebuffer() *image.RGBA {
+	if g.bus.sgb != nil {
+		return g.bus.sgb.Frame()
+	}
+
+	return g.bus.ppu.Framebuffer()
+}
+
+// Rumble reports whether the cartridge's rumble motor is on. It is always
+// false for cartridges without one.
+func (g *GameBoy) Rumble() bool {
+	m, ok := g.bus.mapper.(rumbler)
+
+	return ok && m.Rumble()
+}
+
+// Frames returns how many frames have been completed.
+func (g *GameBoy) Frames() uint64 {
+	return g.bus.ppu.Frames()
+}
+
+// Joypad returns the controller, which is controller 1 on the Super Game
+// Boy.
+func (g *GameBoy) Joypad() *Joypad {
+	return g.bus.joypad
+}
+
+// SetPlayer sets the buttons held on controller 1-4. Only the Super Game
+// Boy has more than one; elsewhere every player is controller 1.
+func (g *GameBoy) SetPlayer(player int, pressed Button) {
+	if g.bus.sgb != nil {
+		g.bus.sgb.SetPlayer(player, pressed)
+
+		return
+	}
+
+	g.bus.joypad.SetState(pressed)
+}
+
+// SetAudioSink routes audio to sink at sampleRate; nil stops it.
+func (g *GameBoy) SetAudioSink(sink AudioSink, sampleRate int) {
+	g.cfg.sink, g.cfg.sampleRate = sink, sampleRate
+	g.bus.apu.SetSink(sink, sampleRate)
+}
+
+// FlushAudio hands any buffered samples to the audio sink.
+func (g *GameBoy) FlushAudio() {
+	g.bus.apu.Flush()
+}
+
+// SetLinkPeer plugs a peer into the link port; nil unplugs the cable.
+func (g *GameBoy) SetLinkPeer(peer LinkPeer) {
+	g.cfg.peer = peer
+	g.bus.serial.SetPeer(peer)
+}
+
+// SaveData returns the battery-backed state of the cartridge, or nil if it
+// has no battery.
+func (g *GameBoy) SaveData() []uint8 {
+	return g.bus.SaveData()
+}
+
+// LoadSaveData restores battery-backed state into the cartridge.
+func (g *GameBoy) LoadSaveData(data []uint8) error {
+	return g.bus.LoadSaveData(data)
+}
@@ -0,0 +1,369 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// GAMEBOY MACHINE
+// =============================================================================
+//
+// GameBoy is the public face of the package: New wires the CPU, bus and
+// peripherals from functional options, RunFrame and RunCycles drive it in
+// normal-speed T-cycles, and the accessors reach the picture, audio and
+// input without touching internals.
+
+// loopROM returns a headerless ROM that runs prog from 0x0100 and then
+// spins on JR -2.
+func loopROM(prog ...uint8) []uint8 {
+	rom := make([]uint8, initPC)
+	rom = append(rom, prog...)
+
+	return append(rom, 0x18, 0xFE)
+}
+
+// fakeMapper serves a flat ROM and records writes to the bank registers.
+type fakeMapper struct {
+	rom    []uint8
+	writes map[uint16]uint8
+}
+
+func (m *fakeMapper) ReadROM(addr uint16) uint8 {
+	if int(addr) < len(m.rom) {
+		return m.rom[addr]
+	}
+
+	return 0xFF
+}
+
+func (m *fakeMapper) WriteROM(addr uint16, value uint8) {
+	m.writes[addr] = value
+}
+
+func (m *fakeMapper) ReadRAM(_ uint16) uint8 {
+	return 0xFF
+}
+
+func (m *fakeMapper) WriteRAM(_ uint16, _ uint8) {}
+
+func TestGameBoy_RunFrame(t *testing.T) {
+	g, err := New(loopROM())
+	require.NoError(t, err)
+
+	for frame := uint64(1); frame <= 3; frame++ {
+		require.NoError(t, g.RunFrame())
+		require.Equal(t, frame, g.Frames())
+		require.Equal(t, uint8(0x90), g.bus.ppu.ly, "each frame ends at VBlank")
+	}
+}
+
+func TestGameBoy_RunFrame_LCDOff(t *testing.T) {
+	// LD A,$00 ; LDH (LCDC),A
+	g, err := New(loopROM(0x3E, 0x00, 0xE0, 0x40))
+	require.NoError(t, err)
+
+	require.NoError(t, g.RunFrame())
+	require.NoError(t, g.RunFrame())
+
+	require.Zero(t, g.Frames(), "returns without a frame instead of spinning forever")
+}
+
+func TestGameBoy_RunCycles_KeepsRealTime(t *testing.T) {
+	testCases := []struct {
+		name  string
+		model Model
+		prog  []uint8
+	}{
+		{"normal speed", ModelDMG, nil},
+		// LD A,$01 ; LDH (KEY1),A ; STOP
+		{"double speed", ModelCGB, []uint8{0x3E, 0x01, 0xE0, 0x4D, 0x10, 0x00}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := New(loopROM(tc.prog...), WithModel(tc.model))
+			require.NoError(t, err)
+			require.False(t, g.DoubleSpeed())
+
+			require.NoError(t, g.RunFrame())
+			require.Equal(t, tc.prog != nil, g.DoubleSpeed())
+
+			elapsed, err := g.RunCycles(10 * CyclesPerFrame)
+			require.NoError(t, err)
+
+			require.GreaterOrEqual(t, elapsed, 10*CyclesPerFrame)
+			require.Equal(t, uint64(11), g.Frames())
+		})
+	}
+}
+
+func TestGameBoy_RunCycles_CPUError(t *testing.T) {
+	g, err := New(loopROM(0xD3)) // illegal opcode
+	require.NoError(t, err)
+
+	_, err = g.RunCycles(CyclesPerFrame)
+
+	require.ErrorContains(t, err, "PC=0x")
+	require.ErrorContains(t, err, "illegal opcode")
+}
+
+func TestGameBoy_Reset(t *testing.T) {
+	// LD A,$42
+	g, err := New(loopROM(0x3E, 0x42))
+	require.NoError(t, err)
+
+	require.NoError(t, g.RunFrame())
+	require.Equal(t, uint8(0x42), g.cpu.A())
+
+	require.NoError(t, g.Reset())
+
+	require.Equal(t, initPC, g.cpu.PC())
+	require.Equal(t, uint8(0x01), g.cpu.A(), "DMG post-boot A")
+}
+
+func TestGameBoy_Reset_PowersOnPeripherals(t *testing.T) {
+	rom := newTestROM(cartMBC1RAMBattery, 0x02, 0x02)
+	sink := &captureSink{}
+
+	g, err := New(rom, WithAudioSink(sink, DefaultSampleRate))
+	require.NoError(t, err)
+
+	fresh, err := New(rom)
+	require.NoError(t, err)
+
+	_, err = g.RunCycles(dotsPerLine * 10)
+	require.NoError(t, err)
+
+	// Bank 2, RAM written, and a machine far from its power-on state
+	g.bus.Write(0x2000, 0x02)
+	g.bus.Write(0x0000, 0x0A)
+	g.bus.Write(0xA010, 0x77)
+	g.bus.Write(0xFFFF, 0x1F)
+	g.bus.Write(regIF, 0x1F)
+	g.bus.Write(regTAC, 0x05)
+	g.bus.Write(regTMA, 0x80)
+	g.bus.Write(regSCX, 0x33)
+	g.bus.Write(regLYC, 0x10)
+	g.bus.Write(regOBP0, 0x1B)
+	g.bus.Write(regDMA, 0xC0)
+	g.Joypad().Press(ButtonA)
+	require.True(t, g.bus.dma.active)
+
+	require.NoError(t, g.Reset())
+
+	bank, _ := g.bus.Read(0x4000)
+	require.Equal(t, uint8(0x01), bank, "the bank register is cleared")
+
+	for _, addr := range []uint16{0xFFFF, regIF, regTAC, regTIMA, regTMA, regLY, regSTAT, regSCX, regLYC, regOBP0, regSC, regNR52} {
+		require.Equal(t, readReg(fresh.bus, addr), readReg(g.bus, addr), "register 0x%04X", addr)
+	}
+
+	require.False(t, g.bus.dma.active)
+
+	g.bus.Write(0x0000, 0x0A)
+	value, _ := g.bus.Read(0xA010)
+	require.Equal(t, uint8(0x77), value, "cartridge RAM survives")
+	require.Equal(t, ButtonA, g.Joypad().State(), "held buttons stay held")
+
+	require.NoError(t, g.RunFrame())
+	g.FlushAudio()
+	require.NotEmpty(t, sink.samples, "the audio sink stays plugged in")
+}
+
+func TestGameBoy_BootROM(t *testing.T) {
+	g, err := New(loopROM(), WithBootROM(testBootROM(ModelDMG)))
+	require.NoError(t, err)
+	require.Equal(t, uint16(0x0000), g.cpu.PC())
+
+	_, err = g.RunCycles(CyclesPerFrame)
+	require.NoError(t, err)
+
+	require.False(t, g.bus.bootMapped)
+	require.Equal(t, uint16(0x0100), g.cpu.PC())
+}
+
+func TestGameBoy_BootROMSizeError(t *testing.T) {
+	_, err := New(loopROM(), WithModel(ModelCGB), WithBootROM(testBootROM(ModelDMG)))
+
+	var sizeErr *BootROMSizeError
+	require.ErrorAs(t, err, &sizeErr)
+}
+
+func TestGameBoy_Mapper(t *testing.T) {
+	// LD A,$05 ; LD ($2000),A
+	m := &fakeMapper{rom: loopROM(0x3E, 0x05, 0xEA, 0x00, 0x20), writes: map[uint16]uint8{}}
+	g, err := New(nil, WithMapper(m))
+	require.NoError(t, err)
+
+	require.NoError(t, g.RunFrame())
+
+	require.Equal(t, map[uint16]uint8{0x2000: 0x05}, m.writes)
+}
+
+func TestGameBoy_AudioSink(t *testing.T) {
+	sink := &captureSink{}
+	g, err := New(toneROM(), WithAudioSink(sink, DefaultSampleRate))
+	require.NoError(t, err)
+
+	require.NoError(t, g.RunFrame())
+	g.FlushAudio()
+
+	require.NotEmpty(t, sink.samples)
+}
+
+func TestGameBoy_LinkPeer(t *testing.T) {
+	var out bytes.Buffer
+
+	// LD A,$42 ; LDH (SB),A ; LD A,$81 ; LDH (SC),A
+	g, err := New(loopROM(0x3E, 0x42, 0xE0, 0x01, 0x3E, 0x81, 0xE0, 0x02), WithLinkPeer(NewLoopbackPeer(&out)))
+	require.NoError(t, err)
+
+	require.NoError(t, g.RunFrame())
+
+	require.Equal(t, []uint8{0x42}, out.Bytes())
+}
+
+// batteryROM returns an MBC1+RAM+BATTERY cartridge that spins at its entry
+// point.
+func batteryROM() []uint8 {
+	rom := newTestROM(cartMBC1RAMBattery, 0x01, 0x02)
+	copy(rom[initPC:], []uint8{0x18, 0xFE}) // JR -2
+	fixTestROMChecksums(rom)
+
+	return rom
+}
+
+func TestGameBoy_SaveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+
+	g, err := New(batteryROM(), WithSaveFile(path, 0))
+	require.NoError(t, err)
+	g.bus.Write(0x0000, 0x0A)
+	g.bus.Write(0xA010, 0x77)
+	require.NoError(t, g.RunFrame())
+	require.NoFileExists(t, path, "no timed flush without an interval")
+	require.NoError(t, g.Close())
+
+	restored, err := New(batteryROM(), WithSaveFile(path, 0))
+	require.NoError(t, err)
+	restored.bus.Write(0x0000, 0x0A)
+
+	value, _ := restored.bus.Read(0xA010)
+	require.Equal(t, uint8(0x77), value)
+}
+
+func TestGameBoy_SaveFile_FlushedFromRunFrame(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	clock := newFakeClock()
+
+	g, err := New(batteryROM(), WithClock(clock), WithSaveFile(path, time.Minute))
+	require.NoError(t, err)
+	g.bus.Write(0x0000, 0x0A)
+	g.bus.Write(0xA000, 0x01)
+
+	require.NoError(t, g.RunFrame())
+	require.NoFileExists(t, path, "nothing is written before the interval elapses")
+
+	clock.Advance(time.Minute)
+	require.NoError(t, g.RunFrame())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0x01), data[0])
+}
+
+func TestGameBoy_Framebuffer(t *testing.T) {
+	testCases := []struct {
+		model  Model
+		bounds image.Rectangle
+	}{
+		{ModelDMG, image.Rect(0, 0, ScreenWidth, ScreenHeight)},
+		{ModelCGB, image.Rect(0, 0, ScreenWidth, ScreenHeight)},
+		{ModelSGB, image.Rect(0, 0, SGBWidth, SGBHeight)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.model.String(), func(t *testing.T) {
+			g, err := New(loopROM(), WithModel(tc.model))
+			require.NoError(t, err)
+
+			require.NoError(t, g.RunFrame())
+
+			require.Equal(t, tc.model, g.Model())
+			require.Equal(t, tc.bounds, g.Framebuffer().Bounds())
+		})
+	}
+}
+
+func TestGameBoy_SGBCartridgeSelectsSGB(t *testing.T) {
+	rom := newTestROM(cartROMOnly, 0x00, 0x00)
+	rom[headerSGBFlag] = sgbFlagSupported
+	rom[headerOldLicensee] = oldLicenseeNew
+	fixTestROMChecksums(rom)
+
+	g, err := New(rom)
+	require.NoError(t, err)
+	require.Equal(t, ModelSGB, g.Model())
+	require.Equal(t, image.Rect(0, 0, SGBWidth, SGBHeight), g.Framebuffer().Bounds())
+
+	g, err = New(rom, WithModel(ModelDMG))
+	require.NoError(t, err)
+	require.Equal(t, ModelDMG, g.Model(), "an explicit model wins")
+
+	g, err = New(newTestROM(cartROMOnly, 0x00, 0x00))
+	require.NoError(t, err)
+	require.Equal(t, ModelDMG, g.Model())
+}
+
+func TestGameBoy_CGBCartridgeSelectsCGB(t *testing.T) {
+	for _, flag := range []uint8{cgbFlagSupported, cgbFlagOnly} {
+		rom := newTestROM(cartROMOnly, 0x00, 0x00)
+		rom[headerCGBFlag] = flag
+		rom[headerSGBFlag] = sgbFlagSupported
+		rom[headerOldLicensee] = oldLicenseeNew
+		fixTestROMChecksums(rom)
+
+		g, err := New(rom)
+		require.NoError(t, err)
+		require.Equal(t, ModelCGB, g.Model(), "CGB flag 0x%02X wins over SGB support", flag)
+		require.True(t, g.bus.cgbMode)
+
+		g, err = New(rom, WithModel(ModelSGB))
+		require.NoError(t, err)
+		require.Equal(t, ModelSGB, g.Model(), "an explicit model wins")
+	}
+}
+
+func TestGameBoy_Rumble(t *testing.T) {
+	g, err := New(newTestROM(cartMBC5RumbleRAM, 0x01, 0x03))
+	require.NoError(t, err)
+	require.False(t, g.Rumble())
+
+	g.bus.Write(0x4000, 0x08)
+	require.True(t, g.Rumble())
+
+	g.bus.Write(0x4000, 0x00)
+	require.False(t, g.Rumble())
+
+	g, err = New(loopROM())
+	require.NoError(t, err)
+	g.bus.Write(0x4000, 0x08)
+	require.False(t, g.Rumble(), "no motor without a rumble cartridge")
+}
+
+func TestGameBoy_SetPlayer(t *testing.T) {
+	g, err := New(loopROM())
+	require.NoError(t, err)
+
+	g.SetPlayer(2, ButtonStart)
+
+	require.Equal(t, ButtonStart, g.Joypad().State(), "a DMG has one controller")
+}
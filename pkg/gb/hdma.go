@@ -0,0 +1,135 @@
+package gb
+
+const (
+	hdmaBlockSize   = 0x10
+	hdmaBlockCycles = 32 // 8 M-cycles per block at normal speed, 16 at double speed
+	hdmaHBlankMode  = 0x80
+	hdmaLengthMask  = 0x7F
+	hdmaSourceMask  = 0xFFF0
+	hdmaDestMask    = 0x1FF0
+	hdmaIdle        = 0xFF
+)
+
+// hdma is the CGB VRAM DMA controller behind HDMA1-HDMA5 (0xFF51-0xFF55).
+// HDMA1/2 hold the source and HDMA3/4 the VRAM destination, both 16-byte
+// aligned. Writing the length in blocks minus one to HDMA5 starts either a
+// general-purpose transfer, which copies everything at once, or with bit 7
+// set an HBlank transfer, which copies one block at the start of every
+// HBlank. The CPU is halted while a block is copied; stall hands those
+// cycles to the CPU so they show up in what Step reports.
+//
+// Reference: Pan Docs - CGB Registers, VRAM DMA Transfers
+// https://gbdev.io/pandocs/CGB_Registers.html#lcd-vram-dma-transfers
+type hdma struct {
+	bus *bus
+
+	source    uint16
+	dest      uint16 // offset into the VRAM bank, 0x0000-0x1FF0
+	remaining int    // blocks left to copy
+	active    bool   // an HBlank transfer is running
+	copied    int    // blocks copied since the last stall
+}
+
+func newHDMA(b *bus) *hdma {
+	return &hdma{bus: b}
+}
+
+// Read reports the transfer state through HDMA5: 0xFF once done, otherwise
+// the blocks left minus one, with bit 7 set if the transfer was cancelled.
+// HDMA1-HDMA4 are write-only.
+func (h *hdma) Read(addr uint16) uint8 {
+	if addr != regHDMA5 {
+		return 0xFF
+	}
+
+	if h.remaining == 0 {
+		return hdmaIdle
+	}
+
+	value := uint8(h.remaining-1) & hdmaLengthMask
+
+	if !h.active {
+		value |= hdmaHBlankMode
+	}
+
+	return value
+}
+
+func (h *hdma) Write(addr uint16, value uint8) {
+	switch addr {
+	case regHDMA1:
+		h.source = (h.source&0x00FF | uint16(value)<<8) & hdmaSourceMask
+	case regHDMA2:
+		h.source = (h.source&0xFF00 | uint16(value)) & hdmaSourceMask
+	case regHDMA3:
+		h.dest = (h.dest&0x00FF | uint16(value)<<8) & hdmaDestMask
+	case regHDMA4:
+		h.dest = (h.dest&0xFF00 | uint16(value)) & hdmaDestMask
+	case regHDMA5:
+		h.start(value)
+	}
+}
+
+// start handles a write to HDMA5. Clearing bit 7 while an HBlank transfer
+// runs cancels it instead of starting a general-purpose one.
+func (h *hdma) start(value uint8) {
+	if h.active && value&hdmaHBlankMode == 0 {
+		h.active = false
+
+		return
+	}
+
+	h.remaining = int(value&hdmaLengthMask) + 1
+
+	if value&hdmaHBlankMode != 0 {
+		h.active = true
+
+		return
+	}
+
+	for h.remaining > 0 {
+		h.copyBlock()
+	}
+}
+
+// hblank copies the next block of an HBlank transfer. The PPU calls it when
+// a visible line enters mode 0.
+func (h *hdma) hblank() {
+	if !h.active {
+		return
+	}
+
+	h.copyBlock()
+
+	if h.remaining == 0 {
+		h.active = false
+	}
+}
+
+// copyBlock moves 16 bytes into the selected VRAM bank. Source and
+// destination advance, so HDMA1-HDMA4 need not be rewritten to continue.
+func (h *hdma) copyBlock() {
+	for i := range uint16(hdmaBlockSize) {
+		value, _ := h.bus.read(h.source + i)
+		h.bus.vram[h.bus.vramOffset(tileDataLow+h.dest+i)] = value
+	}
+
+	h.source += hdmaBlockSize
+	h.dest = (h.dest + hdmaBlockSize) & hdmaDestMask
+	h.remaining--
+	h.copied++
+}
+
+// stall returns the CPU cycles spent copying since the last call. A block
+// takes as long in real time at either speed, so twice the CPU cycles in
+// double speed.
+func (h *hdma) stall(doubleSpeed bool) int {
+	cycles := h.copied * hdmaBlockCycles
+	h.copied = 0
+
+	if doubleSpeed {
+		cycles *= 2
+	}
+
+	return cycles
+}
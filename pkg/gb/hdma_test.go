@@ -0,0 +1,133 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// VRAM DMA
+// =============================================================================
+//
+// HDMA1/2 set the source and HDMA3/4 the VRAM destination, both with the
+// low four bits ignored. HDMA5 starts a transfer of (value&0x7F)+1 blocks of
+// 16 bytes: with bit 7 clear all at once (general-purpose DMA), with bit 7
+// set one block per HBlank. The CPU is halted for 8 M-cycles per block at
+// normal speed and 16 at double speed.
+//
+// Reference: Pan Docs - CGB Registers, VRAM DMA Transfers
+// https://gbdev.io/pandocs/CGB_Registers.html#lcd-vram-dma-transfers
+
+// setupHDMA fills 0xC000-0xC0FF with a pattern and points HDMA1-HDMA4 at it
+// and at 0x8100, with junk in the ignored bits.
+func setupHDMA(b *bus) {
+	for i := range uint16(0x100) {
+		b.Write(0xC000+i, uint8(i)^0x5A)
+	}
+
+	b.Write(regHDMA1, 0xC0)
+	b.Write(regHDMA2, 0x0F)
+	b.Write(regHDMA3, 0xE1)
+	b.Write(regHDMA4, 0x0F)
+}
+
+func requireHDMACopied(t *testing.T, b *bus, blocks int) {
+	t.Helper()
+
+	for i := range blocks * hdmaBlockSize {
+		require.Equal(t, uint8(i)^0x5A, b.vram[vramBankSize+0x100+i], "byte %d", i)
+	}
+
+	require.Zero(t, b.vram[vramBankSize+0x100+blocks*hdmaBlockSize], "copied too much")
+}
+
+func TestHDMA_GeneralPurpose(t *testing.T) {
+	testCases := []struct {
+		name        string
+		doubleSpeed bool
+		cycles      int
+	}{
+		{"normal speed", false, 12 + 2*32},
+		{"double speed", true, 12 + 2*64},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// LD A,0x01 ; LDH (0x55),A
+			c := newSpeedTestCPU(t, 0x3E, 0x01, 0xE0, 0x55)
+			c.doubleSpeed = tc.doubleSpeed
+			c.bus.Write(regVBK, 0x01)
+			setupHDMA(c.bus)
+
+			cycles, err := c.Step()
+			require.NoError(t, err)
+			require.Equal(t, 8, cycles)
+
+			cycles, err = c.Step()
+			require.NoError(t, err)
+			require.Equal(t, tc.cycles, cycles, "CPU halted for the transfer")
+
+			requireHDMACopied(t, c.bus, 2)
+			require.Equal(t, uint8(hdmaIdle), readReg(c.bus, regHDMA5))
+		})
+	}
+}
+
+func TestHDMA_HBlank(t *testing.T) {
+	b := newCGBTestBus(t, RenderScanline)
+	b.Write(regVBK, 0x01)
+	setupHDMA(b)
+
+	b.Write(regHDMA5, hdmaHBlankMode|0x02)
+	require.Equal(t, uint8(0x02), readReg(b, regHDMA5), "three blocks to go")
+	requireHDMACopied(t, b, 0)
+
+	// One block at the start of each HBlank
+	b.ppu.Tick(oamScanDots + drawingDots)
+	require.Equal(t, uint8(modeHBlank), b.ppu.mode)
+	requireHDMACopied(t, b, 1)
+	require.Equal(t, uint8(0x01), readReg(b, regHDMA5))
+
+	b.ppu.Tick(dotsPerLine)
+	requireHDMACopied(t, b, 2)
+
+	// Writing bit 7 clear cancels; HDMA5 keeps the remaining length
+	b.Write(regHDMA5, 0x00)
+	require.Equal(t, uint8(0x80), readReg(b, regHDMA5))
+
+	b.ppu.Tick(dotsPerLine * 2)
+	requireHDMACopied(t, b, 2)
+}
+
+func TestHDMA_HBlankStallsTheCPU(t *testing.T) {
+	c := newSpeedTestCPU(t)
+	setupHDMA(c.bus)
+	c.bus.Write(regLCDC, 0x00)
+	c.bus.Write(regLCDC, lcdcEnable)
+	c.bus.Write(regHDMA5, hdmaHBlankMode)
+
+	elapsed := 0
+
+	// NOPs until the first HBlank, where the step also covers the block
+	for c.bus.ppu.mode != modeHBlank {
+		cycles, err := c.Step()
+		require.NoError(t, err)
+
+		elapsed += cycles
+	}
+
+	require.Equal(t, 4+hdmaBlockCycles, c.cycles)
+	require.Equal(t, oamScanDots+drawingDots+hdmaBlockCycles, elapsed)
+	require.Equal(t, uint8(hdmaIdle), readReg(c.bus, regHDMA5))
+}
+
+func TestHDMA_OnlyOnCGB(t *testing.T) {
+	b := newBus()
+	setupHDMA(b)
+	b.Write(regHDMA5, 0x00)
+
+	require.Equal(t, uint8(0xFF), readReg(b, regHDMA5))
+	require.Zero(t, b.vram[0x100])
+}
@@ -0,0 +1,79 @@
+package gb
+
+// Interrupt sources, as bits of IE (0xFFFF) and IF (0xFF0F). Lower bits have
+// higher priority.
+const (
+	interruptVBlank uint8 = 1 << iota
+	interruptSTAT
+	interruptTimer
+	interruptSerial
+	interruptJoypad
+)
+
+const (
+	interruptMask       = 0x1F   // only the five sources above exist
+	interruptVectorBase = 0x0040 // VBlank vector, each next source is +8
+	interruptCycles     = 20     // 2 wait states, push PC, jump to vector
+)
+
+// interrupts holds the IE and IF registers. Peripherals raise requests
+// through request and the CPU services them between instructions.
+type interrupts struct {
+	enable uint8 // IE
+	flag   uint8 // IF
+}
+
+func newInterrupts() *interrupts {
+	return &interrupts{}
+}
+
+func (i *interrupts) request(source uint8) {
+	i.flag |= source & interruptMask
+}
+
+// pending returns the interrupts that are both requested and enabled,
+// regardless of IME.
+func (i *interrupts) pending() uint8 {
+	return i.enable & i.flag & interruptMask
+}
+
+// Read returns IF; it is the only I/O register this device owns.
+func (i *interrupts) Read(_ uint16) uint8 {
+	return i.flag
+}
+
+func (i *interrupts) Write(_ uint16, value uint8) {
+	i.flag = value & interruptMask
+}
+
+// highestPriority returns the lowest set bit of pending together with the
+// address of its handler.
+func highestPriority(pending uint8) (uint8, uint16) {
+	for bit := range uint8(5) {
+		source := uint8(1) << bit
+		if pending&source != 0 {
+			return source, interruptVectorBase + uint16(bit)*8
+		}
+	}
+
+	return 0, 0
+}
+
+// serviceInterrupt dispatches the highest-priority pending interrupt: IME is
+// cleared, the request is acknowledged, PC is pushed and execution continues
+// at the handler.
+func (c *cpu) serviceInterrupt(pending uint8) (int, error) {
+	source, vector := highestPriority(pending)
+
+	c.ime = false
+	c.imeDelay = false
+	c.bus.interrupts.flag &^= source
+
+	if err := c.push(c.PC()); err != nil {
+		return 0, err
+	}
+
+	c.SetPC(vector)
+
+	return interruptCycles, nil
+}
@@ -0,0 +1,147 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// INTERRUPTS
+// =============================================================================
+//
+// IE (0xFFFF) enables sources, IF (0xFF0F) holds requests. When IME is set
+// and IE & IF is non-zero, the CPU pushes PC and jumps to the handler of the
+// lowest set bit, taking 20 cycles:
+//
+//   Bit 0: VBlank  0x40
+//   Bit 1: STAT    0x48
+//   Bit 2: Timer   0x50
+//   Bit 3: Serial  0x58
+//   Bit 4: Joypad  0x60
+//
+// Reference: Pan Docs - Interrupts
+// https://gbdev.io/pandocs/Interrupts.html
+
+func TestInterrupts_IF_UnusedBitsReadAsOne(t *testing.T) {
+	bus := newBus()
+
+	bus.Write(0xFF0F, 0x01)
+	value, _ := bus.Read(0xFF0F)
+
+	require.Equal(t, uint8(0xE1), value)
+}
+
+func TestInterrupts_ServicedInPriorityOrder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pending uint8
+		vector  uint16
+		left    uint8
+	}{
+		{"VBlank", interruptVBlank, 0x0040, 0x00},
+		{"STAT", interruptSTAT, 0x0048, 0x00},
+		{"Timer", interruptTimer, 0x0050, 0x00},
+		{"Serial", interruptSerial, 0x0058, 0x00},
+		{"Joypad", interruptJoypad, 0x0060, 0x00},
+		{"VBlank before Timer", interruptVBlank | interruptTimer, 0x0040, interruptTimer},
+		{"Serial before Joypad", interruptSerial | interruptJoypad, 0x0058, interruptJoypad},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu := newCPU()
+			cpu.SetPC(0x1234)
+			cpu.SetSP(0xFFFE)
+			cpu.ime = true
+			cpu.bus.Write(0xFFFF, 0x1F)
+			cpu.bus.Write(0xFF0F, tc.pending)
+
+			cycles, err := cpu.Step()
+			require.NoError(t, err)
+
+			require.Equal(t, 20, cycles, "dispatch should take 20 cycles")
+			require.Equal(t, tc.vector, cpu.PC())
+			require.Equal(t, uint16(0xFFFC), cpu.SP())
+			require.False(t, cpu.ime, "IME should be cleared on dispatch")
+			require.Equal(t, tc.left, cpu.bus.interrupts.flag, "only the serviced request is acknowledged")
+
+			lowByte, _ := cpu.bus.Read(0xFFFC)
+			highByte, _ := cpu.bus.Read(0xFFFD)
+			require.Equal(t, uint16(0x1234), uint16(highByte)<<8|uint16(lowByte))
+		})
+	}
+}
+
+func TestInterrupts_NotServicedWhenDisabled(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.bus.LoadROM([]uint8{0x00})
+	cpu.bus.Write(0xFF0F, interruptVBlank)
+
+	// IME set but IE clear
+	cpu.ime = true
+	cpu.Step()
+	require.Equal(t, uint16(0x0001), cpu.PC())
+
+	// IE set but IME clear
+	cpu.SetPC(0x0000)
+	cpu.ime = false
+	cpu.bus.Write(0xFFFF, interruptVBlank)
+	cpu.Step()
+	require.Equal(t, uint16(0x0001), cpu.PC())
+}
+
+func TestInterrupts_EI_IsDelayedByOneInstruction(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.bus.Write(0xFFFF, interruptVBlank)
+	cpu.bus.Write(0xFF0F, interruptVBlank)
+
+	// EI ; NOP ; NOP
+	cpu.bus.LoadROM([]uint8{0xFB, 0x00, 0x00})
+
+	cpu.Step()
+	require.False(t, cpu.ime, "IME should not be set right after EI")
+
+	cpu.Step()
+	require.True(t, cpu.ime, "IME should be set after the instruction following EI")
+	require.Equal(t, uint16(0x0002), cpu.PC(), "the instruction after EI runs first")
+
+	cycles, _ := cpu.Step()
+	require.Equal(t, 20, cycles)
+	require.Equal(t, uint16(0x0040), cpu.PC())
+}
+
+func TestInterrupts_DI_CancelsPendingEI(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+
+	// EI ; DI ; NOP
+	cpu.bus.LoadROM([]uint8{0xFB, 0xF3, 0x00})
+
+	cpu.Step()
+	cpu.Step()
+	cpu.Step()
+
+	require.False(t, cpu.ime)
+}
+
+func TestInterrupts_RETI_EnablesImmediately(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.SetSP(0xFFFC)
+	cpu.bus.Write(0xFFFC, 0x00)
+	cpu.bus.Write(0xFFFD, 0x02)
+
+	// RETI
+	cpu.bus.LoadROM([]uint8{0xD9})
+
+	cycles, err := cpu.Step()
+	require.NoError(t, err)
+
+	require.True(t, cpu.ime)
+	require.Equal(t, uint16(0x0200), cpu.PC())
+	require.Equal(t, 16, cycles, "RETI should take 16 cycles")
+}
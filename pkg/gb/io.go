@@ -0,0 +1,138 @@
+package gb
+
+// I/O register addresses (0xFF00-0xFF7F).
+//
+// Reference: Pan Docs - Memory Map, I/O Ranges
+// https://gbdev.io/pandocs/Hardware_Reg_List.html
+const (
+	regP1    = 0xFF00 // joypad
+	regSB    = 0xFF01 // serial data
+	regSC    = 0xFF02 // serial control
+	regDIV   = 0xFF04 // timer divider
+	regTIMA  = 0xFF05 // timer counter
+	regTMA   = 0xFF06 // timer modulo
+	regTAC   = 0xFF07 // timer control
+	regIF    = 0xFF0F // interrupt flag
+	regNR10  = 0xFF10 // channel 1 sweep
+	regNR11  = 0xFF11 // channel 1 duty and length
+	regNR12  = 0xFF12 // channel 1 envelope
+	regNR13  = 0xFF13 // channel 1 period low
+	regNR14  = 0xFF14 // channel 1 period high and trigger
+	regNR21  = 0xFF16 // channel 2 duty and length
+	regNR22  = 0xFF17 // channel 2 envelope
+	regNR23  = 0xFF18 // channel 2 period low
+	regNR24  = 0xFF19 // channel 2 period high and trigger
+	regNR30  = 0xFF1A // channel 3 DAC enable
+	regNR31  = 0xFF1B // channel 3 length
+	regNR32  = 0xFF1C // channel 3 output level
+	regNR33  = 0xFF1D // channel 3 period low
+	regNR34  = 0xFF1E // channel 3 period high and trigger
+	regNR41  = 0xFF20 // channel 4 length
+	regNR42  = 0xFF21 // channel 4 envelope
+	regNR43  = 0xFF22 // channel 4 frequency and randomness
+	regNR44  = 0xFF23 // channel 4 trigger
+	regNR50  = 0xFF24 // master volume and VIN panning
+	regNR51  = 0xFF25 // sound panning
+	regNR52  = 0xFF26 // sound on/off
+	regWave  = 0xFF30 // wave pattern RAM start
+	regWaveE = 0xFF3F // wave pattern RAM end
+	regLCDC  = 0xFF40 // LCD control
+	regSTAT  = 0xFF41 // LCD status
+	regSCY   = 0xFF42
+	regSCX   = 0xFF43
+	regLY    = 0xFF44
+	regLYC   = 0xFF45
+	regDMA   = 0xFF46 // OAM DMA source
+	regBGP   = 0xFF47
+	regOBP0  = 0xFF48
+	regOBP1  = 0xFF49
+	regWY    = 0xFF4A
+	regWX    = 0xFF4B
+	regKEY0  = 0xFF4C // CGB mode select, boot ROM only
+	regKEY1  = 0xFF4D // CGB speed switch
+	regVBK   = 0xFF4F // CGB VRAM bank
+	regBOOT  = 0xFF50 // boot ROM disable
+	regHDMA1 = 0xFF51 // CGB VRAM DMA source high
+	regHDMA2 = 0xFF52 // CGB VRAM DMA source low
+	regHDMA3 = 0xFF53 // CGB VRAM DMA destination high
+	regHDMA4 = 0xFF54 // CGB VRAM DMA destination low
+	regHDMA5 = 0xFF55 // CGB VRAM DMA length/mode/start
+	regRP    = 0xFF56 // CGB infrared port
+	regBCPS  = 0xFF68 // CGB background palette index
+	regBCPD  = 0xFF69 // CGB background palette data
+	regOCPS  = 0xFF6A // CGB object palette index
+	regOCPD  = 0xFF6B // CGB object palette data
+	regOPRI  = 0xFF6C // CGB object priority mode
+	regSVBK  = 0xFF70 // CGB WRAM bank
+
+	ioStart = 0xFF00
+	ioEnd   = 0xFF7F
+	ioSize  = ioEnd - ioStart + 1
+)
+
+// ioUnusedBits holds, per I/O register, the bits that do not exist in
+// hardware and always read back as 1. Registers with no device behind them
+// read as 0xFF regardless.
+//
+// Reference: Pan Docs - Audio Registers and the per-register descriptions
+var ioUnusedBits = [ioSize]uint8{
+	regP1 - ioStart:   0xC0,
+	regSC - ioStart:   0x7E,
+	regTAC - ioStart:  0xF8,
+	regIF - ioStart:   0xE0,
+	regNR10 - ioStart: 0x80,
+	regNR11 - ioStart: 0x3F, // length is write-only
+	regNR13 - ioStart: 0xFF, // write-only
+	regNR14 - ioStart: 0xBF, // only length enable reads back
+	0xFF15 - ioStart:  0xFF,
+	regNR21 - ioStart: 0x3F,
+	regNR23 - ioStart: 0xFF,
+	regNR24 - ioStart: 0xBF,
+	regNR30 - ioStart: 0x7F,
+	regNR31 - ioStart: 0xFF,
+	regNR32 - ioStart: 0x9F,
+	regNR33 - ioStart: 0xFF,
+	regNR34 - ioStart: 0xBF,
+	0xFF1F - ioStart:  0xFF,
+	regNR41 - ioStart: 0xFF,
+	regNR44 - ioStart: 0xBF,
+	regNR52 - ioStart: 0x70,
+	regSTAT - ioStart: 0x80,
+	regKEY1 - ioStart: 0x7E,
+	regVBK - ioStart:  0xFE,
+	regBCPS - ioStart: 0x40,
+	regOCPS - ioStart: 0x40,
+	regOPRI - ioStart: 0xFE,
+	regSVBK - ioStart: 0xF8,
+}
+
+// device is a peripheral that owns one or more I/O registers. The bus hands
+// it the full register address; unused bits are added by the bus on reads.
+type device interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, value uint8)
+}
+
+// register maps the I/O registers first..last (inclusive) to d, replacing
+// whatever device claimed them before.
+func (b *bus) register(first, last uint16, d device) {
+	for addr := first; addr <= last; addr++ {
+		b.io[addr-ioStart] = d
+	}
+}
+
+func (b *bus) readIO(addr uint16) uint8 {
+	d := b.io[addr-ioStart]
+
+	if d == nil {
+		return 0xFF
+	}
+
+	return d.Read(addr) | ioUnusedBits[addr-ioStart]
+}
+
+func (b *bus) writeIO(addr uint16, value uint8) {
+	if d := b.io[addr-ioStart]; d != nil {
+		d.Write(addr, value)
+	}
+}
@@ -0,0 +1,105 @@
+package gb
+
+// Button is one of the eight Game Boy buttons. Buttons are bit flags, so a
+// whole controller state is a Button mask such as ButtonA|ButtonRight.
+type Button uint8
+
+const (
+	ButtonA Button = 1 << iota
+	ButtonB
+	ButtonSelect
+	ButtonStart
+	ButtonRight
+	ButtonLeft
+	ButtonUp
+	ButtonDown
+)
+
+const (
+	p1SelectButtons = 0x20 // P1 bit 5: 0 selects A/B/Select/Start
+	p1SelectDPad    = 0x10 // P1 bit 4: 0 selects the d-pad
+	p1SelectMask    = p1SelectButtons | p1SelectDPad
+	p1Lines         = 0x0F
+)
+
+// Joypad is the input matrix behind P1 (0xFF00). The program selects the
+// button row, the d-pad row or both, and reads the selected lines in the low
+// nibble, where 0 means pressed. A line going from 1 to 0 requests the
+// joypad interrupt, which also ends STOP.
+//
+// Press, Release and SetState are the host side: frontends and scripts call
+// them between frames or between CPU steps.
+//
+// Reference: Pan Docs - Joypad Input
+// https://gbdev.io/pandocs/Joypad_Input.html
+type Joypad struct {
+	interrupts *interrupts
+
+	pressed Button
+	selects uint8 // P1 bits 4-5 as last written
+}
+
+func newJoypad(i *interrupts) *Joypad {
+	return &Joypad{interrupts: i, selects: p1SelectMask}
+}
+
+// Press holds a button down.
+func (j *Joypad) Press(button Button) {
+	j.SetState(j.pressed | button)
+}
+
+// Release lets go of a button.
+func (j *Joypad) Release(button Button) {
+	j.SetState(j.pressed &^ button)
+}
+
+// SetState replaces the whole controller state with the given mask of
+// pressed buttons.
+func (j *Joypad) SetState(pressed Button) {
+	before := j.lines()
+	j.pressed = pressed
+	j.update(before)
+}
+
+// State returns the mask of buttons currently held.
+func (j *Joypad) State() Button {
+	return j.pressed
+}
+
+func (j *Joypad) Read(_ uint16) uint8 {
+	return j.selects | j.lines()
+}
+
+func (j *Joypad) Write(_ uint16, value uint8) {
+	before := j.lines()
+	j.selects = value & p1SelectMask
+	j.update(before)
+}
+
+// lines returns the low nibble of P1.
+func (j *Joypad) lines() uint8 {
+	return selectedLines(j.selects, j.pressed)
+}
+
+// selectedLines computes P1's low nibble for a controller: every selected
+// row pulls the lines of its pressed buttons low.
+func selectedLines(selects uint8, pressed Button) uint8 {
+	low := uint8(0)
+
+	if selects&p1SelectButtons == 0 {
+		low |= uint8(pressed) & p1Lines
+	}
+
+	if selects&p1SelectDPad == 0 {
+		low |= uint8(pressed>>4) & p1Lines
+	}
+
+	return ^low & p1Lines
+}
+
+// update requests the joypad interrupt if any line fell from 1 to 0.
+func (j *Joypad) update(before uint8) {
+	if before&^j.lines() != 0 {
+		j.interrupts.request(interruptJoypad)
+	}
+}
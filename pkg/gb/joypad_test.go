@@ -0,0 +1,117 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// P1 LINE SELECTION
+// =============================================================================
+//
+// P1 bit 5 low selects A/B/Select/Start, bit 4 low selects the d-pad. The
+// low nibble reads the selected lines, 0 meaning pressed. Bits 6-7 read 1.
+//
+// Reference: Pan Docs - Joypad Input
+// https://gbdev.io/pandocs/Joypad_Input.html
+
+func TestJoypad_LineSelection(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pressed Button
+		p1      uint8
+		want    uint8
+	}{
+		{"nothing selected", ButtonA | ButtonRight, 0x30, 0xFF},
+		{"buttons", ButtonA | ButtonStart, 0x10, 0xD6},
+		{"d-pad", ButtonLeft | ButtonDown, 0x20, 0xE5},
+		{"buttons row ignores the d-pad", ButtonUp, 0x10, 0xDF},
+		{"both rows are ANDed", ButtonB | ButtonRight, 0x00, 0xC0 | 0x0C},
+		{"nothing pressed", 0, 0x00, 0xCF},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBus()
+			b.joypad.SetState(tc.pressed)
+			b.Write(regP1, tc.p1)
+
+			value, _ := b.Read(regP1)
+			require.Equal(t, tc.want, value)
+		})
+	}
+}
+
+func TestJoypad_PressAndRelease(t *testing.T) {
+	b := newBus()
+	b.Write(regP1, p1SelectButtons)
+
+	b.joypad.Press(ButtonLeft)
+	b.joypad.Press(ButtonUp)
+	b.joypad.Release(ButtonLeft)
+	require.Equal(t, ButtonUp, b.joypad.State())
+
+	value, _ := b.Read(regP1)
+	require.Equal(t, uint8(0xEB), value)
+}
+
+// =============================================================================
+// JOYPAD INTERRUPT
+// =============================================================================
+//
+// The interrupt is requested when a selected line goes from high to low:
+// pressing a selected button, or selecting a row with a button already held.
+
+func TestJoypad_Interrupt(t *testing.T) {
+	testCases := []struct {
+		name   string
+		held   Button
+		p1     uint8
+		action func(j *Joypad, b *bus)
+		fires  bool
+	}{
+		{"press on a selected row", 0, p1SelectDPad, func(j *Joypad, _ *bus) { j.Press(ButtonA) }, true},
+		{"press on an unselected row", 0, p1SelectButtons, func(j *Joypad, _ *bus) { j.Press(ButtonA) }, false},
+		{"press with nothing selected", 0, p1SelectMask, func(j *Joypad, _ *bus) { j.Press(ButtonStart) }, false},
+		{"release", ButtonA, p1SelectDPad, func(j *Joypad, _ *bus) { j.Release(ButtonA) }, false},
+		{"selecting a held row", 0, p1SelectMask, func(j *Joypad, b *bus) {
+			j.SetState(ButtonDown)
+			b.Write(regP1, p1SelectButtons)
+		}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBus()
+			b.joypad.SetState(tc.held)
+			b.Write(regP1, tc.p1)
+			b.interrupts.flag = 0
+
+			tc.action(b.joypad, b)
+
+			require.Equal(t, tc.fires, b.interrupts.flag&interruptJoypad != 0)
+		})
+	}
+}
+
+func TestJoypad_WakesCPUFromSTOP(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.bus.Write(regP1, p1SelectDPad)
+
+	// STOP ; INC A
+	cpu.bus.LoadROM([]uint8{0x10, 0x00, 0x3C})
+	cpu.SetA(0x00)
+
+	cpu.Step()
+	cpu.Step()
+	require.True(t, cpu.stopped)
+
+	cpu.bus.joypad.Press(ButtonStart)
+	cpu.Step()
+
+	require.False(t, cpu.stopped)
+	require.Equal(t, uint8(0x01), cpu.A())
+}
@@ -0,0 +1,190 @@
+package gb
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// PipePeer is one end of an in-process link cable between two emulators.
+// Both emulators must be stepped in lockstep, from one goroutine or under
+// the caller's own synchronization; the pipe only guards its own state.
+type PipePeer struct {
+	mu    *sync.Mutex
+	other *PipePeer
+
+	out   uint8 // SB as of the last Poll
+	ready bool  // an external-clock transfer was armed at the last Poll
+	inbox uint8
+	full  bool
+}
+
+// NewLinkPipe returns the two ends of an in-process link cable.
+func NewLinkPipe() (*PipePeer, *PipePeer) {
+	mu := &sync.Mutex{}
+	a := &PipePeer{mu: mu}
+	b := &PipePeer{mu: mu, other: a}
+	a.other = b
+
+	return a, b
+}
+
+// Exchange clocks a byte into the other end if it is waiting for one.
+func (p *PipePeer) Exchange(out uint8) uint8 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o := p.other
+
+	if !o.ready {
+		return linkIdle
+	}
+
+	o.inbox = out
+	o.full = true
+	o.ready = false
+
+	return o.out
+}
+
+// Poll delivers a byte clocked in by the other end and records whether this
+// end is ready for the next one.
+func (p *PipePeer) Poll(out uint8, ready bool) (uint8, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.full {
+		p.full = false
+
+		return p.inbox, true
+	}
+
+	p.out = out
+	p.ready = ready
+
+	return 0, false
+}
+
+const (
+	tcpLinkExchange = 'X' // followed by the byte the sender clocked out
+	tcpLinkReply    = 'R' // followed by the byte shifted back
+	tcpLinkQueue    = 16
+)
+
+// TCPPeer links two emulator processes over a TCP connection. Every message
+// is two bytes: a kind and a data byte. The side driving the clock sends an
+// exchange and blocks until the reply arrives; the other side answers from
+// Poll with its SB, or 0xFF when no transfer is armed.
+type TCPPeer struct {
+	conn     net.Conn
+	writeMu  sync.Mutex
+	requests chan uint8
+	replies  chan uint8
+	done     chan struct{}
+}
+
+// NewTCPPeer runs the link protocol over an established connection.
+func NewTCPPeer(conn net.Conn) *TCPPeer {
+	p := &TCPPeer{
+		conn:     conn,
+		requests: make(chan uint8, tcpLinkQueue),
+		replies:  make(chan uint8, tcpLinkQueue),
+		done:     make(chan struct{}),
+	}
+
+	go p.readLoop()
+
+	return p
+}
+
+// DialTCPPeer connects to an emulator waiting in AcceptTCPPeer.
+func DialTCPPeer(addr string) (*TCPPeer, error) {
+	conn, err := net.Dial("tcp", addr)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial link peer: %w", err)
+	}
+
+	return NewTCPPeer(conn), nil
+}
+
+// AcceptTCPPeer waits for one emulator to connect to l.
+func AcceptTCPPeer(l net.Listener) (*TCPPeer, error) {
+	conn, err := l.Accept()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept link peer: %w", err)
+	}
+
+	return NewTCPPeer(conn), nil
+}
+
+// Close hangs up. Both ends then behave like an unplugged cable.
+func (p *TCPPeer) Close() error {
+	return p.conn.Close()
+}
+
+func (p *TCPPeer) Exchange(out uint8) uint8 {
+	if !p.send(tcpLinkExchange, out) {
+		return linkIdle
+	}
+
+	for {
+		select {
+		case in := <-p.replies:
+			return in
+		case <-p.requests:
+			// Both sides drove the clock at once. Neither is listening,
+			// so each answers the other with 0xFF and both shift in 0xFF
+			p.send(tcpLinkReply, linkIdle)
+		case <-p.done:
+			return linkIdle
+		}
+	}
+}
+
+func (p *TCPPeer) Poll(out uint8, ready bool) (uint8, bool) {
+	select {
+	case in := <-p.requests:
+		if !ready {
+			p.send(tcpLinkReply, linkIdle)
+
+			return 0, false
+		}
+
+		p.send(tcpLinkReply, out)
+
+		return in, true
+	default:
+		return 0, false
+	}
+}
+
+func (p *TCPPeer) send(kind, value uint8) bool {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	_, err := p.conn.Write([]byte{kind, value})
+
+	return err == nil
+}
+
+func (p *TCPPeer) readLoop() {
+	defer close(p.done)
+
+	var msg [2]uint8
+
+	for {
+		if _, err := io.ReadFull(p.conn, msg[:]); err != nil {
+			return
+		}
+
+		switch msg[0] {
+		case tcpLinkExchange:
+			p.requests <- msg[1]
+		case tcpLinkReply:
+			p.replies <- msg[1]
+		}
+	}
+}
@@ -0,0 +1,135 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// LINK TRANSPORTS
+// =============================================================================
+//
+// The side on the internal clock drives the transfer; the other side must
+// have armed SC=0x80 with its reply already in SB, otherwise the driver
+// shifts in 0xFF.
+
+// armExternal loads SB and arms an external-clock transfer.
+func armExternal(b *bus, value uint8) {
+	b.Write(regSB, value)
+	b.Write(regSC, 0x80)
+}
+
+// startInternal loads SB and starts an internal-clock transfer.
+func startInternal(b *bus, value uint8) {
+	b.Write(regSB, value)
+	b.Write(regSC, 0x81)
+}
+
+func TestLinkPipe_ExchangesBytes(t *testing.T) {
+	master, slave := newBus(), newBus()
+	a, b := NewLinkPipe()
+	master.serial.SetPeer(a)
+	slave.serial.SetPeer(b)
+
+	armExternal(slave, 0x42)
+	startInternal(master, 0x99)
+
+	// Lockstep: the slave must have polled once to be seen as ready
+	slave.tick(4, false)
+	master.tick(serialByteCycles, false)
+	slave.tick(4, false)
+
+	sb, _ := master.Read(regSB)
+	require.Equal(t, uint8(0x42), sb)
+	require.NotZero(t, master.interrupts.flag&interruptSerial)
+
+	sb, _ = slave.Read(regSB)
+	require.Equal(t, uint8(0x99), sb)
+	require.NotZero(t, slave.interrupts.flag&interruptSerial)
+
+	sc, _ := slave.Read(regSC)
+	require.Zero(t, sc&scTransfer)
+}
+
+func TestLinkPipe_UnreadyPeer(t *testing.T) {
+	master, slave := newBus(), newBus()
+	a, b := NewLinkPipe()
+	master.serial.SetPeer(a)
+	slave.serial.SetPeer(b)
+
+	slave.Write(regSB, 0x42)
+	slave.tick(4, false)
+
+	startInternal(master, 0x99)
+	master.tick(serialByteCycles, false)
+	slave.tick(4, false)
+
+	sb, _ := master.Read(regSB)
+	require.Equal(t, uint8(0xFF), sb)
+
+	sb, _ = slave.Read(regSB)
+	require.Equal(t, uint8(0x42), sb, "a slave without SC bit 7 ignores the clock")
+	require.Zero(t, slave.interrupts.flag&interruptSerial)
+}
+
+func TestTCPPeer_ExchangesBytes(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan *TCPPeer)
+
+	go func() {
+		peer, err := AcceptTCPPeer(l)
+		if err == nil {
+			accepted <- peer
+		}
+	}()
+
+	dialed, err := DialTCPPeer(l.Addr().String())
+	require.NoError(t, err)
+	defer dialed.Close()
+
+	server := <-accepted
+	defer server.Close()
+
+	master, slave := newBus(), newBus()
+	master.serial.SetPeer(dialed)
+	slave.serial.SetPeer(server)
+
+	armExternal(slave, 0x42)
+
+	// The slave emulator runs in its own goroutine, as it would in its own
+	// process
+	slaveDone := make(chan uint8)
+
+	go func() {
+		deadline := time.Now().Add(5 * time.Second)
+
+		for slave.interrupts.flag&interruptSerial == 0 && time.Now().Before(deadline) {
+			slave.tick(4, false)
+		}
+
+		sb, _ := slave.Read(regSB)
+		slaveDone <- sb
+	}()
+
+	startInternal(master, 0x99)
+	master.tick(serialByteCycles, false)
+
+	sb, _ := master.Read(regSB)
+	require.Equal(t, uint8(0x42), sb)
+	require.Equal(t, uint8(0x99), <-slaveDone)
+}
+
+func TestTCPPeer_ClosedConnectionReadsIdle(t *testing.T) {
+	client, server := net.Pipe()
+	peer := NewTCPPeer(client)
+	require.NoError(t, server.Close())
+
+	require.Equal(t, uint8(linkIdle), peer.Exchange(0x12))
+}
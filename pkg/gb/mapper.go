@@ -0,0 +1,205 @@
+package gb
+
+import "fmt"
+
+// Mapper is the memory bank controller of a cartridge. The bus forwards
+// 0x0000-0x7FFF (ROM and bank registers) and 0xA000-0xBFFF (external RAM)
+// to it.
+type Mapper interface {
+	ReadROM(addr uint16) uint8
+	WriteROM(addr uint16, value uint8)
+	ReadRAM(addr uint16) uint8
+	WriteRAM(addr uint16, value uint8)
+}
+
+// Cartridge type header byte values.
+//
+// Reference: Pan Docs - The Cartridge Header, 0147 - Cartridge Type
+// https://gbdev.io/pandocs/The_Cartridge_Header.html#0147--cartridge-type
+const (
+	cartROMOnly        = 0x00
+	cartMBC1           = 0x01
+	cartMBC1RAM        = 0x02
+	cartMBC1RAMBattery = 0x03
+
+	cartMBC2        = 0x05
+	cartMBC2Battery = 0x06
+
+	cartROMRAM        = 0x08
+	cartROMRAMBattery = 0x09
+
+	cartMBC3TimerBattery    = 0x0F
+	cartMBC3TimerRAMBattery = 0x10
+	cartMBC3                = 0x11
+	cartMBC3RAM             = 0x12
+	cartMBC3RAMBattery      = 0x13
+
+	cartMBC5                 = 0x19
+	cartMBC5RAM              = 0x1A
+	cartMBC5RAMBattery       = 0x1B
+	cartMBC5Rumble           = 0x1C
+	cartMBC5RumbleRAM        = 0x1D
+	cartMBC5RumbleRAMBattery = 0x1E
+)
+
+// cartridgeTypeNames names every cartridge type byte listed in the header
+// documentation, supported or not.
+var cartridgeTypeNames = map[uint8]string{
+	0x00: "ROM ONLY",
+	0x01: "MBC1",
+	0x02: "MBC1+RAM",
+	0x03: "MBC1+RAM+BATTERY",
+	0x05: "MBC2",
+	0x06: "MBC2+BATTERY",
+	0x08: "ROM+RAM",
+	0x09: "ROM+RAM+BATTERY",
+	0x0B: "MMM01",
+	0x0C: "MMM01+RAM",
+	0x0D: "MMM01+RAM+BATTERY",
+	0x0F: "MBC3+TIMER+BATTERY",
+	0x10: "MBC3+TIMER+RAM+BATTERY",
+	0x11: "MBC3",
+	0x12: "MBC3+RAM",
+	0x13: "MBC3+RAM+BATTERY",
+	0x19: "MBC5",
+	0x1A: "MBC5+RAM",
+	0x1B: "MBC5+RAM+BATTERY",
+	0x1C: "MBC5+RUMBLE",
+	0x1D: "MBC5+RUMBLE+RAM",
+	0x1E: "MBC5+RUMBLE+RAM+BATTERY",
+	0x20: "MBC6",
+	0x22: "MBC7+SENSOR+RUMBLE+RAM+BATTERY",
+	0xFC: "POCKET CAMERA",
+	0xFD: "BANDAI TAMA5",
+	0xFE: "HuC3",
+	0xFF: "HuC1+RAM+BATTERY",
+}
+
+// UnsupportedCartridgeTypeError is returned by LoadROM when the cartridge
+// type byte asks for a mapper that is not emulated.
+type UnsupportedCartridgeTypeError struct {
+	Type uint8
+}
+
+func (e *UnsupportedCartridgeTypeError) Error() string {
+	name, ok := cartridgeTypeNames[e.Type]
+
+	if !ok {
+		name = "unknown"
+	}
+
+	return fmt.Sprintf("unsupported cartridge type 0x%02X (%s)", e.Type, name)
+}
+
+// newMapper selects the memory bank controller from the cartridge type byte.
+// clock drives the real-time clock of cartridges that have one.
+func newMapper(cart *Cartridge, clock Clock) (Mapper, error) {
+	switch cart.Type {
+	case cartROMOnly:
+		return newROMOnly(cart.ROM()), nil
+	case cartMBC1, cartMBC1RAM, cartMBC1RAMBattery:
+		return newMBC1(cart), nil
+	case cartMBC3TimerBattery, cartMBC3TimerRAMBattery:
+		return newMBC3(cart, clock, true), nil
+	case cartMBC3, cartMBC3RAM, cartMBC3RAMBattery:
+		return newMBC3(cart, clock, false), nil
+	case cartMBC2, cartMBC2Battery:
+		return newMBC2(cart), nil
+	case cartROMRAM, cartROMRAMBattery:
+		return newROMRAM(cart), nil
+	case cartMBC5, cartMBC5RAM, cartMBC5RAMBattery:
+		return newMBC5(cart, false), nil
+	case cartMBC5Rumble, cartMBC5RumbleRAM, cartMBC5RumbleRAMBattery:
+		return newMBC5(cart, true), nil
+	default:
+		return nil, &UnsupportedCartridgeTypeError{Type: cart.Type}
+	}
+}
+
+// romOnly is a cartridge without a memory bank controller: 32 KiB of ROM
+// mapped directly, writes ignored.
+type romOnly struct {
+	rom []uint8
+}
+
+func newROMOnly(rom []uint8) *romOnly {
+	return &romOnly{rom: rom}
+}
+
+func (m *romOnly) ReadROM(addr uint16) uint8 {
+	if int(addr) >= len(m.rom) {
+		return 0xFF
+	}
+
+	return m.rom[addr]
+}
+
+func (m *romOnly) WriteROM(_ uint16, _ uint8) {}
+
+func (m *romOnly) ReadRAM(_ uint16) uint8 {
+	return 0xFF
+}
+
+func (m *romOnly) WriteRAM(_ uint16, _ uint8) {}
+
+// romRAM is a cartridge without a memory bank controller but with up to
+// 8 KiB of RAM that is always enabled.
+type romRAM struct {
+	romOnly
+
+	ram []uint8
+}
+
+func newROMRAM(cart *Cartridge) *romRAM {
+	return &romRAM{
+		romOnly: romOnly{rom: cart.ROM()},
+		ram:     make([]uint8, max(cart.RAMSize, ramBankSize)),
+	}
+}
+
+func (m *romRAM) ReadRAM(addr uint16) uint8 {
+	return readBankedRAM(m.ram, 0, addr)
+}
+
+func (m *romRAM) WriteRAM(addr uint16, value uint8) {
+	writeBankedRAM(m.ram, 0, addr, value)
+}
+
+// romBankCount returns the number of 16 KiB banks in a ROM image, at least 2.
+func romBankCount(rom []uint8) int {
+	return max(len(rom)/romBankSize, 2)
+}
+
+// readBankedROM reads addr (0x0000-0x3FFF relative) from the given ROM bank,
+// wrapping the bank number to the banks actually present.
+func readBankedROM(rom []uint8, bank int, addr uint16) uint8 {
+	offset := (bank%romBankCount(rom))*romBankSize + int(addr&0x3FFF)
+
+	if offset >= len(rom) {
+		return 0xFF
+	}
+
+	return rom[offset]
+}
+
+// readBankedRAM reads addr (0xA000-0xBFFF) from the given 8 KiB RAM bank,
+// wrapping the bank number to the RAM actually present.
+func readBankedRAM(ram []uint8, bank int, addr uint16) uint8 {
+	if len(ram) == 0 {
+		return 0xFF
+	}
+
+	return ram[ramOffset(ram, bank, addr)]
+}
+
+func writeBankedRAM(ram []uint8, bank int, addr uint16, value uint8) {
+	if len(ram) == 0 {
+		return
+	}
+
+	ram[ramOffset(ram, bank, addr)] = value
+}
+
+func ramOffset(ram []uint8, bank int, addr uint16) int {
+	return (bank*ramBankSize + int(addr-0xA000)) % len(ram)
+}
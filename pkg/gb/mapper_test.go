@@ -0,0 +1,53 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapper_SelectedFromCartridgeType(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cartType uint8
+		expected Mapper
+	}{
+		{"ROM ONLY", cartROMOnly, &romOnly{}},
+		{"MBC1+RAM+BATTERY", cartMBC1RAMBattery, &mbc1{}},
+		{"MBC2", cartMBC2, &mbc2{}},
+		{"ROM+RAM", cartROMRAM, &romRAM{}},
+		{"MBC3+TIMER+RAM+BATTERY", cartMBC3TimerRAMBattery, &mbc3{}},
+		{"MBC5+RUMBLE", cartMBC5Rumble, &mbc5{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bus := newBus()
+			require.NoError(t, bus.LoadROM(newTestROM(tc.cartType, 0x00, 0x00)))
+
+			require.IsType(t, tc.expected, bus.mapper)
+		})
+	}
+}
+
+func TestMapper_ROMRAM(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartROMRAMBattery, 0x00, 0x02)))
+
+	bus.Write(0xA000, 0x5A)
+	value, _ := bus.Read(0xA000)
+
+	require.Equal(t, uint8(0x5A), value, "ROM+RAM needs no enable sequence")
+}
+
+func TestMapper_UnsupportedType_NamesIt(t *testing.T) {
+	bus := newBus()
+
+	err := bus.LoadROM(newTestROM(0xFC, 0x00, 0x00))
+
+	var typeErr *UnsupportedCartridgeTypeError
+	require.ErrorAs(t, err, &typeErr)
+	require.Equal(t, uint8(0xFC), typeErr.Type)
+	require.Contains(t, err.Error(), "POCKET CAMERA")
+}
@@ -0,0 +1,127 @@
+package gb
+
+import "bytes"
+
+const (
+	mbc1RAMEnableValue = 0x0A
+	mbc1Bank1Mask      = 0x1F
+	mbc1MBank1Mask     = 0x0F
+	mbc1Bank2Mask      = 0x03
+	mbc1MulticartSize  = 0x100000 // MBC1M carts are always 1 MiB
+	mbc1MulticartBank  = 0x10     // first bank of the second game
+	logoStart          = 0x0104
+	logoEnd            = 0x0134
+)
+
+// mbc1 implements the MBC1 controller: a 5-bit ROM bank register (BANK1), a
+// 2-bit secondary register (BANK2) that extends the ROM bank or selects the
+// RAM bank, and a mode bit that decides whether BANK2 also applies to
+// 0x0000-0x3FFF and RAM.
+//
+// Reference: Pan Docs - MBC1
+// https://gbdev.io/pandocs/MBC1.html
+type mbc1 struct {
+	rom []uint8
+	ram []uint8
+
+	ramEnabled bool
+	bank1      uint8
+	bank2      uint8
+	mode       uint8
+
+	// MBC1M multicarts wire BANK2 to ROM address lines 18-19 instead of
+	// 19-20, so BANK1 only contributes 4 bits.
+	multicart bool
+}
+
+func newMBC1(cart *Cartridge) *mbc1 {
+	return &mbc1{
+		rom:       cart.ROM(),
+		ram:       make([]uint8, cart.RAMSize),
+		bank1:     1,
+		multicart: isMBC1Multicart(cart.ROM()),
+	}
+}
+
+// isMBC1Multicart detects MBC1M collections by the Nintendo logo of a second
+// game header in bank 0x10.
+func isMBC1Multicart(rom []uint8) bool {
+	if len(rom) != mbc1MulticartSize {
+		return false
+	}
+
+	second := mbc1MulticartBank * romBankSize
+
+	return bytes.Equal(rom[logoStart:logoEnd], rom[second+logoStart:second+logoEnd])
+}
+
+func (m *mbc1) bank2Shift() uint8 {
+	if m.multicart {
+		return 4
+	}
+
+	return 5
+}
+
+func (m *mbc1) ReadROM(addr uint16) uint8 {
+	if addr < romBankSize {
+		bank := 0
+
+		if m.mode == 1 {
+			bank = int(m.bank2) << m.bank2Shift()
+		}
+
+		return readBankedROM(m.rom, bank, addr)
+	}
+
+	bank1 := m.bank1
+
+	if m.multicart {
+		bank1 &= mbc1MBank1Mask
+	}
+
+	return readBankedROM(m.rom, int(m.bank2)<<m.bank2Shift()|int(bank1), addr)
+}
+
+func (m *mbc1) WriteROM(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = value&0x0F == mbc1RAMEnableValue
+	case addr < 0x4000:
+		// BANK1 can never be 0: the comparison is done on all 5 bits, so
+		// banks 0x20/0x40/0x60 are unreachable in mode 0.
+		m.bank1 = value & mbc1Bank1Mask
+
+		if m.bank1 == 0 {
+			m.bank1 = 1
+		}
+	case addr < 0x6000:
+		m.bank2 = value & mbc1Bank2Mask
+	default:
+		m.mode = value & 0x01
+	}
+}
+
+func (m *mbc1) ramBank() int {
+	if m.mode == 1 {
+		return int(m.bank2)
+	}
+
+	return 0
+}
+
+func (m *mbc1) ReadRAM(addr uint16) uint8 {
+	if !m.ramEnabled {
+		return 0xFF
+	}
+
+	return readBankedRAM(m.ram, m.ramBank(), addr)
+}
+
+func (m *mbc1) WriteRAM(addr uint16, value uint8) {
+	if !m.ramEnabled {
+		return
+	}
+
+	writeBankedRAM(m.ram, m.ramBank(), addr, value)
+}
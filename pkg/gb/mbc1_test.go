@@ -0,0 +1,125 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// MBC1
+// =============================================================================
+//
+//   0000-1FFF: RAM enable (0x0A in the low nibble)
+//   2000-3FFF: BANK1, 5-bit ROM bank (0 is treated as 1)
+//   4000-5FFF: BANK2, 2-bit RAM bank or upper ROM bank bits
+//   6000-7FFF: banking mode
+//
+// newTestROM writes the bank number into the first byte of every bank, so
+// reading 0x4000 tells which bank is mapped.
+//
+// Reference: Pan Docs - MBC1
+// https://gbdev.io/pandocs/MBC1.html
+
+func newMBC1TestBus(t *testing.T, romSizeCode, ramSizeCode uint8) *bus {
+	t.Helper()
+
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC1RAMBattery, romSizeCode, ramSizeCode)))
+
+	return bus
+}
+
+func TestMBC1_ROMBanking(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bank1    uint8
+		expected uint8
+	}{
+		{"default bank 1", 0x01, 0x01},
+		{"bank 0 maps to 1", 0x00, 0x01},
+		{"bank 5", 0x05, 0x05},
+		{"upper bits ignored", 0xE3, 0x03},
+		{"wraps to ROM size", 0x1F, 0x07},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bus := newMBC1TestBus(t, 0x02, 0x00) // 128 KiB, 8 banks
+
+			bus.Write(0x2000, tc.bank1)
+			value, _ := bus.Read(0x4000)
+
+			require.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestMBC1_LargeROM_UsesBank2(t *testing.T) {
+	bus := newMBC1TestBus(t, 0x06, 0x00) // 2 MiB, 128 banks
+
+	bus.Write(0x2000, 0x05)
+	bus.Write(0x4000, 0x02)
+
+	value, _ := bus.Read(0x4000)
+	require.Equal(t, uint8(0x45), value, "BANK2 should supply ROM bank bits 5-6")
+
+	value, _ = bus.Read(0x0000)
+	require.Equal(t, uint8(0x00), value, "mode 0 keeps bank 0 at 0x0000")
+
+	bus.Write(0x6000, 0x01)
+	value, _ = bus.Read(0x0000)
+	require.Equal(t, uint8(0x40), value, "mode 1 applies BANK2 to 0x0000-0x3FFF")
+
+	// 0x20 can't be selected directly: BANK1=0 becomes 1
+	bus.Write(0x2000, 0x00)
+	bus.Write(0x4000, 0x01)
+	value, _ = bus.Read(0x4000)
+	require.Equal(t, uint8(0x21), value)
+}
+
+func TestMBC1_RAM(t *testing.T) {
+	bus := newMBC1TestBus(t, 0x01, 0x03) // 32 KiB RAM, 4 banks
+
+	bus.Write(0xA000, 0x12)
+	value, _ := bus.Read(0xA000)
+	require.Equal(t, uint8(0xFF), value, "disabled RAM reads 0xFF and ignores writes")
+
+	bus.Write(0x0000, 0x0A)
+	bus.Write(0xA000, 0x12)
+	bus.Write(0x6000, 0x01)
+	bus.Write(0x4000, 0x02)
+	bus.Write(0xA000, 0x34)
+
+	value, _ = bus.Read(0xA000)
+	require.Equal(t, uint8(0x34), value, "mode 1 selects RAM bank 2")
+
+	bus.Write(0x6000, 0x00)
+	value, _ = bus.Read(0xA000)
+	require.Equal(t, uint8(0x12), value, "mode 0 always uses RAM bank 0")
+
+	bus.Write(0x0000, 0x00)
+	value, _ = bus.Read(0xA000)
+	require.Equal(t, uint8(0xFF), value)
+}
+
+func TestMBC1_Multicart(t *testing.T) {
+	rom := newTestROM(cartMBC1, 0x05, 0x00) // 1 MiB
+	copy(rom[logoStart:logoEnd], []uint8{0xCE, 0xED, 0x66, 0x66})
+	copy(rom[mbc1MulticartBank*romBankSize+logoStart:], []uint8{0xCE, 0xED, 0x66, 0x66})
+	fixTestROMChecksums(rom)
+
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(rom))
+
+	bus.Write(0x2000, 0x12) // only 4 bits used: bank 2
+	bus.Write(0x4000, 0x01) // BANK2 is shifted by 4: bank 0x10
+
+	value, _ := bus.Read(0x4000)
+	require.Equal(t, uint8(0x12), value)
+
+	bus.Write(0x6000, 0x01)
+	value, _ = bus.Read(0x0000)
+	require.Equal(t, uint8(0x10), value, "mode 1 maps the second game's bank 0")
+}
@@ -0,0 +1,73 @@
+package gb
+
+const (
+	mbc2RAMSize        = 512
+	mbc2RAMEnableValue = 0x0A
+	mbc2ROMBankMask    = 0x0F
+	mbc2RegisterSelect = 0x0100 // address bit 8 picks RAM enable or ROM bank
+	mbc2NibbleUnused   = 0xF0   // only the low 4 bits of each RAM cell exist
+)
+
+// mbc2 implements the MBC2 controller: a 4-bit ROM bank and 512 half-bytes
+// of built-in RAM, echoed across the whole 0xA000-0xBFFF window. Both
+// registers live in 0x0000-0x3FFF and address bit 8 tells them apart.
+//
+// Reference: Pan Docs - MBC2
+// https://gbdev.io/pandocs/MBC2.html
+type mbc2 struct {
+	rom []uint8
+	ram []uint8
+
+	ramEnabled bool
+	romBank    uint8
+}
+
+func newMBC2(cart *Cartridge) *mbc2 {
+	return &mbc2{
+		rom:     cart.ROM(),
+		ram:     make([]uint8, mbc2RAMSize),
+		romBank: 1,
+	}
+}
+
+func (m *mbc2) ReadROM(addr uint16) uint8 {
+	if addr < romBankSize {
+		return readBankedROM(m.rom, 0, addr)
+	}
+
+	return readBankedROM(m.rom, int(m.romBank), addr)
+}
+
+func (m *mbc2) WriteROM(addr uint16, value uint8) {
+	if addr >= romBankSize {
+		return
+	}
+
+	if addr&mbc2RegisterSelect == 0 {
+		m.ramEnabled = value&0x0F == mbc2RAMEnableValue
+
+		return
+	}
+
+	m.romBank = value & mbc2ROMBankMask
+
+	if m.romBank == 0 {
+		m.romBank = 1
+	}
+}
+
+func (m *mbc2) ReadRAM(addr uint16) uint8 {
+	if !m.ramEnabled {
+		return 0xFF
+	}
+
+	return m.ram[int(addr)%mbc2RAMSize] | mbc2NibbleUnused
+}
+
+func (m *mbc2) WriteRAM(addr uint16, value uint8) {
+	if !m.ramEnabled {
+		return
+	}
+
+	m.ram[int(addr)%mbc2RAMSize] = value &^ mbc2NibbleUnused
+}
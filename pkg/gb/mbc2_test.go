@@ -0,0 +1,49 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// MBC2
+// =============================================================================
+//
+//   0000-3FFF: RAM enable when address bit 8 is 0, ROM bank when it is 1
+//   A000-A1FF: 512 x 4-bit built-in RAM, echoed up to 0xBFFF
+//
+// Reference: Pan Docs - MBC2
+// https://gbdev.io/pandocs/MBC2.html
+
+func TestMBC2_RegisterSelectByAddressBit8(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC2Battery, 0x03, 0x00))) // 16 banks
+
+	bus.Write(0x0000, 0x07) // bit 8 clear: RAM enable register
+	value, _ := bus.Read(0x4000)
+	require.Equal(t, uint8(0x01), value, "a bit-8-clear write must not switch banks")
+
+	bus.Write(0x2100, 0x07) // bit 8 set: ROM bank register
+	value, _ = bus.Read(0x4000)
+	require.Equal(t, uint8(0x07), value)
+
+	bus.Write(0x0100, 0x00)
+	value, _ = bus.Read(0x4000)
+	require.Equal(t, uint8(0x01), value, "bank 0 maps to 1")
+}
+
+func TestMBC2_HalfByteRAM(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC2Battery, 0x01, 0x00)))
+
+	bus.Write(0x0000, 0x0A)
+	bus.Write(0xA005, 0xAB)
+
+	value, _ := bus.Read(0xA005)
+	require.Equal(t, uint8(0xFB), value, "only the low nibble is stored, the upper reads as 1s")
+
+	value, _ = bus.Read(0xA205)
+	require.Equal(t, uint8(0xFB), value, "RAM is echoed every 512 bytes")
+}
@@ -0,0 +1,235 @@
+package gb
+
+import "time"
+
+const (
+	mbc3RAMEnableValue = 0x0A
+	mbc3ROMBankMask    = 0x7F
+	mbc3RAMBankMask    = 0x03
+	mbc3RTCSeconds     = 0x08
+	mbc3RTCMinutes     = 0x09
+	mbc3RTCHours       = 0x0A
+	mbc3RTCDayLow      = 0x0B
+	mbc3RTCDayHigh     = 0x0C
+	rtcDayHighBit      = 0x01 // DH bit 0: day counter bit 8
+	rtcHaltBit         = 0x40 // DH bit 6: clock stopped
+	rtcDayCarryBit     = 0x80 // DH bit 7: day counter overflowed past 511
+	rtcDays            = 512
+	secondsPerDay      = 24 * 60 * 60
+)
+
+// Clock is the time source of a cartridge real-time clock. Tests inject a
+// fake to advance time deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// frozenClock never moves, so the RTC only changes when the game writes it.
+// Headless runs use it to stay reproducible.
+type frozenClock struct{}
+
+func (frozenClock) Now() time.Time {
+	return time.Unix(0, 0)
+}
+
+// rtc is the MBC3 real-time clock: seconds, minutes, hours and a 9-bit day
+// counter with halt and carry flags. The live counters advance with the
+// Clock; the CPU only ever sees the values copied by the last latch.
+type rtc struct {
+	clock Clock
+	last  time.Time // time the live counters were last brought up to date
+
+	seconds  uint8
+	minutes  uint8
+	hours    uint8
+	days     uint16
+	halted   bool
+	dayCarry bool
+
+	latched [5]uint8 // S, M, H, DL, DH as seen at the last latch
+}
+
+func newRTC(clock Clock) *rtc {
+	return &rtc{clock: clock, last: clock.Now()}
+}
+
+// update advances the live counters by the whole seconds elapsed since the
+// last update, unless the clock is halted.
+func (r *rtc) update() {
+	now := r.clock.Now()
+
+	if r.halted {
+		r.last = now
+
+		return
+	}
+
+	elapsed := int64(now.Sub(r.last) / time.Second)
+
+	if elapsed <= 0 {
+		return
+	}
+
+	r.last = r.last.Add(time.Duration(elapsed) * time.Second)
+	r.advance(elapsed)
+}
+
+func (r *rtc) advance(seconds int64) {
+	total := int64(r.seconds) + seconds
+	r.seconds = uint8(total % 60)
+
+	total = int64(r.minutes) + total/60
+	r.minutes = uint8(total % 60)
+
+	total = int64(r.hours) + total/60
+	r.hours = uint8(total % 24)
+
+	total = int64(r.days) + total/24
+
+	if total >= rtcDays {
+		r.dayCarry = true
+	}
+
+	r.days = uint16(total % rtcDays)
+}
+
+func (r *rtc) dayHigh() uint8 {
+	value := uint8(r.days>>8) & rtcDayHighBit
+
+	if r.halted {
+		value |= rtcHaltBit
+	}
+
+	if r.dayCarry {
+		value |= rtcDayCarryBit
+	}
+
+	return value
+}
+
+func (r *rtc) latch() {
+	r.update()
+	r.latched = [5]uint8{r.seconds, r.minutes, r.hours, uint8(r.days), r.dayHigh()}
+}
+
+func (r *rtc) Read(register uint8) uint8 {
+	return r.latched[register-mbc3RTCSeconds]
+}
+
+func (r *rtc) Write(register uint8, value uint8) {
+	r.update()
+
+	switch register {
+	case mbc3RTCSeconds:
+		r.seconds = value & 0x3F
+		r.last = r.clock.Now() // writing seconds resets the sub-second divider
+	case mbc3RTCMinutes:
+		r.minutes = value & 0x3F
+	case mbc3RTCHours:
+		r.hours = value & 0x1F
+	case mbc3RTCDayLow:
+		r.days = r.days&0x100 | uint16(value)
+	case mbc3RTCDayHigh:
+		r.days = r.days&0xFF | uint16(value&rtcDayHighBit)<<8
+		r.halted = value&rtcHaltBit != 0
+		r.dayCarry = value&rtcDayCarryBit != 0
+	}
+
+	r.latched[register-mbc3RTCSeconds] = value
+}
+
+// mbc3 implements the MBC3 controller: a 7-bit ROM bank, four 8 KiB RAM
+// banks and, on timer cartridges, the RTC registers mapped into the RAM
+// window when 0x08-0x0C is selected.
+//
+// Reference: Pan Docs - MBC3
+// https://gbdev.io/pandocs/MBC3.html
+type mbc3 struct {
+	rom []uint8
+	ram []uint8
+	rtc *rtc // nil without a timer
+
+	ramEnabled bool
+	romBank    uint8
+	ramSelect  uint8 // RAM bank 0-3 or RTC register 0x08-0x0C
+	latchValue uint8 // last value written to 0x6000-0x7FFF
+}
+
+func newMBC3(cart *Cartridge, clock Clock, timer bool) *mbc3 {
+	m := &mbc3{
+		rom:        cart.ROM(),
+		ram:        make([]uint8, cart.RAMSize),
+		romBank:    1,
+		latchValue: 0xFF,
+	}
+
+	if timer {
+		m.rtc = newRTC(clock)
+	}
+
+	return m
+}
+
+func (m *mbc3) ReadROM(addr uint16) uint8 {
+	if addr < romBankSize {
+		return readBankedROM(m.rom, 0, addr)
+	}
+
+	return readBankedROM(m.rom, int(m.romBank), addr)
+}
+
+func (m *mbc3) WriteROM(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = value&0x0F == mbc3RAMEnableValue
+	case addr < 0x4000:
+		m.romBank = value & mbc3ROMBankMask
+
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr < 0x6000:
+		m.ramSelect = value
+	default:
+		// Writing 0x00 then 0x01 copies the live clock into the latched
+		// registers.
+		if m.rtc != nil && m.latchValue == 0x00 && value == 0x01 {
+			m.rtc.latch()
+		}
+
+		m.latchValue = value
+	}
+}
+
+func (m *mbc3) rtcSelected() bool {
+	return m.rtc != nil && m.ramSelect >= mbc3RTCSeconds && m.ramSelect <= mbc3RTCDayHigh
+}
+
+func (m *mbc3) ReadRAM(addr uint16) uint8 {
+	switch {
+	case !m.ramEnabled:
+		return 0xFF
+	case m.rtcSelected():
+		return m.rtc.Read(m.ramSelect)
+	case m.ramSelect <= mbc3RAMBankMask:
+		return readBankedRAM(m.ram, int(m.ramSelect), addr)
+	default:
+		return 0xFF
+	}
+}
+
+func (m *mbc3) WriteRAM(addr uint16, value uint8) {
+	switch {
+	case !m.ramEnabled:
+	case m.rtcSelected():
+		m.rtc.Write(m.ramSelect, value)
+	case m.ramSelect <= mbc3RAMBankMask:
+		writeBankedRAM(m.ram, int(m.ramSelect), addr, value)
+	}
+}
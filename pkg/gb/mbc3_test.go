@@ -0,0 +1,153 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock that only moves when the test advances it.
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newMBC3TestBus(t *testing.T, cartType uint8) (*bus, *fakeClock) {
+	t.Helper()
+
+	clock := newFakeClock()
+	bus := newBus()
+	bus.clock = clock
+	require.NoError(t, bus.LoadROM(newTestROM(cartType, 0x03, 0x03)))
+
+	return bus, clock
+}
+
+// readRTC latches the clock and reads the S, M, H, DL and DH registers.
+func readRTC(bus *bus) [5]uint8 {
+	var registers [5]uint8
+
+	bus.Write(0x6000, 0x00)
+	bus.Write(0x6000, 0x01)
+
+	for i := range registers {
+		bus.Write(0x4000, mbc3RTCSeconds+uint8(i))
+		registers[i], _ = bus.Read(0xA000)
+	}
+
+	return registers
+}
+
+// =============================================================================
+// MBC3
+// =============================================================================
+//
+//   0000-1FFF: RAM and RTC enable
+//   2000-3FFF: 7-bit ROM bank (0 is treated as 1)
+//   4000-5FFF: RAM bank 0-3, or RTC register 0x08-0x0C
+//   6000-7FFF: latch clock data (write 0x00 then 0x01)
+//
+// Reference: Pan Docs - MBC3
+// https://gbdev.io/pandocs/MBC3.html
+
+func TestMBC3_ROMAndRAMBanking(t *testing.T) {
+	bus, _ := newMBC3TestBus(t, cartMBC3RAMBattery)
+
+	bus.Write(0x2000, 0x0D)
+	value, _ := bus.Read(0x4000)
+	require.Equal(t, uint8(0x0D), value)
+
+	bus.Write(0x2000, 0x00)
+	value, _ = bus.Read(0x4000)
+	require.Equal(t, uint8(0x01), value, "bank 0 maps to 1")
+
+	bus.Write(0x0000, 0x0A)
+	bus.Write(0x4000, 0x03)
+	bus.Write(0xA123, 0x99)
+	bus.Write(0x4000, 0x00)
+	value, _ = bus.Read(0xA123)
+	require.Equal(t, uint8(0x00), value)
+
+	bus.Write(0x4000, 0x03)
+	value, _ = bus.Read(0xA123)
+	require.Equal(t, uint8(0x99), value)
+}
+
+func TestMBC3_RTC_LatchFreezesReadValues(t *testing.T) {
+	bus, clock := newMBC3TestBus(t, cartMBC3TimerRAMBattery)
+	bus.Write(0x0000, 0x0A)
+
+	clock.Advance(1*time.Hour + 2*time.Minute + 3*time.Second)
+	require.Equal(t, [5]uint8{3, 2, 1, 0, 0}, readRTC(bus))
+
+	// Time moves on but nothing changes until the next latch
+	clock.Advance(10 * time.Second)
+	bus.Write(0x4000, mbc3RTCSeconds)
+	value, _ := bus.Read(0xA000)
+	require.Equal(t, uint8(3), value)
+
+	require.Equal(t, [5]uint8{13, 2, 1, 0, 0}, readRTC(bus))
+}
+
+func TestMBC3_RTC_LatchNeedsZeroThenOne(t *testing.T) {
+	bus, clock := newMBC3TestBus(t, cartMBC3TimerRAMBattery)
+	bus.Write(0x0000, 0x0A)
+	bus.Write(0x4000, mbc3RTCSeconds)
+
+	clock.Advance(5 * time.Second)
+	bus.Write(0x6000, 0x01)
+	value, _ := bus.Read(0xA000)
+	require.Equal(t, uint8(0), value, "writing 1 alone does not latch")
+
+	bus.Write(0x6000, 0x00)
+	bus.Write(0x6000, 0x01)
+	value, _ = bus.Read(0xA000)
+	require.Equal(t, uint8(5), value)
+}
+
+func TestMBC3_RTC_DayCounterCarry(t *testing.T) {
+	bus, clock := newMBC3TestBus(t, cartMBC3TimerBattery)
+	bus.Write(0x0000, 0x0A)
+
+	clock.Advance(300 * secondsPerDay * time.Second)
+	registers := readRTC(bus)
+	require.Equal(t, uint8(300-256), registers[3], "DL holds the low 8 bits")
+	require.Equal(t, uint8(rtcDayHighBit), registers[4], "DH bit 0 holds day bit 8")
+
+	clock.Advance(212 * secondsPerDay * time.Second)
+	registers = readRTC(bus)
+	require.Equal(t, uint8(0), registers[3])
+	require.Equal(t, uint8(rtcDayCarryBit), registers[4], "512 days sets the carry and wraps")
+}
+
+func TestMBC3_RTC_Halt(t *testing.T) {
+	bus, clock := newMBC3TestBus(t, cartMBC3TimerBattery)
+	bus.Write(0x0000, 0x0A)
+
+	bus.Write(0x4000, mbc3RTCDayHigh)
+	bus.Write(0xA000, rtcHaltBit)
+	bus.Write(0x4000, mbc3RTCMinutes)
+	bus.Write(0xA000, 42)
+
+	clock.Advance(time.Hour)
+	require.Equal(t, [5]uint8{0, 42, 0, 0, rtcHaltBit}, readRTC(bus), "a halted clock does not advance")
+
+	bus.Write(0x4000, mbc3RTCDayHigh)
+	bus.Write(0xA000, 0x00)
+
+	clock.Advance(time.Minute)
+	require.Equal(t, [5]uint8{0, 43, 0, 0, 0}, readRTC(bus))
+}
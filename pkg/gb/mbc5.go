@@ -0,0 +1,86 @@
+package gb
+
+const (
+	mbc5RAMEnableValue = 0x0A
+	mbc5RAMBankMask    = 0x0F
+	mbc5RumbleBit      = 0x08
+)
+
+// mbc5 implements the MBC5 controller: a 9-bit ROM bank split over two
+// registers and up to 16 RAM banks. On rumble cartridges bit 3 of the RAM
+// bank register drives the motor instead of selecting banks.
+//
+// Reference: Pan Docs - MBC5
+// https://gbdev.io/pandocs/MBC5.html
+type mbc5 struct {
+	rom []uint8
+	ram []uint8
+
+	ramEnabled bool
+	romBank    uint16 // unlike MBC1/MBC3, bank 0 can be mapped at 0x4000
+	ramBank    uint8
+
+	hasRumble bool
+	rumble    bool
+}
+
+func newMBC5(cart *Cartridge, hasRumble bool) *mbc5 {
+	return &mbc5{
+		rom:       cart.ROM(),
+		ram:       make([]uint8, cart.RAMSize),
+		romBank:   1,
+		hasRumble: hasRumble,
+	}
+}
+
+// rumbler is implemented by mappers with a rumble motor.
+type rumbler interface {
+	Rumble() bool
+}
+
+// Rumble reports whether the rumble motor is currently on.
+func (m *mbc5) Rumble() bool {
+	return m.rumble
+}
+
+func (m *mbc5) ReadROM(addr uint16) uint8 {
+	if addr < romBankSize {
+		return readBankedROM(m.rom, 0, addr)
+	}
+
+	return readBankedROM(m.rom, int(m.romBank), addr)
+}
+
+func (m *mbc5) WriteROM(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = value&0x0F == mbc5RAMEnableValue
+	case addr < 0x3000:
+		m.romBank = m.romBank&0x100 | uint16(value)
+	case addr < 0x4000:
+		m.romBank = m.romBank&0xFF | uint16(value&0x01)<<8
+	case addr < 0x6000:
+		if m.hasRumble {
+			m.rumble = value&mbc5RumbleBit != 0
+			value &^= mbc5RumbleBit
+		}
+
+		m.ramBank = value & mbc5RAMBankMask
+	}
+}
+
+func (m *mbc5) ReadRAM(addr uint16) uint8 {
+	if !m.ramEnabled {
+		return 0xFF
+	}
+
+	return readBankedRAM(m.ram, int(m.ramBank), addr)
+}
+
+func (m *mbc5) WriteRAM(addr uint16, value uint8) {
+	if !m.ramEnabled {
+		return
+	}
+
+	writeBankedRAM(m.ram, int(m.ramBank), addr, value)
+}
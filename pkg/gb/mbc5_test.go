@@ -0,0 +1,71 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// MBC5
+// =============================================================================
+//
+//   0000-1FFF: RAM enable
+//   2000-2FFF: ROM bank, low 8 bits
+//   3000-3FFF: ROM bank, bit 8
+//   4000-5FFF: RAM bank 0-F (bit 3 drives the motor on rumble carts)
+//
+// Reference: Pan Docs - MBC5
+// https://gbdev.io/pandocs/MBC5.html
+
+func TestMBC5_NineBitROMBank(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC5, 0x08, 0x00))) // 8 MiB, 512 banks
+
+	bus.Write(0x2000, 0x34)
+	bus.Write(0x3000, 0x01)
+
+	low, _ := bus.Read(0x4000)
+	high, _ := bus.Read(0x4001)
+	require.Equal(t, uint16(0x134), uint16(high)<<8|uint16(low))
+
+	bus.Write(0x2000, 0x00)
+	bus.Write(0x3000, 0x00)
+
+	low, _ = bus.Read(0x4000)
+	require.Equal(t, uint8(0x00), low, "MBC5 can map bank 0 at 0x4000")
+}
+
+func TestMBC5_RAMBanks(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC5RAMBattery, 0x01, 0x04))) // 128 KiB RAM
+
+	bus.Write(0x0000, 0x0A)
+
+	for bank := range uint8(16) {
+		bus.Write(0x4000, bank)
+		bus.Write(0xA000, bank+0x10)
+	}
+
+	for bank := range uint8(16) {
+		bus.Write(0x4000, bank)
+		value, _ := bus.Read(0xA000)
+		require.Equal(t, bank+0x10, value)
+	}
+}
+
+func TestMBC5_Rumble(t *testing.T) {
+	cart, err := ParseCartridge(newTestROM(cartMBC5RumbleRAM, 0x01, 0x03))
+	require.NoError(t, err)
+
+	m := newMBC5(cart, true)
+	m.WriteROM(0x0000, 0x0A)
+
+	m.WriteROM(0x4000, 0x09)
+	require.True(t, m.Rumble())
+	require.Equal(t, uint8(0x01), m.ramBank, "the rumble bit does not select a RAM bank")
+
+	m.WriteROM(0x4000, 0x01)
+	require.False(t, m.Rumble())
+}
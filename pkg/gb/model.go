@@ -0,0 +1,97 @@
+package gb
+
+// Model is the Game Boy hardware being emulated. The zero value is the
+// original DMG.
+type Model int
+
+const (
+	ModelDMG  Model = iota // Game Boy
+	ModelDMG0              // early Japanese Game Boy, boot ROM revision 0
+	ModelMGB               // Game Boy Pocket
+	ModelSGB               // Super Game Boy
+	ModelCGB               // Game Boy Color
+	ModelAGB               // Game Boy Advance in Game Boy Color mode
+)
+
+var modelNames = map[Model]string{
+	ModelDMG:  "DMG",
+	ModelDMG0: "DMG0",
+	ModelMGB:  "MGB",
+	ModelSGB:  "SGB",
+	ModelCGB:  "CGB",
+	ModelAGB:  "AGB",
+}
+
+func (m Model) String() string {
+	if name, ok := modelNames[m]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// IsCGB reports whether the model has Game Boy Color hardware.
+func (m Model) IsCGB() bool {
+	return m == ModelCGB || m == ModelAGB
+}
+
+// bootROMSize is the size of the model's boot ROM image. Color models map
+// 0x0000-0x00FF and 0x0200-0x08FF; the gap shows the cartridge header.
+func (m Model) bootROMSize() int {
+	if m.IsCGB() {
+		return cgbBootROMSize
+	}
+
+	return dmgBootROMSize
+}
+
+// bootState is what a model's boot ROM leaves behind when it jumps to the
+// cartridge at 0x0100.
+type bootState struct {
+	a, f, b, c, d, e, h, l uint8
+	div                    uint16 // system counter
+}
+
+// postBoot holds the register values per model. Color models differ for
+// cartridges without CGB support, see postBootCompat. B and H on those, and
+// DIV on SGB and color models, depend on the cartridge header and boot
+// timing; zero stands in for them.
+//
+// Reference: Pan Docs - Power Up Sequence
+// https://gbdev.io/pandocs/Power_Up_Sequence.html
+var postBoot = map[Model]bootState{
+	ModelDMG0: {a: 0x01, f: 0x00, b: 0xFF, c: 0x13, d: 0x00, e: 0xC1, h: 0x84, l: 0x03, div: 0x1800},
+	ModelDMG:  {a: 0x01, f: 0xB0, b: 0x00, c: 0x13, d: 0x00, e: 0xD8, h: 0x01, l: 0x4D, div: initDivCounter},
+	ModelMGB:  {a: 0xFF, f: 0xB0, b: 0x00, c: 0x13, d: 0x00, e: 0xD8, h: 0x01, l: 0x4D, div: initDivCounter},
+	ModelSGB:  {a: 0x01, f: 0x00, b: 0x00, c: 0x14, d: 0x00, e: 0x00, h: 0xC0, l: 0x60},
+	ModelCGB:  {a: 0x11, f: 0x80, b: 0x00, c: 0x00, d: 0xFF, e: 0x56, h: 0x00, l: 0x0D},
+	ModelAGB:  {a: 0x11, f: 0x00, b: 0x01, c: 0x00, d: 0xFF, e: 0x56, h: 0x00, l: 0x0D},
+}
+
+// postBootCompat holds the color models' values for DMG cartridges.
+var postBootCompat = map[Model]bootState{
+	ModelCGB: {a: 0x11, f: 0x80, b: 0x00, c: 0x00, d: 0x00, e: 0x08, h: 0x00, l: 0x7C},
+	ModelAGB: {a: 0x11, f: 0x00, b: 0x01, c: 0x00, d: 0x00, e: 0x08, h: 0x00, l: 0x7C},
+}
+
+// postBootState returns the state the model's boot ROM hands to cart, which
+// may be nil when no cartridge is loaded.
+func postBootState(m Model, cart *Cartridge) bootState {
+	state, ok := postBoot[m]
+
+	if !ok {
+		state = postBoot[ModelDMG]
+	}
+
+	if compat, ok := postBootCompat[m]; ok && cart != nil && !cart.IsCGB() {
+		state = compat
+	}
+
+	// The DMG and MGB boot ROMs leave H and C set unless the header checksum
+	// happens to be zero
+	if (m == ModelDMG || m == ModelMGB) && cart != nil && cart.HeaderChecksum == 0 {
+		state.f = flagZ
+	}
+
+	return state
+}
@@ -0,0 +1,686 @@
+package gb
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+const (
+	ScreenWidth    = 160
+	ScreenHeight   = 144
+	CyclesPerFrame = dotsPerLine * linesPerFrame // 70224 dots, ~59.7 Hz
+
+	dotsPerLine   = 456
+	linesPerFrame = 154
+	oamScanDots   = 80
+	drawingDots   = 172 // shortest mode 3: no scrolling, window or sprites
+	maxSprites    = 10  // per scanline
+	oamEntries    = 40
+	tileMapWidth  = 32
+	tileBytes     = 16
+)
+
+// PPU modes, as reported in STAT bits 0-1.
+const (
+	modeHBlank  = 0x00
+	modeVBlank  = 0x01
+	modeOAMScan = 0x02
+	modeDrawing = 0x03
+)
+
+// LCDC bits.
+const (
+	lcdcBGEnable     = 0x01
+	lcdcOBJEnable    = 0x02
+	lcdcOBJSize      = 0x04 // 8x16 sprites
+	lcdcBGMap        = 0x08 // 0x9C00 instead of 0x9800
+	lcdcTileData     = 0x10 // 0x8000 unsigned instead of 0x9000 signed
+	lcdcWindowEnable = 0x20
+	lcdcWindowMap    = 0x40 // 0x9C00 instead of 0x9800
+	lcdcEnable       = 0x80
+)
+
+// STAT bits.
+const (
+	statMode       = 0x03
+	statLYCFlag    = 0x04
+	statHBlankIRQ  = 0x08
+	statVBlankIRQ  = 0x10
+	statOAMIRQ     = 0x20
+	statLYCIRQ     = 0x40
+	statWritable   = 0x78
+	tileMapLow     = 0x9800
+	tileMapHigh    = 0x9C00
+	tileDataLow    = 0x8000
+	tileDataSigned = 0x9000
+)
+
+// Sprite attribute bits (OAM byte 3).
+const (
+	attrPalette  = 0x10 // DMG: OBP1 instead of OBP0
+	attrFlipX    = 0x20
+	attrFlipY    = 0x40
+	attrBehindBG = 0x80 // BG colors 1-3 are drawn over the sprite
+)
+
+// PPU register values after the DMG boot ROM.
+const (
+	initLCDC uint8 = 0x91
+	initBGP  uint8 = 0xFC
+)
+
+// dmgShades are the four grey levels of the DMG LCD, lightest first.
+var dmgShades = [4]color.RGBA{
+	{0xFF, 0xFF, 0xFF, 0xFF},
+	{0xAA, 0xAA, 0xAA, 0xFF},
+	{0x55, 0x55, 0x55, 0xFF},
+	{0x00, 0x00, 0x00, 0xFF},
+}
+
+// RenderMode selects how the PPU draws mode 3.
+type RenderMode int
+
+const (
+	// RenderScanline draws each line in one go after a fixed-length mode 3.
+	// It is fast and right for nearly every game.
+	RenderScanline RenderMode = iota
+	// RenderFIFO emulates the background fetcher and pixel FIFOs dot by dot,
+	// giving the variable mode 3 length and mid-scanline register effects
+	// that timing-sensitive demos rely on.
+	RenderFIFO
+)
+
+// renderer draws the current line during mode 3.
+type renderer interface {
+	// startLine is called when mode 3 begins.
+	startLine()
+	// drawDot advances mode 3 by one dot and reports whether the line is
+	// complete.
+	drawDot() bool
+}
+
+// scanlineRenderer keeps mode 3 at its minimum length and renders the whole
+// line with the register values of its last dot.
+type scanlineRenderer struct {
+	ppu       *ppu
+	remaining int
+}
+
+func (r *scanlineRenderer) startLine() {
+	r.remaining = drawingDots
+}
+
+func (r *scanlineRenderer) drawDot() bool {
+	r.remaining--
+
+	if r.remaining > 0 {
+		return false
+	}
+
+	r.ppu.renderScanline()
+
+	return true
+}
+
+// sprite is one OAM entry selected for the current scanline.
+type sprite struct {
+	y, x  int // screen position of the top-left pixel
+	tile  uint8
+	attr  uint8
+	index int // OAM index, breaks X ties on DMG and decides priority in CGB mode
+}
+
+// ppu is the picture processing unit. It walks the mode 2/3/0/1 state
+// machine one dot per cycle and hands mode 3 to its renderer, which either
+// draws the line in one go or runs the pixel FIFO. Finished frames are
+// copied to the front buffer at VBlank.
+//
+// Reference: Pan Docs - Rendering
+// https://gbdev.io/pandocs/Rendering.html
+type ppu struct {
+	bus      *bus
+	renderer renderer
+
+	lcdc uint8
+	stat uint8 // only the writable interrupt-select bits
+	scy  uint8
+	scx  uint8
+	ly   uint8
+	lyc  uint8
+	bgp  uint8
+	obp0 uint8
+	obp1 uint8
+	wy   uint8
+	wx   uint8
+
+	mode     uint8
+	dot      int  // dot within the current scanline
+	statLine bool // STAT interrupt line, interrupts fire on its rising edge
+
+	windowTriggered bool // WY matched LY at some point this frame
+	windowLine      int  // internal line counter, only advances when the window is drawn
+
+	sprites []sprite
+	bgIndex [ScreenWidth]uint8 // BG/window color index of the current line, for sprite priority
+	bgAttr  [ScreenWidth]uint8 // BG/window attributes of the current line, CGB mode only
+
+	bgPalettes  paletteRAM // BCPS/BCPD
+	objPalettes paletteRAM // OCPS/OCPD
+	opri        uint8
+
+	back   *image.RGBA
+	front  *image.RGBA
+	frames uint64
+}
+
+func newPPU(b *bus, mode RenderMode) *ppu {
+	p := &ppu{
+		bus:     b,
+		lcdc:    initLCDC,
+		bgp:     initBGP,
+		mode:    modeOAMScan,
+		sprites: make([]sprite, 0, maxSprites),
+		back:    image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight)),
+		front:   image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight)),
+	}
+
+	p.clear(p.front)
+	p.clear(p.back)
+
+	if mode == RenderFIFO {
+		p.renderer = newFIFORenderer(p)
+	} else {
+		p.renderer = &scanlineRenderer{ppu: p}
+	}
+
+	return p
+}
+
+// Framebuffer returns the last completed frame.
+func (p *ppu) Framebuffer() *image.RGBA {
+	return p.front
+}
+
+// Frames returns how many frames have been completed.
+func (p *ppu) Frames() uint64 {
+	return p.frames
+}
+
+func (p *ppu) clear(img *image.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:i+4], []uint8{0xFF, 0xFF, 0xFF, 0xFF})
+	}
+}
+
+func (p *ppu) Read(addr uint16) uint8 {
+	switch addr {
+	case regLCDC:
+		return p.lcdc
+	case regSTAT:
+		value := p.stat | p.mode
+
+		if p.ly == p.lyc {
+			value |= statLYCFlag
+		}
+
+		return value
+	case regSCY:
+		return p.scy
+	case regSCX:
+		return p.scx
+	case regLY:
+		return p.ly
+	case regLYC:
+		return p.lyc
+	case regBGP:
+		return p.bgp
+	case regOBP0:
+		return p.obp0
+	case regOBP1:
+		return p.obp1
+	case regWY:
+		return p.wy
+	case regWX:
+		return p.wx
+	case regBCPS:
+		return p.bgPalettes.index
+	case regBCPD:
+		return p.bgPalettes.readData()
+	case regOCPS:
+		return p.objPalettes.index
+	case regOCPD:
+		return p.objPalettes.readData()
+	case regOPRI:
+		return p.opri
+	}
+
+	return 0xFF
+}
+
+func (p *ppu) Write(addr uint16, value uint8) {
+	switch addr {
+	case regLCDC:
+		p.writeLCDC(value)
+	case regSTAT:
+		p.stat = value & statWritable
+		p.updateStatLine()
+	case regSCY:
+		p.scy = value
+	case regSCX:
+		p.scx = value
+	case regLY:
+		// read-only
+	case regLYC:
+		p.lyc = value
+		p.updateStatLine()
+	case regBGP:
+		p.bgp = value
+	case regOBP0:
+		p.obp0 = value
+	case regOBP1:
+		p.obp1 = value
+	case regWY:
+		p.wy = value
+	case regWX:
+		p.wx = value
+	case regBCPS:
+		p.bgPalettes.writeIndex(value)
+	case regBCPD:
+		p.bgPalettes.writeData(value)
+	case regOCPS:
+		p.objPalettes.writeIndex(value)
+	case regOCPD:
+		p.objPalettes.writeData(value)
+	case regOPRI:
+		p.opri = value & opriCoordinates
+	}
+}
+
+// writeLCDC handles the LCD being switched off (LY and the mode reset to 0
+// and the screen goes blank) or back on (a new frame starts at line 0).
+func (p *ppu) writeLCDC(value uint8) {
+	wasOn := p.lcdc&lcdcEnable != 0
+	p.lcdc = value
+
+	switch {
+	case wasOn && value&lcdcEnable == 0:
+		p.ly = 0
+		p.dot = 0
+		p.mode = modeHBlank
+		p.clear(p.front)
+	case !wasOn && value&lcdcEnable != 0:
+		p.ly = 0
+		p.dot = 0
+		p.windowLine = 0
+		p.windowTriggered = false
+		p.setMode(modeOAMScan)
+	}
+}
+
+// Tick advances the PPU by the given number of dots.
+func (p *ppu) Tick(cycles int) {
+	if p.lcdc&lcdcEnable == 0 {
+		return
+	}
+
+	for range cycles {
+		p.step()
+	}
+}
+
+func (p *ppu) step() {
+	p.dot++
+
+	switch {
+	case p.mode == modeOAMScan && p.dot == oamScanDots:
+		if p.ly == p.wy {
+			p.windowTriggered = true
+		}
+
+		p.scanOAM()
+		p.setMode(modeDrawing)
+		p.renderer.startLine()
+	case p.mode == modeDrawing:
+		if p.renderer.drawDot() {
+			p.setMode(modeHBlank)
+			p.bus.hdma.hblank()
+		}
+	case p.dot == dotsPerLine:
+		p.nextLine()
+	}
+}
+
+func (p *ppu) nextLine() {
+	p.dot = 0
+	p.ly++
+
+	switch {
+	case p.ly == ScreenHeight:
+		copy(p.front.Pix, p.back.Pix)
+		p.frames++
+
+		if p.bus.sgb != nil {
+			p.bus.sgb.vblank(p.front)
+		}
+
+		p.bus.interrupts.request(interruptVBlank)
+		p.setMode(modeVBlank)
+	case p.ly == linesPerFrame:
+		p.ly = 0
+		p.windowLine = 0
+		p.windowTriggered = false
+		p.setMode(modeOAMScan)
+	case p.ly < ScreenHeight:
+		p.setMode(modeOAMScan)
+	default:
+		p.updateStatLine()
+	}
+}
+
+func (p *ppu) setMode(mode uint8) {
+	p.mode = mode
+	p.updateStatLine()
+}
+
+// updateStatLine recomputes the STAT interrupt line, the OR of every enabled
+// condition, and requests an interrupt only when it goes from low to high.
+// A condition that stays true therefore blocks others from re-triggering.
+func (p *ppu) updateStatLine() {
+	line := p.ly == p.lyc && p.stat&statLYCIRQ != 0
+
+	switch p.mode {
+	case modeHBlank:
+		line = line || p.stat&statHBlankIRQ != 0
+	case modeVBlank:
+		line = line || p.stat&statVBlankIRQ != 0
+	case modeOAMScan:
+		line = line || p.stat&statOAMIRQ != 0
+	}
+
+	if line && !p.statLine {
+		p.bus.interrupts.request(interruptSTAT)
+	}
+
+	p.statLine = line
+}
+
+func (p *ppu) spriteHeight() int {
+	if p.lcdc&lcdcOBJSize != 0 {
+		return 16
+	}
+
+	return 8
+}
+
+// scanOAM selects up to ten sprites overlapping the current line, in OAM
+// order. X does not matter for selection, so off-screen sprites still count.
+// The selection is sorted by X either way, which is the order the pixel
+// FIFO fetches them in; oamPriority decides who wins where they overlap.
+func (p *ppu) scanOAM() {
+	p.sprites = p.sprites[:0]
+	height := p.spriteHeight()
+
+	for i := 0; i < oamEntries && len(p.sprites) < maxSprites; i++ {
+		y := int(p.bus.oam[i*4]) - 16
+
+		if int(p.ly) < y || int(p.ly) >= y+height {
+			continue
+		}
+
+		p.sprites = append(p.sprites, sprite{
+			y:     y,
+			x:     int(p.bus.oam[i*4+1]) - 8,
+			tile:  p.bus.oam[i*4+2],
+			attr:  p.bus.oam[i*4+3],
+			index: i,
+		})
+	}
+
+	sort.SliceStable(p.sprites, func(i, j int) bool {
+		return p.sprites[i].x < p.sprites[j].x
+	})
+}
+
+// oamPriority reports whether overlapping sprites are ranked by OAM index
+// alone, as in CGB mode unless OPRI asks for the DMG rule: smaller X first,
+// then OAM index.
+func (p *ppu) oamPriority() bool {
+	return p.bus.model.IsCGB() && p.opri&opriCoordinates == 0
+}
+
+// tileRow returns the two bit-plane bytes of row 0-7 of a tile, resolving
+// the tile index through the addressing mode selected by LCDC bit 4. BG
+// attributes pick the VRAM bank and flip the row vertically.
+func (p *ppu) tileRow(tile uint8, row uint8, attr uint8) (uint8, uint8) {
+	addr := uint16(tileDataLow) + uint16(tile)*tileBytes
+
+	if p.lcdc&lcdcTileData == 0 {
+		addr = uint16(int(tileDataSigned) + int(int8(tile))*tileBytes)
+	}
+
+	if attr&bgAttrFlipY != 0 {
+		row = 7 - row
+	}
+
+	offset := int(addr-tileDataLow) + int(row)*2
+
+	if attr&bgAttrBank != 0 {
+		offset += vramBankSize
+	}
+
+	return p.bus.vram[offset], p.bus.vram[offset+1]
+}
+
+// tilePixel returns the 2-bit color index at (x, y) of a 256x256 tile map,
+// along with the tile's attributes.
+func (p *ppu) tilePixel(tileMap uint16, x, y uint8) (uint8, uint8) {
+	offset := tileMap + uint16(y/8)*tileMapWidth + uint16(x/8) - tileDataLow
+	attr := p.bgAttributes(offset)
+	low, high := p.tileRow(p.bus.vram[offset], y%8, attr)
+	bit := 7 - x%8
+
+	if attr&bgAttrFlipX != 0 {
+		bit = x % 8
+	}
+
+	return pixelColor(low, high, bit), attr
+}
+
+// bgAttributes returns the attribute byte that VRAM bank 1 holds behind the
+// tile map entry at offset. Outside CGB mode there are none.
+func (p *ppu) bgAttributes(offset uint16) uint8 {
+	if !p.bus.cgbMode {
+		return 0
+	}
+
+	return p.bus.vram[vramBankSize+int(offset)]
+}
+
+func pixelColor(low, high uint8, bit uint8) uint8 {
+	return (high>>bit&0x01)<<1 | low>>bit&0x01
+}
+
+// shade applies a DMG palette register to a color index.
+func shade(palette uint8, index uint8) uint8 {
+	return palette >> (index * 2) & 0x03
+}
+
+func (p *ppu) bgMap() uint16 {
+	if p.lcdc&lcdcBGMap != 0 {
+		return tileMapHigh
+	}
+
+	return tileMapLow
+}
+
+func (p *ppu) windowMap() uint16 {
+	if p.lcdc&lcdcWindowMap != 0 {
+		return tileMapHigh
+	}
+
+	return tileMapLow
+}
+
+func (p *ppu) renderScanline() {
+	var line [ScreenWidth]color.RGBA
+
+	windowVisible := p.lcdc&lcdcWindowEnable != 0 && p.windowTriggered && p.wx <= ScreenWidth+6
+	windowUsed := false
+
+	for x := range ScreenWidth {
+		var index, attr uint8
+
+		// On DMG, LCDC bit 0 turns off both background and window; in CGB
+		// mode it only takes away their priority over sprites
+		if p.lcdc&lcdcBGEnable != 0 || p.bus.cgbMode {
+			if windowVisible && x+7 >= int(p.wx) {
+				index, attr = p.tilePixel(p.windowMap(), uint8(x+7-int(p.wx)), uint8(p.windowLine))
+				windowUsed = true
+			} else {
+				index, attr = p.tilePixel(p.bgMap(), uint8(x)+p.scx, p.ly+p.scy)
+			}
+		}
+
+		p.bgIndex[x] = index
+		p.bgAttr[x] = attr
+		line[x] = p.bgColor(index, attr)
+	}
+
+	if windowUsed {
+		p.windowLine++
+	}
+
+	if p.lcdc&lcdcOBJEnable != 0 {
+		for x := range ScreenWidth {
+			if value, ok := p.spritePixel(x); ok {
+				line[x] = value
+			}
+		}
+	}
+
+	for x, value := range line {
+		p.setPixel(x, value)
+	}
+}
+
+// setPixel writes a final color to the back buffer on the current line.
+func (p *ppu) setPixel(x int, value color.RGBA) {
+	p.back.SetRGBA(x, int(p.ly), value)
+}
+
+// bgColor turns a BG/window color index into its final color: through the
+// CGB palette its attributes select, or through BGP. A color model running
+// a DMG cartridge looks the BGP shade up in BG palette 0.
+func (p *ppu) bgColor(index, attr uint8) color.RGBA {
+	if p.bus.cgbMode {
+		return p.bgPalettes.color(attr&bgAttrPalette, index)
+	}
+
+	return p.dmgColor(&p.bgPalettes, 0, shade(p.bgp, index))
+}
+
+// objColor is bgColor for sprites, with OBP0/OBP1 mapping to OBJ palettes 0
+// and 1 outside CGB mode.
+func (p *ppu) objColor(index, attr uint8) color.RGBA {
+	if p.bus.cgbMode {
+		return p.objPalettes.color(attr&attrCGBPalette, index)
+	}
+
+	palette, number := p.obp0, uint8(0)
+
+	if attr&attrPalette != 0 {
+		palette, number = p.obp1, 1
+	}
+
+	return p.dmgColor(&p.objPalettes, number, shade(palette, index))
+}
+
+func (p *ppu) dmgColor(palettes *paletteRAM, number, value uint8) color.RGBA {
+	if p.bus.model.IsCGB() {
+		return palettes.color(number, value)
+	}
+
+	return dmgShades[value]
+}
+
+// spriteWins reports whether an opaque sprite pixel is drawn over the
+// background. In CGB mode, clearing LCDC bit 0 puts every sprite in front,
+// and the BG attribute's priority bit works like the sprite's own.
+func (p *ppu) spriteWins(bgIndex, bgAttr, objAttr uint8) bool {
+	if bgIndex == 0 {
+		return true
+	}
+
+	if p.bus.cgbMode && p.lcdc&lcdcBGEnable == 0 {
+		return true
+	}
+
+	return objAttr&attrBehindBG == 0 && bgAttr&bgAttrPriority == 0
+}
+
+// spritePixel returns the color of the highest-priority opaque sprite pixel
+// at x, if it is not hidden behind the background.
+func (p *ppu) spritePixel(x int) (color.RGBA, bool) {
+	var (
+		best  sprite
+		index uint8
+	)
+
+	for _, s := range p.sprites {
+		if x < s.x || x >= s.x+8 {
+			continue
+		}
+
+		value := p.spriteColor(s, x)
+
+		if value == 0 || index != 0 && s.index > best.index {
+			continue
+		}
+
+		best, index = s, value
+
+		// Sorted by X, the first opaque sprite wins unless OAM order decides
+		if !p.oamPriority() {
+			break
+		}
+	}
+
+	if index == 0 || !p.spriteWins(p.bgIndex[x], p.bgAttr[x], best.attr) {
+		return color.RGBA{}, false
+	}
+
+	return p.objColor(index, best.attr), true
+}
+
+// spriteColor returns the color index of sprite s at screen column x on the
+// current line, honouring flips, 8x16 mode and, in CGB mode, the VRAM bank.
+func (p *ppu) spriteColor(s sprite, x int) uint8 {
+	height := p.spriteHeight()
+	row := int(p.ly) - s.y
+	column := x - s.x
+
+	if s.attr&attrFlipY != 0 {
+		row = height - 1 - row
+	}
+
+	if s.attr&attrFlipX != 0 {
+		column = 7 - column
+	}
+
+	tile := s.tile
+
+	if height == 16 {
+		tile &= 0xFE
+	}
+
+	offset := int(tile)*tileBytes + row*2
+
+	if p.bus.cgbMode && s.attr&attrBank != 0 {
+		offset += vramBankSize
+	}
+
+	low, high := p.bus.vram[offset], p.bus.vram[offset+1]
+
+	return pixelColor(low, high, uint8(7-column))
+}
@@ -0,0 +1,275 @@
+package gb
+
+const (
+	fifoSize        = 8
+	fetcherTileDot  = 1 // dots 0-1: read the tile index
+	fetcherLowDot   = 3 // dots 2-3: read the low bit plane
+	fetcherHighDot  = 5 // dots 4-5: read the high bit plane
+	fetcherPushDot  = 6 // dot 6+: push once the BG FIFO is empty
+	firstFetchDots  = 6 // the first tile fetched on a line is thrown away
+	spriteFetchDots = 6
+)
+
+// fifoPixel is a pixel waiting in the BG or OBJ FIFO.
+type fifoPixel struct {
+	color uint8 // 2-bit color index
+	attr  uint8 // BG map attributes or OAM byte 3
+	oam   int   // OBJ only: OAM index, for CGB priority
+}
+
+// fetcher is the background/window tile fetcher. It produces one row of
+// eight pixels every six dots and pushes them when the BG FIFO is empty.
+type fetcher struct {
+	dot    int
+	x      uint8 // tile column, relative to SCX/8 for BG or 0 for the window
+	window bool
+	tile   uint8
+	attr   uint8 // CGB mode only
+	low    uint8
+	high   uint8
+}
+
+// fifoRenderer emulates the DMG pixel pipeline: the fetcher fills the BG
+// FIFO, sprites are mixed into the OBJ FIFO as the output position reaches
+// them, and one pixel is shifted out per dot. Mode 3 ends when 160 pixels
+// are out, so its length depends on SCX, the window and sprites.
+//
+// Reference: Pan Docs - Pixel FIFO
+// https://gbdev.io/pandocs/pixel_fifo.html
+type fifoRenderer struct {
+	ppu *ppu
+
+	fetcher fetcher
+	bg      [fifoSize]fifoPixel
+	bgLen   int
+	obj     [fifoSize]fifoPixel
+	objLen  int
+
+	lx         int // next screen column to output
+	discard    int // SCX fine-scroll pixels still to drop
+	delay      int // dots lost to the first fetch of the line
+	windowUsed bool
+
+	nextSprite  int // index into ppu.sprites of the next sprite to fetch
+	spriteDelay int // dots left in the current sprite fetch, -1 when idle
+}
+
+func newFIFORenderer(p *ppu) *fifoRenderer {
+	return &fifoRenderer{ppu: p, spriteDelay: -1}
+}
+
+func (r *fifoRenderer) startLine() {
+	r.fetcher = fetcher{}
+	r.bgLen = 0
+	r.objLen = 0
+	r.lx = 0
+	r.discard = int(r.ppu.scx % 8)
+	r.delay = firstFetchDots
+	r.windowUsed = false
+	r.nextSprite = 0
+	r.spriteDelay = -1
+}
+
+func (r *fifoRenderer) drawDot() bool {
+	if r.delay > 0 {
+		r.delay--
+
+		return false
+	}
+
+	if r.fetchSprite() {
+		return false
+	}
+
+	r.startWindow()
+	r.stepFetcher()
+	r.shiftOut()
+
+	if r.lx < ScreenWidth {
+		return false
+	}
+
+	if r.windowUsed {
+		r.ppu.windowLine++
+	}
+
+	return true
+}
+
+// startWindow switches the fetcher to the window when the output reaches
+// WX-7: the BG FIFO is cleared and fetching restarts from window column 0.
+func (r *fifoRenderer) startWindow() {
+	p := r.ppu
+
+	if r.fetcher.window || p.lcdc&lcdcWindowEnable == 0 || !p.windowTriggered {
+		return
+	}
+
+	if r.discard > 0 || r.lx+7 < int(p.wx) {
+		return
+	}
+
+	r.fetcher = fetcher{window: true}
+	r.bgLen = 0
+	r.windowUsed = true
+}
+
+// fetchSprite stalls the pipeline while a sprite that starts at the current
+// output column is fetched: six dots for the fetch itself, plus up to five
+// more waiting for the BG fetcher to finish the tile under the sprite.
+func (r *fifoRenderer) fetchSprite() bool {
+	p := r.ppu
+
+	if r.spriteDelay < 0 {
+		if p.lcdc&lcdcOBJEnable == 0 || r.discard > 0 || r.nextSprite >= len(p.sprites) {
+			return false
+		}
+
+		s := p.sprites[r.nextSprite]
+
+		if s.x > r.lx {
+			return false
+		}
+
+		column := (s.x + 8 + int(p.scx)) % 8
+		r.spriteDelay = spriteFetchDots + max(0, fetcherHighDot-column)
+	}
+
+	r.spriteDelay--
+
+	if r.spriteDelay > 0 {
+		return true
+	}
+
+	r.mergeSprite(p.sprites[r.nextSprite])
+	r.nextSprite++
+	r.spriteDelay = -1
+
+	return true
+}
+
+// mergeSprite overlays a sprite row onto the OBJ FIFO. Pixels already in the
+// FIFO belong to sprites with a smaller X and are only replaced where they
+// are transparent, or, under CGB priority, by a sprite earlier in OAM.
+func (r *fifoRenderer) mergeSprite(s sprite) {
+	p := r.ppu
+
+	for r.objLen < fifoSize {
+		r.obj[r.objLen] = fifoPixel{}
+		r.objLen++
+	}
+
+	// Sprites partly left of the screen lose their off-screen columns
+	skip := r.lx - s.x
+
+	for column := skip; column < 8; column++ {
+		slot := &r.obj[column-skip]
+		value := p.spriteColor(s, s.x+column)
+
+		if slot.color != 0 && (value == 0 || !p.oamPriority() || slot.oam < s.index) {
+			continue
+		}
+
+		*slot = fifoPixel{color: value, attr: s.attr, oam: s.index}
+	}
+}
+
+func (r *fifoRenderer) stepFetcher() {
+	p := r.ppu
+	f := &r.fetcher
+
+	switch f.dot {
+	case fetcherTileDot:
+		offset := r.tileMapAddr() - tileDataLow
+		f.tile = p.bus.vram[offset]
+		f.attr = p.bgAttributes(offset)
+	case fetcherLowDot:
+		f.low, _ = p.tileRow(f.tile, r.tileRow(), f.attr)
+	case fetcherHighDot:
+		_, f.high = p.tileRow(f.tile, r.tileRow(), f.attr)
+	}
+
+	if f.dot < fetcherPushDot {
+		f.dot++
+
+		return
+	}
+
+	if r.bgLen > 0 {
+		return
+	}
+
+	for i := range fifoSize {
+		bit := uint8(7 - i)
+
+		if f.attr&bgAttrFlipX != 0 {
+			bit = uint8(i)
+		}
+
+		r.bg[i] = fifoPixel{color: pixelColor(f.low, f.high, bit), attr: f.attr}
+	}
+
+	r.bgLen = fifoSize
+	f.x++
+	f.dot = 0
+}
+
+func (r *fifoRenderer) tileMapAddr() uint16 {
+	p := r.ppu
+
+	if r.fetcher.window {
+		return p.windowMap() + uint16(p.windowLine/8)*tileMapWidth + uint16(r.fetcher.x&0x1F)
+	}
+
+	y := p.ly + p.scy
+	x := (p.scx/8 + r.fetcher.x) & 0x1F
+
+	return p.bgMap() + uint16(y/8)*tileMapWidth + uint16(x)
+}
+
+func (r *fifoRenderer) tileRow() uint8 {
+	if r.fetcher.window {
+		return uint8(r.ppu.windowLine % 8)
+	}
+
+	return (r.ppu.ly + r.ppu.scy) % 8
+}
+
+// shiftOut pops one pixel from each FIFO, mixes them and writes the result.
+func (r *fifoRenderer) shiftOut() {
+	if r.bgLen == 0 {
+		return
+	}
+
+	p := r.ppu
+	bg := r.bg[0]
+	copy(r.bg[:], r.bg[1:r.bgLen])
+	r.bgLen--
+
+	if r.discard > 0 {
+		r.discard--
+
+		return
+	}
+
+	var obj fifoPixel
+
+	if r.objLen > 0 {
+		obj = r.obj[0]
+		copy(r.obj[:], r.obj[1:r.objLen])
+		r.objLen--
+	}
+
+	if p.lcdc&lcdcBGEnable == 0 && !p.bus.cgbMode {
+		bg.color = 0
+	}
+
+	value := p.bgColor(bg.color, bg.attr)
+
+	if obj.color != 0 && p.lcdc&lcdcOBJEnable != 0 && p.spriteWins(bg.color, bg.attr, obj.attr) {
+		value = p.objColor(obj.color, obj.attr)
+	}
+
+	p.setPixel(r.lx, value)
+	r.lx++
+}
@@ -0,0 +1,176 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newFIFOTestBus is newPPUTestBus with the pixel FIFO renderer attached.
+func newFIFOTestBus() *bus {
+	b := newBus()
+	b.attachPPU(RenderFIFO)
+	b.Write(regBGP, 0xE4)
+	b.Write(regOBP0, 0xE4)
+	b.Write(regOBP1, 0x1B)
+
+	return b
+}
+
+// mode3Length runs the first line's OAM scan and counts the dots spent in
+// mode 3.
+func mode3Length(b *bus) int {
+	b.ppu.Tick(oamScanDots)
+
+	dots := 0
+
+	for b.ppu.mode == modeDrawing {
+		b.ppu.Tick(1)
+		dots++
+	}
+
+	return dots
+}
+
+// =============================================================================
+// PIXEL FIFO TIMING
+// =============================================================================
+//
+// Mode 3 lasts 172 dots at minimum and is stretched by the SCX fine-scroll
+// discard (SCX mod 8 dots), the window restarting the fetcher (6 dots) and
+// each sprite fetch (6-11 dots depending on the BG fetcher's progress).
+//
+// Reference: Pan Docs - Rendering, Mode 3 length
+// https://gbdev.io/pandocs/Rendering.html#mode-3-length
+
+func TestFIFO_Mode3Length(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(b *bus)
+		dots  int
+	}{
+		{"plain background", func(_ *bus) {}, drawingDots},
+		{"SCX fine scroll", func(b *bus) { b.Write(regSCX, 0x13) }, drawingDots + 3},
+		{"window at x=80", func(b *bus) {
+			b.Write(regLCDC, initLCDC|lcdcWindowEnable)
+			b.Write(regWX, 80+7)
+		}, drawingDots + 6},
+		{"sprite at x=0", func(b *bus) {
+			b.Write(regLCDC, initLCDC|lcdcOBJEnable)
+			writeSprite(b, 0, 0, 0, 0x00, 0)
+		}, drawingDots + 11},
+		{"sprite disabled in LCDC", func(b *bus) {
+			writeSprite(b, 0, 0, 0, 0x00, 0)
+		}, drawingDots},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newFIFOTestBus()
+			tc.setup(b)
+
+			require.Equal(t, tc.dots, mode3Length(b))
+		})
+	}
+}
+
+func TestFIFO_Mode3Length_ScanlineModeIsFixed(t *testing.T) {
+	b := newPPUTestBus()
+	b.Write(regSCX, 0x07)
+	b.Write(regLCDC, initLCDC|lcdcOBJEnable)
+	writeSprite(b, 0, 0, 0, 0x00, 0)
+
+	require.Equal(t, drawingDots, mode3Length(b))
+}
+
+func TestFIFO_SpritesStretchHBlankAway(t *testing.T) {
+	b := newFIFOTestBus()
+	b.Write(regLCDC, initLCDC|lcdcOBJEnable)
+
+	for i := range maxSprites {
+		writeSprite(b, i, i*16, 0, 0x00, 0)
+	}
+
+	dots := mode3Length(b)
+	require.Greater(t, dots, drawingDots+maxSprites*6-1)
+
+	// The line still ends on time
+	b.ppu.Tick(dotsPerLine - oamScanDots - dots)
+	require.Equal(t, uint8(1), b.ppu.ly)
+}
+
+// =============================================================================
+// PIXEL FIFO OUTPUT
+// =============================================================================
+
+func TestFIFO_MatchesScanlineRenderer(t *testing.T) {
+	scene := func(b *bus) {
+		b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcOBJEnable|lcdcTileData|lcdcWindowEnable|lcdcWindowMap)
+		b.Write(regSCX, 5)
+		b.Write(regSCY, 3)
+		b.Write(regWY, 64)
+		b.Write(regWX, 96+7)
+
+		writeTile(b, 0x8010, 1)
+		writeTile(b, 0x8020, 2)
+
+		// Tile 3: a diagonal so flips and fine scroll are visible
+		for row := range uint16(8) {
+			b.Write(0x8030+row*2, 0x80>>row)
+			b.Write(0x8031+row*2, 0x01<<row)
+		}
+
+		for i := range uint16(32 * 32) {
+			b.Write(tileMapLow+i, uint8(i%4))
+			b.Write(tileMapHigh+i, uint8(3-i%4))
+		}
+
+		writeSprite(b, 0, -3, 10, 0x03, 0)
+		writeSprite(b, 1, 30, 12, 0x03, attrFlipX|attrPalette)
+		writeSprite(b, 2, 34, 12, 0x02, 0)
+		writeSprite(b, 3, 100, 70, 0x03, attrBehindBG)
+		writeSprite(b, 4, 156, 100, 0x03, attrFlipY)
+	}
+
+	scanline := newPPUTestBus()
+	fifo := newFIFOTestBus()
+	scene(scanline)
+	scene(fifo)
+
+	scanline.ppu.Tick(CyclesPerFrame)
+	fifo.ppu.Tick(CyclesPerFrame)
+
+	for y := range ScreenHeight {
+		for x := range ScreenWidth {
+			require.Equal(t, pixelAt(scanline, x, y), pixelAt(fifo, x, y), "pixel (%d, %d)", x, y)
+		}
+	}
+}
+
+func TestFIFO_MidScanlinePaletteWrite(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bus   func() *bus
+		left  uint8
+		right uint8
+	}{
+		{"FIFO splits the line", newFIFOTestBus, 0, 3},
+		{"scanline uses the last value", newPPUTestBus, 3, 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.bus()
+
+			// Roughly halfway through mode 3 of line 0, make color 0 black
+			b.ppu.Tick(oamScanDots + drawingDots/2)
+			b.Write(regBGP, 0xE7)
+			b.ppu.Tick(CyclesPerFrame - oamScanDots - drawingDots/2)
+
+			require.Equal(t, dmgShades[tc.left], pixelAt(b, 0, 0))
+			require.Equal(t, dmgShades[tc.right], pixelAt(b, ScreenWidth-1, 0))
+			require.Equal(t, dmgShades[3], pixelAt(b, 0, 1))
+		})
+	}
+}
@@ -0,0 +1,276 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTile fills tile data at addr with a single 2-bit color index.
+func writeTile(b *bus, addr uint16, index uint8) {
+	var low, high uint8
+
+	if index&0x01 != 0 {
+		low = 0xFF
+	}
+
+	if index&0x02 != 0 {
+		high = 0xFF
+	}
+
+	for row := range uint16(8) {
+		b.Write(addr+row*2, low)
+		b.Write(addr+row*2+1, high)
+	}
+}
+
+// newPPUTestBus returns a bus with the identity palette in BGP/OBP0 and the
+// PPU at the very start of a frame.
+func newPPUTestBus() *bus {
+	b := newBus()
+	b.Write(regBGP, 0xE4)
+	b.Write(regOBP0, 0xE4)
+	b.Write(regOBP1, 0x1B)
+
+	return b
+}
+
+func pixelAt(b *bus, x, y int) color.RGBA {
+	return b.ppu.Framebuffer().RGBAAt(x, y)
+}
+
+// =============================================================================
+// PPU TIMING
+// =============================================================================
+//
+// Each scanline is 456 dots: mode 2 (OAM scan, 80 dots), mode 3 (drawing,
+// 172+ dots), mode 0 (HBlank, the rest). Lines 144-153 are mode 1 (VBlank).
+// A frame is 154 lines = 70224 dots.
+//
+// Reference: Pan Docs - Rendering
+// https://gbdev.io/pandocs/Rendering.html
+
+func TestPPU_ModeTimingAndLY(t *testing.T) {
+	b := newPPUTestBus()
+	p := b.ppu
+
+	require.Equal(t, uint8(modeOAMScan), p.mode)
+
+	p.Tick(oamScanDots)
+	require.Equal(t, uint8(modeDrawing), p.mode)
+
+	p.Tick(drawingDots)
+	require.Equal(t, uint8(modeHBlank), p.mode)
+
+	p.Tick(dotsPerLine - oamScanDots - drawingDots)
+	require.Equal(t, uint8(modeOAMScan), p.mode)
+
+	ly, _ := b.Read(regLY)
+	require.Equal(t, uint8(1), ly)
+
+	p.Tick(dotsPerLine * (ScreenHeight - 1))
+	require.Equal(t, uint8(modeVBlank), p.mode)
+	require.Equal(t, uint8(ScreenHeight), p.ly)
+	require.NotZero(t, b.interrupts.flag&interruptVBlank, "VBlank should be requested at line 144")
+	require.Equal(t, uint64(1), p.Frames())
+
+	p.Tick(dotsPerLine * (linesPerFrame - ScreenHeight))
+	require.Equal(t, uint8(0), p.ly)
+	require.Equal(t, uint8(modeOAMScan), p.mode)
+}
+
+func TestPPU_STAT_ReportsModeAndLYC(t *testing.T) {
+	b := newPPUTestBus()
+	b.Write(regLYC, 2)
+	b.Write(regSTAT, statLYCIRQ)
+
+	b.ppu.Tick(oamScanDots)
+	stat, _ := b.Read(regSTAT)
+	require.Equal(t, uint8(0x80|statLYCIRQ|modeDrawing), stat)
+
+	b.ppu.Tick(dotsPerLine*2 - oamScanDots)
+	stat, _ = b.Read(regSTAT)
+	require.NotZero(t, stat&statLYCFlag, "LY=LYC should set the coincidence flag")
+	require.NotZero(t, b.interrupts.flag&interruptSTAT, "LY=LYC should request STAT")
+}
+
+func TestPPU_STAT_InterruptOnlyOnRisingEdge(t *testing.T) {
+	b := newPPUTestBus()
+	b.Write(regSTAT, statHBlankIRQ|statOAMIRQ)
+	b.interrupts.flag = 0
+
+	// HBlank into OAM scan keeps the line high: no second interrupt
+	b.ppu.Tick(oamScanDots + drawingDots)
+	require.NotZero(t, b.interrupts.flag&interruptSTAT)
+
+	b.interrupts.flag = 0
+	b.ppu.Tick(dotsPerLine - oamScanDots - drawingDots)
+	require.Zero(t, b.interrupts.flag&interruptSTAT)
+}
+
+func TestPPU_LCDOff(t *testing.T) {
+	b := newPPUTestBus()
+	b.ppu.Tick(dotsPerLine * 10)
+
+	b.Write(regLCDC, initLCDC&^lcdcEnable)
+	b.ppu.Tick(dotsPerLine * 10)
+
+	ly, _ := b.Read(regLY)
+	require.Equal(t, uint8(0), ly)
+
+	stat, _ := b.Read(regSTAT)
+	require.Equal(t, uint8(modeHBlank), stat&statMode)
+}
+
+// =============================================================================
+// BACKGROUND AND WINDOW
+// =============================================================================
+
+func TestPPU_Background_TileDataModes(t *testing.T) {
+	testCases := []struct {
+		name string
+		lcdc uint8
+		tile uint16 // address of tile index 0x80 in the selected mode
+	}{
+		{"0x8000 unsigned", lcdcEnable | lcdcBGEnable | lcdcTileData, 0x8800},
+		{"0x9000 signed", lcdcEnable | lcdcBGEnable, 0x8800},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newPPUTestBus()
+			b.Write(regLCDC, tc.lcdc)
+			writeTile(b, tc.tile, 3)
+			b.Write(tileMapLow, 0x80)
+
+			b.ppu.Tick(CyclesPerFrame)
+
+			require.Equal(t, dmgShades[3], pixelAt(b, 0, 0))
+			require.Equal(t, dmgShades[0], pixelAt(b, 8, 0))
+		})
+	}
+}
+
+func TestPPU_Background_Scroll(t *testing.T) {
+	b := newPPUTestBus()
+	writeTile(b, 0x8010, 2)
+	b.Write(tileMapLow+1, 0x01) // second tile of the first row
+
+	b.Write(regSCX, 4)
+	b.ppu.Tick(CyclesPerFrame)
+
+	require.Equal(t, dmgShades[0], pixelAt(b, 3, 0))
+	require.Equal(t, dmgShades[2], pixelAt(b, 4, 0), "SCX=4 moves tile column 1 to x=4")
+	require.Equal(t, dmgShades[2], pixelAt(b, 11, 0))
+	require.Equal(t, dmgShades[0], pixelAt(b, 12, 0))
+}
+
+func TestPPU_Window_InternalLineCounter(t *testing.T) {
+	b := newPPUTestBus()
+	b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcTileData|lcdcWindowEnable|lcdcWindowMap)
+	b.Write(regWY, 0)
+	b.Write(regWX, 7)
+
+	// Window map row 0 uses tile 1 (color 1), row 1 uses tile 2 (color 2)
+	writeTile(b, 0x8010, 1)
+	writeTile(b, 0x8020, 2)
+
+	for x := range uint16(tileMapWidth) {
+		b.Write(tileMapHigh+x, 0x01)
+		b.Write(tileMapHigh+tileMapWidth+x, 0x02)
+	}
+
+	// Draw 4 lines of window, hide it for 4 lines, then show it again
+	b.ppu.Tick(dotsPerLine * 4)
+	b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcTileData|lcdcWindowMap)
+	b.ppu.Tick(dotsPerLine * 4)
+	b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcTileData|lcdcWindowEnable|lcdcWindowMap)
+	b.ppu.Tick(CyclesPerFrame - dotsPerLine*8)
+
+	require.Equal(t, dmgShades[1], pixelAt(b, 0, 3))
+	require.Equal(t, dmgShades[0], pixelAt(b, 0, 4), "background shows while the window is off")
+	require.Equal(t, dmgShades[1], pixelAt(b, 0, 8), "the window resumes at its own line 4, not 8")
+	require.Equal(t, dmgShades[2], pixelAt(b, 0, 12))
+}
+
+// =============================================================================
+// SPRITES
+// =============================================================================
+//
+// OAM holds 40 sprites of 4 bytes: Y+16, X+8, tile, attributes. At most 10
+// are drawn per line. On DMG, the lower X wins; equal X falls back to OAM
+// order.
+
+func writeSprite(b *bus, index int, x, y int, tile, attr uint8) {
+	base := uint16(0xFE00 + index*4)
+	b.Write(base, uint8(y+16))
+	b.Write(base+1, uint8(x+8))
+	b.Write(base+2, tile)
+	b.Write(base+3, attr)
+}
+
+func TestPPU_Sprites_PriorityAndLimit(t *testing.T) {
+	b := newPPUTestBus()
+	b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcOBJEnable|lcdcTileData)
+	writeTile(b, 0x8010, 1)
+	writeTile(b, 0x8020, 2)
+
+	// Sprite 0 at x=4 loses to sprite 1 at x=2 where they overlap
+	writeSprite(b, 0, 4, 0, 0x01, 0)
+	writeSprite(b, 1, 2, 0, 0x02, 0)
+
+	// Eleven sprites on line 20: the eleventh is dropped
+	for i := range 11 {
+		writeSprite(b, 2+i, i*10, 20, 0x01, 0)
+	}
+
+	b.ppu.Tick(CyclesPerFrame)
+
+	require.Equal(t, dmgShades[2], pixelAt(b, 5, 0), "lower X wins")
+	require.Equal(t, dmgShades[1], pixelAt(b, 11, 0))
+	require.Equal(t, dmgShades[1], pixelAt(b, 90, 20), "tenth sprite is drawn")
+	require.Equal(t, dmgShades[0], pixelAt(b, 100, 20), "eleventh sprite is dropped")
+}
+
+func TestPPU_Sprites_PaletteFlipAndBGPriority(t *testing.T) {
+	b := newPPUTestBus()
+	b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcOBJEnable|lcdcTileData)
+
+	// Tile 1: only the leftmost column is color 3
+	for row := range uint16(8) {
+		b.Write(0x8010+row*2, 0x80)
+		b.Write(0x8011+row*2, 0x80)
+	}
+
+	writeSprite(b, 0, 0, 0, 0x01, attrFlipX)
+	writeSprite(b, 1, 20, 0, 0x01, attrPalette)
+
+	// BG tile 2 is color 1 under the third sprite, which is behind the BG
+	writeTile(b, 0x8020, 1)
+	b.Write(tileMapLow+5, 0x02)
+	writeSprite(b, 2, 40, 0, 0x01, attrBehindBG)
+
+	b.ppu.Tick(CyclesPerFrame)
+
+	require.Equal(t, dmgShades[0], pixelAt(b, 0, 0))
+	require.Equal(t, dmgShades[3], pixelAt(b, 7, 0), "X flip mirrors the column")
+	require.Equal(t, dmgShades[0], pixelAt(b, 20, 0), "OBP1 maps color 3 to white")
+	require.Equal(t, dmgShades[1], pixelAt(b, 40, 0), "BG colors 1-3 cover a behind-BG sprite")
+}
+
+func TestPPU_Sprites_8x16(t *testing.T) {
+	b := newPPUTestBus()
+	b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcOBJEnable|lcdcOBJSize|lcdcTileData)
+	writeTile(b, 0x8020, 1)
+	writeTile(b, 0x8030, 2)
+
+	// Bit 0 of the tile index is ignored in 8x16 mode
+	writeSprite(b, 0, 0, 0, 0x03, 0)
+
+	b.ppu.Tick(CyclesPerFrame)
+
+	require.Equal(t, dmgShades[1], pixelAt(b, 0, 0))
+	require.Equal(t, dmgShades[2], pixelAt(b, 0, 15))
+}
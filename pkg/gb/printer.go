@@ -0,0 +1,331 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+const (
+	printerMagic1      = 0x88
+	printerMagic2      = 0x33
+	printerCmdInit     = 0x01
+	printerCmdPrint    = 0x02
+	printerCmdData     = 0x04
+	printerCmdBreak    = 0x08
+	printerCmdStatus   = 0x0F
+	printerAlive       = 0x81 // device ID sent back after the checksum
+	printerWidthTiles  = 20
+	printerBandBytes   = printerWidthTiles * 2 * tileBytes // one DATA packet: two rows of tiles
+	printerMaxBands    = 9
+	printerBusyPolls   = 4 // STATUS inquiries that still report "printing"
+	printerMarginRows  = 8 // pixel rows per margin unit in the rendered strip
+	printerDefaultBGP  = 0xE4
+	printerRLERun      = 0x80
+	printerRLELenMask  = 0x7F
+	printerRLERunExtra = 2 // a run of n means n+2 copies
+)
+
+// Printer status bits, sent as the last byte of every packet.
+const (
+	printerStatusChecksum    = 0x01
+	printerStatusPrinting    = 0x02
+	printerStatusFull        = 0x04
+	printerStatusUnprocessed = 0x08
+)
+
+// printerState is the position within a packet.
+type printerState int
+
+const (
+	printerWaitMagic1 printerState = iota
+	printerWaitMagic2
+	printerReadCommand
+	printerReadCompression
+	printerReadLengthLow
+	printerReadLengthHigh
+	printerReadData
+	printerReadChecksumLow
+	printerReadChecksumHigh
+	printerSendAlive
+	printerSendStatus
+)
+
+// PrintHandler receives each printed strip. An error is kept and reported
+// by Printer.Err.
+type PrintHandler func(strip *image.Paletted) error
+
+// Printer emulates the Game Boy Printer on the link cable. The Game Boy
+// always drives the clock and sends packets of the form
+//
+//	0x88 0x33 command compression length(LE16) data... checksum(LE16) 0x00 0x00
+//
+// to which the printer answers 0x00 for every byte except the last two:
+// 0x81 (alive) and its status. DATA packets carry two rows of 20 tiles,
+// optionally run-length encoded; PRINT renders the buffered tiles with the
+// packet's palette and margins and hands the strip to the PrintHandler.
+//
+// Reference: Pan Docs - Game Boy Printer
+// https://gbdev.io/pandocs/Gameboy_Printer.html
+type Printer struct {
+	handler PrintHandler
+	err     error
+
+	state       printerState
+	command     uint8
+	compression uint8
+	length      uint16
+	packet      []uint8
+	sum         uint16
+	checksum    uint16
+
+	buffer []uint8 // decompressed tile data waiting to be printed
+	status uint8
+	busy   int
+}
+
+// NewPrinter returns a printer that calls handler for every strip.
+func NewPrinter(handler PrintHandler) *Printer {
+	return &Printer{handler: handler}
+}
+
+// Err returns the first error returned by the PrintHandler.
+func (p *Printer) Err() error {
+	return p.err
+}
+
+// Exchange receives one byte of a packet and returns the printer's reply.
+func (p *Printer) Exchange(out uint8) uint8 {
+	switch p.state {
+	case printerWaitMagic1:
+		if out == printerMagic1 {
+			p.state = printerWaitMagic2
+		}
+	case printerWaitMagic2:
+		p.state = printerWaitMagic1
+
+		if out == printerMagic2 {
+			p.state = printerReadCommand
+		}
+	case printerReadCommand:
+		p.command = out
+		p.sum = uint16(out)
+		p.state = printerReadCompression
+	case printerReadCompression:
+		p.compression = out
+		p.sum += uint16(out)
+		p.state = printerReadLengthLow
+	case printerReadLengthLow:
+		p.length = uint16(out)
+		p.sum += uint16(out)
+		p.state = printerReadLengthHigh
+	case printerReadLengthHigh:
+		p.length |= uint16(out) << 8
+		p.sum += uint16(out)
+		p.packet = p.packet[:0]
+		p.state = printerReadData
+
+		if p.length == 0 {
+			p.state = printerReadChecksumLow
+		}
+	case printerReadData:
+		p.packet = append(p.packet, out)
+		p.sum += uint16(out)
+
+		if len(p.packet) == int(p.length) {
+			p.state = printerReadChecksumLow
+		}
+	case printerReadChecksumLow:
+		p.checksum = uint16(out)
+		p.state = printerReadChecksumHigh
+	case printerReadChecksumHigh:
+		p.checksum |= uint16(out) << 8
+		p.process()
+		p.state = printerSendAlive
+	case printerSendAlive:
+		p.state = printerSendStatus
+
+		return printerAlive
+	case printerSendStatus:
+		p.state = printerWaitMagic1
+
+		return p.status
+	}
+
+	return 0x00
+}
+
+// Poll never reports a byte: the printer only ever follows the Game Boy's
+// clock.
+func (p *Printer) Poll(_ uint8, _ bool) (uint8, bool) {
+	return 0, false
+}
+
+// process runs a complete packet and updates the status byte.
+func (p *Printer) process() {
+	if p.checksum != p.sum {
+		p.status |= printerStatusChecksum
+
+		return
+	}
+
+	p.status &^= printerStatusChecksum
+
+	switch p.command {
+	case printerCmdInit:
+		p.buffer = p.buffer[:0]
+		p.status = 0
+		p.busy = 0
+	case printerCmdData:
+		p.receive()
+	case printerCmdPrint:
+		p.print()
+	case printerCmdBreak:
+		p.busy = 0
+		p.status &^= printerStatusPrinting
+	case printerCmdStatus:
+		if p.busy > 0 {
+			p.busy--
+
+			if p.busy == 0 {
+				p.status &^= printerStatusPrinting
+			}
+		}
+	}
+}
+
+// receive appends a DATA packet's tiles to the print buffer. Once the
+// buffer holds printerMaxBands bands, further data is dropped.
+func (p *Printer) receive() {
+	data := p.packet
+
+	if p.compression != 0 {
+		data = decompressPrinterData(data)
+	}
+
+	room := printerBandBytes*printerMaxBands - len(p.buffer)
+
+	if len(data) == 0 || room <= 0 {
+		return
+	}
+
+	p.buffer = append(p.buffer, data[:min(len(data), room)]...)
+	p.status |= printerStatusUnprocessed
+
+	if len(p.buffer) == printerBandBytes*printerMaxBands {
+		p.status |= printerStatusFull
+	}
+}
+
+// decompressPrinterData expands the printer's run-length encoding: a control
+// byte with bit 7 set repeats the next byte (n&0x7F)+2 times, otherwise the
+// next n+1 bytes are copied as is.
+func decompressPrinterData(data []uint8) []uint8 {
+	var out []uint8
+
+	for i := 0; i < len(data); {
+		control := data[i]
+		i++
+
+		if control&printerRLERun != 0 {
+			if i >= len(data) {
+				break
+			}
+
+			for range int(control&printerRLELenMask) + printerRLERunExtra {
+				out = append(out, data[i])
+			}
+
+			i++
+
+			continue
+		}
+
+		end := min(i+int(control)+1, len(data))
+		out = append(out, data[i:end]...)
+		i = end
+	}
+
+	return out
+}
+
+// print renders the buffer as one strip. The PRINT packet carries the number
+// of sheets, margins (upper nibble before, lower nibble after), a palette in
+// BGP format and the exposure.
+func (p *Printer) print() {
+	if len(p.packet) < 4 {
+		return
+	}
+
+	margins := p.packet[1]
+	palette := p.packet[2]
+
+	if palette == 0 {
+		palette = printerDefaultBGP
+	}
+
+	strip := renderPrinterStrip(p.buffer, palette, int(margins>>4), int(margins&0x0F))
+
+	p.buffer = p.buffer[:0]
+	p.status = p.status&^(printerStatusUnprocessed|printerStatusFull) | printerStatusPrinting
+	p.busy = printerBusyPolls
+
+	if p.handler != nil && p.err == nil {
+		p.err = p.handler(strip)
+	}
+}
+
+// renderPrinterStrip lays out 2bpp tiles 20 to a row, with blank margins
+// above and below.
+func renderPrinterStrip(tiles []uint8, palette uint8, top, bottom int) *image.Paletted {
+	rows := len(tiles) / (printerWidthTiles * tileBytes)
+	topRows := top * printerMarginRows
+	height := topRows + rows*8 + bottom*printerMarginRows
+
+	colors := make(color.Palette, len(dmgShades))
+
+	for i, shade := range dmgShades {
+		colors[i] = shade
+	}
+
+	strip := image.NewPaletted(image.Rect(0, 0, ScreenWidth, height), colors)
+
+	for y := range rows * 8 {
+		for x := range ScreenWidth {
+			tile := (y/8)*printerWidthTiles + x/8
+			offset := tile*tileBytes + (y%8)*2
+			index := pixelColor(tiles[offset], tiles[offset+1], uint8(7-x%8))
+
+			strip.SetColorIndex(x, topRows+y, shade(palette, index))
+		}
+	}
+
+	return strip
+}
+
+// PNGDirectory returns a PrintHandler that writes each strip to dir as
+// print-001.png, print-002.png and so on.
+func PNGDirectory(dir string) PrintHandler {
+	count := 0
+
+	return func(strip *image.Paletted) error {
+		count++
+		path := filepath.Join(dir, fmt.Sprintf("print-%03d.png", count))
+
+		f, err := os.Create(path)
+
+		if err != nil {
+			return fmt.Errorf("failed to create print: %w", err)
+		}
+
+		if err := png.Encode(f, strip); err != nil {
+			return errors.Join(fmt.Errorf("failed to encode print: %w", err), f.Close())
+		}
+
+		return f.Close()
+	}
+}
@@ -0,0 +1,235 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// printerPacket builds a packet with a correct checksum, followed by the two
+// bytes that clock out the alive and status replies.
+func printerPacket(command, compression uint8, data []uint8) []uint8 {
+	length := len(data)
+	packet := []uint8{printerMagic1, printerMagic2, command, compression, uint8(length), uint8(length >> 8)}
+	packet = append(packet, data...)
+
+	sum := uint16(0)
+
+	for _, b := range packet[2:] {
+		sum += uint16(b)
+	}
+
+	return append(packet, uint8(sum), uint8(sum>>8), 0x00, 0x00)
+}
+
+// sendPacket clocks a packet into the peer and returns the replies.
+func sendPacket(peer LinkPeer, packet []uint8) []uint8 {
+	replies := make([]uint8, len(packet))
+
+	for i, b := range packet {
+		replies[i] = peer.Exchange(b)
+	}
+
+	return replies
+}
+
+// printerStatus returns the status byte from a packet's replies.
+func printerStatus(replies []uint8) uint8 {
+	return replies[len(replies)-1]
+}
+
+// printerBand returns one DATA packet's worth of tiles: 40 tiles of a
+// single color index.
+func printerBand(index uint8) []uint8 {
+	var low, high uint8
+
+	if index&0x01 != 0 {
+		low = 0xFF
+	}
+
+	if index&0x02 != 0 {
+		high = 0xFF
+	}
+
+	band := make([]uint8, 0, printerBandBytes)
+
+	for range printerBandBytes / 2 {
+		band = append(band, low, high)
+	}
+
+	return band
+}
+
+// =============================================================================
+// PRINTER PROTOCOL
+// =============================================================================
+//
+// Reference: Pan Docs - Game Boy Printer
+// https://gbdev.io/pandocs/Gameboy_Printer.html
+
+func TestPrinter_Replies(t *testing.T) {
+	p := NewPrinter(nil)
+	replies := sendPacket(p, printerPacket(printerCmdInit, 0, nil))
+
+	require.Equal(t, []uint8{0, 0, 0, 0, 0, 0, 0, 0, printerAlive, 0x00}, replies)
+}
+
+func TestPrinter_ChecksumError(t *testing.T) {
+	p := NewPrinter(nil)
+	packet := printerPacket(printerCmdData, 0, []uint8{1, 2, 3})
+	packet[len(packet)-4]++ // corrupt the checksum
+
+	require.Equal(t, uint8(printerStatusChecksum), printerStatus(sendPacket(p, packet)))
+	require.Empty(t, p.buffer, "a bad packet is dropped")
+
+	status := printerStatus(sendPacket(p, printerPacket(printerCmdStatus, 0, nil)))
+	require.Zero(t, status&printerStatusChecksum, "a good packet clears the error")
+}
+
+func TestPrinter_IgnoresNoiseBeforeMagic(t *testing.T) {
+	p := NewPrinter(nil)
+	packet := append([]uint8{0x00, 0x88, 0x00, 0x33}, printerPacket(printerCmdStatus, 0, nil)...)
+
+	replies := sendPacket(p, packet)
+	require.Equal(t, uint8(printerAlive), replies[len(replies)-2])
+}
+
+func TestPrinter_DataAndStatusBits(t *testing.T) {
+	p := NewPrinter(nil)
+	sendPacket(p, printerPacket(printerCmdInit, 0, nil))
+
+	status := printerStatus(sendPacket(p, printerPacket(printerCmdData, 0, printerBand(1))))
+	require.Equal(t, uint8(printerStatusUnprocessed), status)
+
+	for range printerMaxBands - 1 {
+		status = printerStatus(sendPacket(p, printerPacket(printerCmdData, 0, printerBand(1))))
+	}
+
+	require.Equal(t, uint8(printerStatusUnprocessed|printerStatusFull), status)
+
+	// An empty DATA packet marks the end of the image
+	status = printerStatus(sendPacket(p, printerPacket(printerCmdData, 0, nil)))
+	require.Equal(t, uint8(printerStatusUnprocessed|printerStatusFull), status)
+}
+
+func TestPrinter_DropsDataWhenFull(t *testing.T) {
+	p := NewPrinter(nil)
+	sendPacket(p, printerPacket(printerCmdInit, 0, nil))
+
+	for range printerMaxBands + 2 {
+		sendPacket(p, printerPacket(printerCmdData, 0, printerBand(1)))
+	}
+
+	require.Len(t, p.buffer, printerBandBytes*printerMaxBands)
+}
+
+func TestPrinter_Decompression(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   []uint8
+		want []uint8
+	}{
+		{"literal", []uint8{0x02, 0xAA, 0xBB, 0xCC}, []uint8{0xAA, 0xBB, 0xCC}},
+		{"run", []uint8{0x81, 0x55}, []uint8{0x55, 0x55, 0x55}},
+		{"mixed", []uint8{0x80, 0x11, 0x00, 0x22}, []uint8{0x11, 0x11, 0x22}},
+		{"truncated run", []uint8{0x85}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, decompressPrinterData(tc.in))
+		})
+	}
+}
+
+// =============================================================================
+// PRINTING
+// =============================================================================
+
+func TestPrinter_PrintRendersStrip(t *testing.T) {
+	var strips []*image.Paletted
+
+	p := NewPrinter(func(strip *image.Paletted) error {
+		strips = append(strips, strip)
+
+		return nil
+	})
+
+	sendPacket(p, printerPacket(printerCmdInit, 0, nil))
+	sendPacket(p, printerPacket(printerCmdData, 0, printerBand(1)))
+
+	// The second band is compressed: 640 bytes of 0xFF in 0x81-long runs
+	var compressed []uint8
+
+	for range printerBandBytes / (printerRLELenMask + printerRLERunExtra) {
+		compressed = append(compressed, 0xFF, 0xFF)
+	}
+
+	compressed = append(compressed, 0x80|(printerBandBytes%(printerRLELenMask+printerRLERunExtra)-printerRLERunExtra), 0xFF)
+	sendPacket(p, printerPacket(printerCmdData, 1, compressed))
+	sendPacket(p, printerPacket(printerCmdData, 0, nil))
+
+	// One sheet, margins 1 before and 2 after, palette inverted
+	status := printerStatus(sendPacket(p, printerPacket(printerCmdPrint, 0, []uint8{0x01, 0x12, 0x1B, 0x40})))
+	require.Equal(t, uint8(printerStatusPrinting), status)
+
+	require.Len(t, strips, 1)
+	strip := strips[0]
+	require.Equal(t, image.Rect(0, 0, ScreenWidth, 8+32+16), strip.Bounds())
+
+	require.Equal(t, uint8(0), strip.ColorIndexAt(0, 0), "the top margin is blank")
+	require.Equal(t, uint8(2), strip.ColorIndexAt(0, 8), "color 1 through palette 0x1B")
+	require.Equal(t, uint8(0), strip.ColorIndexAt(159, 8+16), "color 3 through palette 0x1B")
+	require.Equal(t, uint8(0), strip.ColorIndexAt(0, 8+32), "the bottom margin is blank")
+
+	for range printerBusyPolls - 1 {
+		status = printerStatus(sendPacket(p, printerPacket(printerCmdStatus, 0, nil)))
+		require.Equal(t, uint8(printerStatusPrinting), status)
+	}
+
+	status = printerStatus(sendPacket(p, printerPacket(printerCmdStatus, 0, nil)))
+	require.Zero(t, status, "printing finishes")
+	require.NoError(t, p.Err())
+}
+
+func TestPrinter_OverTheLinkCable(t *testing.T) {
+	b := newBus()
+	p := NewPrinter(nil)
+	b.serial.SetPeer(p)
+
+	var replies []uint8
+
+	for _, out := range printerPacket(printerCmdInit, 0, nil) {
+		b.Write(regSB, out)
+		b.Write(regSC, 0x81)
+		b.tick(serialByteCycles, false)
+
+		in, _ := b.Read(regSB)
+		replies = append(replies, in)
+	}
+
+	require.Equal(t, uint8(printerAlive), replies[len(replies)-2])
+}
+
+func TestPNGDirectory(t *testing.T) {
+	dir := t.TempDir()
+	handler := PNGDirectory(dir)
+
+	strip := renderPrinterStrip(printerBand(3), printerDefaultBGP, 0, 0)
+	require.NoError(t, handler(strip))
+	require.NoError(t, handler(strip))
+
+	f, err := os.Open(filepath.Join(dir, "print-002.png"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, ScreenWidth, 16), img.Bounds())
+	require.Equal(t, dmgShades[3], img.At(0, 0))
+}
@@ -0,0 +1,26 @@
+package gb
+
+import "fmt"
+
+// RecordAudio runs rom headlessly for the given number of T-cycles and
+// streams its audio to sink at sampleRate. Nothing depends on the host:
+// there is no input and the cartridge clock is frozen, so the same ROM and
+// cycle count always produce the same samples. opts can add to that, for
+// instance a save file to start from. The save is only read, never written
+// back, so one recording never changes the next.
+func RecordAudio(rom []uint8, sink AudioSink, sampleRate int, cycles int, opts ...Option) error {
+	opts = append([]Option{WithClock(frozenClock{}), WithAudioSink(sink, sampleRate)}, opts...)
+	g, err := New(rom, opts...)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.RunCycles(cycles); err != nil {
+		return fmt.Errorf("failed to record audio: %w", err)
+	}
+
+	g.FlushAudio()
+
+	return nil
+}
@@ -0,0 +1,101 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// toneROM powers the APU and plays a steady square wave on channel 1.
+func toneROM() []uint8 {
+	rom := make([]uint8, initPC)
+
+	return append(rom,
+		0x3E, 0x80, 0xE0, 0x26, // LD A,$80 ; LDH (NR52),A
+		0x3E, 0x77, 0xE0, 0x24, // LD A,$77 ; LDH (NR50),A
+		0x3E, 0xFF, 0xE0, 0x25, // LD A,$FF ; LDH (NR51),A
+		0x3E, 0xF0, 0xE0, 0x12, // LD A,$F0 ; LDH (NR12),A
+		0x3E, 0x86, 0xE0, 0x14, // LD A,$86 ; LDH (NR14),A
+		0x18, 0xFE, // JR -2
+	)
+}
+
+// saveToneROM is a battery-backed cartridge that plays a tone at a volume
+// taken from its save: it increments RAM byte 0 and writes it to NR50.
+func saveToneROM() []uint8 {
+	rom := newTestROM(cartMBC1RAMBattery, 0x01, 0x02)
+	copy(rom[initPC:], []uint8{0xC3, 0x50, 0x01}) // JP $0150
+	copy(rom[headerEnd:], []uint8{
+		0x3E, 0x80, 0xE0, 0x26, // LD A,$80 ; LDH (NR52),A
+		0x3E, 0x0A, 0xEA, 0x00, 0x00, // LD A,$0A ; LD ($0000),A
+		0xFA, 0x00, 0xA0, 0x3C, // LD A,($A000) ; INC A
+		0xEA, 0x00, 0xA0, 0xE0, 0x24, // LD ($A000),A ; LDH (NR50),A
+		0x3E, 0xFF, 0xE0, 0x25, // LD A,$FF ; LDH (NR51),A
+		0x3E, 0xF0, 0xE0, 0x12, // LD A,$F0 ; LDH (NR12),A
+		0x3E, 0x86, 0xE0, 0x14, // LD A,$86 ; LDH (NR14),A
+		0x18, 0xFE, // JR -2
+	})
+	fixTestROMChecksums(rom)
+
+	return rom
+}
+
+func TestRecordAudio_IsDeterministic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	save := make([]uint8, Size8Kb)
+	save[0] = 0x02
+	require.NoError(t, os.WriteFile(path, save, 0o644))
+
+	testCases := []struct {
+		name string
+		rom  []uint8
+		opts []Option
+	}{
+		{"no save", toneROM(), nil},
+		// The save is read but must not be written back, or the first
+		// recording would change the second
+		{"save file", saveToneROM(), []Option{WithSaveFile(path, 0)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := func() []int16 {
+				sink := &captureSink{}
+				err := RecordAudio(tc.rom, sink, DefaultSampleRate, CyclesPerFrame*10, tc.opts...)
+				require.NoError(t, err)
+
+				return sink.samples
+			}
+
+			first := record()
+			second := record()
+
+			require.Equal(t, first, second)
+			require.GreaterOrEqual(t, len(first), 2*DefaultSampleRate*CyclesPerFrame*10/ClockRate)
+
+			peak := int16(0)
+
+			for _, sample := range first {
+				peak = max(peak, sample)
+			}
+
+			require.Positive(t, peak, "the tone is audible")
+		})
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, save, data)
+}
+
+func TestRecordAudio_CPUError(t *testing.T) {
+	rom := make([]uint8, initPC)
+	rom = append(rom, 0xD3) // illegal opcode
+
+	err := RecordAudio(rom, &captureSink{}, DefaultSampleRate, CyclesPerFrame)
+
+	require.ErrorContains(t, err, "illegal opcode")
+}
@@ -0,0 +1,282 @@
+package gb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	saveExtension  = ".sav"
+	rtcTrailerSize = 48 // 5 live + 5 latched 32-bit registers, 64-bit timestamp
+)
+
+// batteryBacked is implemented by mappers whose RAM is kept alive by a
+// battery. SaveData returns the raw .sav contents, LoadSaveData restores
+// them.
+type batteryBacked interface {
+	SaveData() []uint8
+	LoadSaveData(data []uint8) error
+}
+
+// batteryTypes are the cartridge types with a battery.
+var batteryTypes = map[uint8]bool{
+	0x03: true, // MBC1+RAM+BATTERY
+	0x06: true, // MBC2+BATTERY
+	0x09: true, // ROM+RAM+BATTERY
+	0x0D: true, // MMM01+RAM+BATTERY
+	0x0F: true, // MBC3+TIMER+BATTERY
+	0x10: true, // MBC3+TIMER+RAM+BATTERY
+	0x13: true, // MBC3+RAM+BATTERY
+	0x1B: true, // MBC5+RAM+BATTERY
+	0x1E: true, // MBC5+RUMBLE+RAM+BATTERY
+	0x22: true, // MBC7+SENSOR+RUMBLE+RAM+BATTERY
+	0xFF: true, // HuC1+RAM+BATTERY
+}
+
+// HasBattery reports whether the cartridge keeps its RAM (and clock) alive
+// while powered off.
+func (c *Cartridge) HasBattery() bool {
+	return batteryTypes[c.Type]
+}
+
+// SaveSizeError is returned when a save file does not fit the cartridge.
+type SaveSizeError struct {
+	Expected int
+	Actual   int
+}
+
+func (e *SaveSizeError) Error() string {
+	return fmt.Sprintf("save data is %d bytes, cartridge expects %d", e.Actual, e.Expected)
+}
+
+func saveRAM(ram []uint8) []uint8 {
+	return bytes.Clone(ram)
+}
+
+func loadRAM(ram []uint8, data []uint8) error {
+	if len(data) != len(ram) {
+		return &SaveSizeError{Expected: len(ram), Actual: len(data)}
+	}
+
+	copy(ram, data)
+
+	return nil
+}
+
+func (m *romRAM) SaveData() []uint8 {
+	return saveRAM(m.ram)
+}
+
+func (m *romRAM) LoadSaveData(data []uint8) error {
+	return loadRAM(m.ram, data)
+}
+
+func (m *mbc1) SaveData() []uint8 {
+	return saveRAM(m.ram)
+}
+
+func (m *mbc1) LoadSaveData(data []uint8) error {
+	return loadRAM(m.ram, data)
+}
+
+func (m *mbc2) SaveData() []uint8 {
+	return saveRAM(m.ram)
+}
+
+func (m *mbc2) LoadSaveData(data []uint8) error {
+	return loadRAM(m.ram, data)
+}
+
+func (m *mbc5) SaveData() []uint8 {
+	return saveRAM(m.ram)
+}
+
+func (m *mbc5) LoadSaveData(data []uint8) error {
+	return loadRAM(m.ram, data)
+}
+
+// SaveData returns the RAM followed, on timer cartridges, by the 48-byte RTC
+// trailer used by VBA-M, BGB and most other emulators.
+func (m *mbc3) SaveData() []uint8 {
+	data := saveRAM(m.ram)
+
+	if m.rtc != nil {
+		data = append(data, m.rtc.marshal()...)
+	}
+
+	return data
+}
+
+// LoadSaveData accepts RAM with or without an RTC trailer, so saves made
+// before the clock was emulated still load.
+func (m *mbc3) LoadSaveData(data []uint8) error {
+	if m.rtc != nil && len(data) == len(m.ram)+rtcTrailerSize {
+		m.rtc.unmarshal(data[len(m.ram):])
+		data = data[:len(m.ram)]
+	}
+
+	return loadRAM(m.ram, data)
+}
+
+// marshal encodes the clock as five live and five latched little-endian
+// 32-bit registers followed by the 64-bit UNIX time of the save.
+func (r *rtc) marshal() []uint8 {
+	r.update()
+
+	trailer := make([]uint8, rtcTrailerSize)
+	live := [5]uint8{r.seconds, r.minutes, r.hours, uint8(r.days), r.dayHigh()}
+
+	for i, value := range live {
+		binary.LittleEndian.PutUint32(trailer[i*4:], uint32(value))
+	}
+
+	for i, value := range r.latched {
+		binary.LittleEndian.PutUint32(trailer[20+i*4:], uint32(value))
+	}
+
+	binary.LittleEndian.PutUint64(trailer[40:], uint64(r.last.Unix()))
+
+	return trailer
+}
+
+// unmarshal restores a trailer written by marshal. The clock then catches up
+// with the time that passed since the save was written.
+func (r *rtc) unmarshal(trailer []uint8) {
+	register := func(i int) uint8 {
+		return uint8(binary.LittleEndian.Uint32(trailer[i*4:]))
+	}
+
+	dayHigh := register(4)
+	r.seconds = register(0)
+	r.minutes = register(1)
+	r.hours = register(2)
+	r.days = uint16(register(3)) | uint16(dayHigh&rtcDayHighBit)<<8
+	r.halted = dayHigh&rtcHaltBit != 0
+	r.dayCarry = dayHigh&rtcDayCarryBit != 0
+
+	for i := range r.latched {
+		r.latched[i] = register(5 + i)
+	}
+
+	r.last = time.Unix(int64(binary.LittleEndian.Uint64(trailer[40:])), 0)
+	r.update()
+}
+
+// SaveData returns the battery-backed state of the loaded cartridge, or nil
+// if it has no battery.
+func (b *bus) SaveData() []uint8 {
+	m, ok := b.mapper.(batteryBacked)
+
+	if !ok || b.cartridge == nil || !b.cartridge.HasBattery() {
+		return nil
+	}
+
+	return m.SaveData()
+}
+
+// LoadSaveData restores battery-backed state into the loaded cartridge.
+func (b *bus) LoadSaveData(data []uint8) error {
+	m, ok := b.mapper.(batteryBacked)
+
+	if !ok || b.cartridge == nil || !b.cartridge.HasBattery() {
+		return errors.New("cartridge has no battery-backed RAM")
+	}
+
+	return m.LoadSaveData(data)
+}
+
+// SavePath returns the .sav file that belongs next to a ROM file.
+func SavePath(romPath string) string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + saveExtension
+}
+
+// batterySaver keeps a .sav file in sync with the cartridge. Flush writes
+// unconditionally (call it on exit); MaybeFlush is meant to be called from
+// the emulation loop and writes at most once per interval, and only when the
+// data changed. A zero interval disables timed flushes.
+type batterySaver struct {
+	bus      *bus
+	path     string
+	interval time.Duration
+	clock    Clock
+
+	lastFlush time.Time
+	lastData  []uint8
+}
+
+func newBatterySaver(b *bus, path string, interval time.Duration) *batterySaver {
+	return &batterySaver{
+		bus:       b,
+		path:      path,
+		interval:  interval,
+		clock:     b.clock,
+		lastFlush: b.clock.Now(),
+	}
+}
+
+// Load restores the save file if there is one. A missing file is not an
+// error: the game simply starts without a save.
+func (s *batterySaver) Load() error {
+	data, err := os.ReadFile(s.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to read save file: %w", err)
+	}
+
+	if err := s.bus.LoadSaveData(data); err != nil {
+		return fmt.Errorf("failed to load save file %s: %w", s.path, err)
+	}
+
+	s.lastData = data
+
+	return nil
+}
+
+func (s *batterySaver) Flush() error {
+	data := s.bus.SaveData()
+	s.lastFlush = s.clock.Now()
+
+	if data == nil {
+		return nil
+	}
+
+	// Write next to the target and rename, so a crash mid-write never
+	// leaves a truncated save behind.
+	tmp := s.path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write save file: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write save file: %w", err)
+	}
+
+	s.lastData = data
+
+	return nil
+}
+
+func (s *batterySaver) MaybeFlush() error {
+	if s.interval <= 0 || s.clock.Now().Sub(s.lastFlush) < s.interval {
+		return nil
+	}
+
+	if bytes.Equal(s.bus.SaveData(), s.lastData) {
+		s.lastFlush = s.clock.Now()
+
+		return nil
+	}
+
+	return s.Flush()
+}
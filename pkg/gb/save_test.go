@@ -0,0 +1,137 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// BATTERY-BACKED SAVES
+// =============================================================================
+//
+// Cartridges with a battery keep their RAM across power cycles. Emulators
+// store it as a raw .sav file; MBC3 timer cartridges append a 48-byte RTC
+// trailer (5 live + 5 latched registers as uint32 LE, then a uint64 LE UNIX
+// timestamp).
+
+func TestSave_RoundTripsCartridgeRAM(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC1RAMBattery, 0x01, 0x02)))
+	bus.Write(0x0000, 0x0A)
+	bus.Write(0xA000, 0x12)
+	bus.Write(0xBFFF, 0x34)
+
+	data := bus.SaveData()
+	require.Len(t, data, 8*1024)
+
+	restored := newBus()
+	require.NoError(t, restored.LoadROM(newTestROM(cartMBC1RAMBattery, 0x01, 0x02)))
+	require.NoError(t, restored.LoadSaveData(data))
+	restored.Write(0x0000, 0x0A)
+
+	value, _ := restored.Read(0xA000)
+	require.Equal(t, uint8(0x12), value)
+	value, _ = restored.Read(0xBFFF)
+	require.Equal(t, uint8(0x34), value)
+}
+
+func TestSave_NoBattery(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC1RAM, 0x01, 0x02)))
+
+	require.Nil(t, bus.SaveData())
+	require.Error(t, bus.LoadSaveData(make([]uint8, 8*1024)))
+}
+
+func TestSave_WrongSize(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC5RAMBattery, 0x01, 0x03)))
+
+	var sizeErr *SaveSizeError
+	require.ErrorAs(t, bus.LoadSaveData(make([]uint8, 100)), &sizeErr)
+}
+
+func TestSave_MBC3_RTCTrailer(t *testing.T) {
+	bus, clock := newMBC3TestBus(t, cartMBC3TimerRAMBattery)
+	bus.Write(0x0000, 0x0A)
+	clock.Advance(2*time.Hour + 5*time.Second)
+
+	data := bus.SaveData()
+	require.Len(t, data, 32*1024+rtcTrailerSize)
+
+	// One day passes while the emulator is closed
+	clock.Advance(24 * time.Hour)
+
+	restored := newBus()
+	restored.clock = clock
+	require.NoError(t, restored.LoadROM(newTestROM(cartMBC3TimerRAMBattery, 0x03, 0x03)))
+	require.NoError(t, restored.LoadSaveData(data))
+	restored.Write(0x0000, 0x0A)
+
+	require.Equal(t, [5]uint8{5, 0, 2, 1, 0}, readRTC(restored))
+}
+
+func TestSave_MBC3_AcceptsSaveWithoutTrailer(t *testing.T) {
+	bus, _ := newMBC3TestBus(t, cartMBC3TimerRAMBattery)
+
+	require.NoError(t, bus.LoadSaveData(make([]uint8, 32*1024)))
+}
+
+func TestSave_Path(t *testing.T) {
+	require.Equal(t, "roms/tetris.sav", SavePath("roms/tetris.gb"))
+	require.Equal(t, "pokemon.sav", SavePath("pokemon"))
+}
+
+func TestBatterySaver_FlushAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC1RAMBattery, 0x01, 0x02)))
+	bus.Write(0x0000, 0x0A)
+	bus.Write(0xA010, 0x77)
+
+	require.NoError(t, newBatterySaver(bus, path, 0).Flush())
+
+	restored := newBus()
+	require.NoError(t, restored.LoadROM(newTestROM(cartMBC1RAMBattery, 0x01, 0x02)))
+	require.NoError(t, newBatterySaver(restored, path, 0).Load())
+	restored.Write(0x0000, 0x0A)
+
+	value, _ := restored.Read(0xA010)
+	require.Equal(t, uint8(0x77), value)
+}
+
+func TestBatterySaver_MissingFileIsNotAnError(t *testing.T) {
+	bus := newBus()
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC1RAMBattery, 0x01, 0x02)))
+
+	require.NoError(t, newBatterySaver(bus, filepath.Join(t.TempDir(), "none.sav"), 0).Load())
+}
+
+func TestBatterySaver_MaybeFlushOnInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	clock := newFakeClock()
+
+	bus := newBus()
+	bus.clock = clock
+	require.NoError(t, bus.LoadROM(newTestROM(cartMBC1RAMBattery, 0x01, 0x02)))
+	bus.Write(0x0000, 0x0A)
+
+	saver := newBatterySaver(bus, path, time.Minute)
+
+	bus.Write(0xA000, 0x01)
+	require.NoError(t, saver.MaybeFlush())
+	require.NoFileExists(t, path, "nothing is written before the interval elapses")
+
+	clock.Advance(time.Minute)
+	require.NoError(t, saver.MaybeFlush())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0x01), data[0])
+}
@@ -0,0 +1,144 @@
+package gb
+
+import "io"
+
+const (
+	scTransfer       = 0x80 // SC bit 7: transfer requested or in progress
+	scInternalClock  = 0x01 // SC bit 0: this side drives the clock
+	scWritable       = scTransfer | scInternalClock
+	serialBitCycles  = 512 // 8192 Hz internal clock
+	serialByteCycles = serialBitCycles * 8
+	linkIdle         = 0xFF // what a disconnected or unready line shifts in
+)
+
+// LinkPeer is whatever sits at the other end of the link cable.
+type LinkPeer interface {
+	// Exchange is called when this Game Boy, driving the clock, has shifted
+	// out a whole byte. It returns the byte shifted in from the peer.
+	Exchange(out uint8) uint8
+
+	// Poll is called on every tick so the peer can drive the clock instead.
+	// out is the byte waiting in SB and ready whether SC has a transfer
+	// armed on the external clock. If the peer clocked a byte into a ready
+	// Game Boy, Poll returns it and true.
+	Poll(out uint8, ready bool) (uint8, bool)
+}
+
+// serial is the link port: SB holds the byte being shifted and SC starts a
+// transfer and selects the clock. On the internal clock a byte takes 4096
+// T-cycles; on the external clock it completes whenever the peer says so.
+// Either way the serial interrupt is requested at the end.
+//
+// Reference: Pan Docs - Serial Data Transfer (Link Cable)
+// https://gbdev.io/pandocs/Serial_Data_Transfer_(Link_Cable).html
+type serial struct {
+	interrupts *interrupts
+	peer       LinkPeer
+
+	sb     uint8
+	sc     uint8
+	cycles int   // T-cycles into the current bit of an internal-clock transfer
+	bits   int   // bits already shifted in the current transfer
+	in     uint8 // the byte the peer is shifting in
+}
+
+func newSerial(i *interrupts) *serial {
+	return &serial{interrupts: i, peer: NewLoopbackPeer(nil)}
+}
+
+// SetPeer plugs a peer into the link port; nil unplugs the cable.
+func (s *serial) SetPeer(peer LinkPeer) {
+	if peer == nil {
+		peer = NewLoopbackPeer(nil)
+	}
+
+	s.peer = peer
+}
+
+func (s *serial) Read(addr uint16) uint8 {
+	if addr == regSB {
+		return s.sb
+	}
+
+	return s.sc
+}
+
+func (s *serial) Write(addr uint16, value uint8) {
+	if addr == regSB {
+		s.sb = value
+
+		return
+	}
+
+	s.sc = value & scWritable
+	s.cycles = 0
+	s.bits = 0
+}
+
+// Tick shifts SB one bit per serialBitCycles on the internal clock, most
+// significant bit first, taking the incoming bits from the peer's byte. On
+// the external clock the peer delivers a whole byte at once.
+func (s *serial) Tick(cycles int) {
+	if s.sc&scWritable == scWritable {
+		s.cycles += cycles
+
+		for s.sc&scTransfer != 0 && s.cycles >= serialBitCycles {
+			s.cycles -= serialBitCycles
+			s.shiftBit()
+		}
+
+		return
+	}
+
+	if in, ok := s.peer.Poll(s.sb, s.sc&scTransfer != 0); ok {
+		s.complete(in)
+	}
+}
+
+// shiftBit moves one bit out of SB and the peer's next bit in. The peer
+// sees the whole outgoing byte when the first bit is clocked.
+func (s *serial) shiftBit() {
+	if s.bits == 0 {
+		s.in = s.peer.Exchange(s.sb)
+	}
+
+	s.sb = s.sb<<1 | s.in>>(7-s.bits)&1
+	s.bits++
+
+	if s.bits == 8 {
+		s.complete(s.sb)
+	}
+}
+
+func (s *serial) complete(in uint8) {
+	s.sb = in
+	s.sc &^= scTransfer
+	s.cycles = 0
+	s.bits = 0
+	s.interrupts.request(interruptSerial)
+}
+
+// LoopbackPeer is an unplugged cable: every byte shifted in is 0xFF and the
+// peer never drives the clock. Bytes sent are copied to an optional writer,
+// which is how test ROMs such as Blargg's report their results.
+type LoopbackPeer struct {
+	w io.Writer
+}
+
+// NewLoopbackPeer returns a disconnected peer that copies outgoing bytes
+// to w, if not nil.
+func NewLoopbackPeer(w io.Writer) *LoopbackPeer {
+	return &LoopbackPeer{w: w}
+}
+
+func (p *LoopbackPeer) Exchange(out uint8) uint8 {
+	if p.w != nil {
+		_, _ = p.w.Write([]byte{out})
+	}
+
+	return linkIdle
+}
+
+func (p *LoopbackPeer) Poll(_ uint8, _ bool) (uint8, bool) {
+	return 0, false
+}
@@ -0,0 +1,91 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// SERIAL PORT
+// =============================================================================
+//
+// Writing SC=0x81 shifts SB out on the internal 8192 Hz clock, one bit per
+// 512 T-cycles. After eight bits SB holds the byte shifted in, SC bit 7
+// clears and the serial interrupt is requested.
+//
+// Reference: Pan Docs - Serial Data Transfer (Link Cable)
+// https://gbdev.io/pandocs/Serial_Data_Transfer_(Link_Cable).html
+
+func TestSerial_InternalClockTiming(t *testing.T) {
+	b := newBus()
+	b.Write(regSB, 0x42)
+	b.Write(regSC, 0x81)
+
+	b.tick(serialByteCycles-4, false)
+	sc, _ := b.Read(regSC)
+	require.Equal(t, uint8(0xFF), sc, "transfer still running")
+	require.Zero(t, b.interrupts.flag&interruptSerial)
+
+	b.tick(4, false)
+	sc, _ = b.Read(regSC)
+	require.Equal(t, uint8(0x7F), sc)
+	require.NotZero(t, b.interrupts.flag&interruptSerial)
+
+	sb, _ := b.Read(regSB)
+	require.Equal(t, uint8(0xFF), sb, "an unplugged cable shifts in 1s")
+}
+
+func TestSerial_InternalClockShiftsOneBitAtATime(t *testing.T) {
+	b := newBus()
+	b.Write(regSB, 0x42)
+	b.Write(regSC, 0x81)
+
+	b.tick(serialBitCycles*3, false)
+
+	// 0100_0010 shifted left three times, with 1s from the unplugged cable
+	sb, _ := b.Read(regSB)
+	require.Equal(t, uint8(0x17), sb)
+	require.Zero(t, b.interrupts.flag&interruptSerial)
+}
+
+func TestSerial_ExternalClockWaitsForPeer(t *testing.T) {
+	b := newBus()
+	b.Write(regSB, 0x42)
+	b.Write(regSC, 0x80)
+
+	b.tick(serialByteCycles*4, false)
+
+	sc, _ := b.Read(regSC)
+	require.Equal(t, uint8(0xFE), sc, "nobody drives the clock")
+	require.Zero(t, b.interrupts.flag&interruptSerial)
+}
+
+func TestSerial_LoopbackCapturesOutput(t *testing.T) {
+	cpu := newCPU()
+	var out bytes.Buffer
+	cpu.bus.serial.SetPeer(NewLoopbackPeer(&out))
+
+	// The Blargg print routine: LD A,c ; LDH (SB),A ; LD A,$81 ; LDH (SC),A ;
+	// wait: LDH A,(SC) ; BIT 7,A ; JR NZ,wait
+	printByte := func(c uint8) []uint8 {
+		return []uint8{0x3E, c, 0xE0, 0x01, 0x3E, 0x81, 0xE0, 0x02, 0xF0, 0x02, 0xCB, 0x7F, 0x20, 0xFA}
+	}
+
+	program := append(printByte('o'), printByte('k')...)
+	program = append(program, 0x18, 0xFE) // JR -2
+
+	rom := make([]uint8, initPC)
+	require.NoError(t, cpu.bus.LoadROM(append(rom, program...)))
+
+	end := initPC + uint16(len(program)) - 2
+
+	for cpu.PC() != end {
+		_, err := cpu.Step()
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, "ok", out.String())
+}
@@ -0,0 +1,559 @@
+package gb
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+const (
+	// SGBWidth and SGBHeight are the size of the Super Game Boy picture: the
+	// Game Boy screen framed by a border.
+	SGBWidth  = 256
+	SGBHeight = 224
+
+	sgbScreenX             = 48 // position of the Game Boy screen within the border
+	sgbScreenY             = 40
+	sgbPacketBytes         = 16
+	sgbPacketBits          = sgbPacketBytes * 8
+	sgbPacketsMask         = 0x07 // first byte: number of packets in the command
+	sgbCellsX              = ScreenWidth / 8
+	sgbCellsY              = ScreenHeight / 8
+	sgbTransferSize        = 0x1000
+	sgbBorderTiles         = 256
+	sgbBorderTileBytes     = 32 // SNES 4bpp
+	sgbBorderMapWidth      = 32
+	sgbBorderMapHeight     = 28
+	sgbBorderMapBytes      = sgbBorderMapWidth * sgbBorderMapHeight * 2
+	sgbBorderPaletteOffset = 0x800 // PCT_TRN: palettes 4-7, after the map and 0x100 unused bytes
+	sgbBorderPalettes      = 4     // SNES palettes 4-7
+	sgbBorderColors        = 16
+	sgbBorderFlipX         = 0x4000
+	sgbBorderFlipY         = 0x8000
+	sgbMaxPlayers          = 4
+	sgbNoTransfer          = -1
+)
+
+// SGB command codes, the upper five bits of a command's first byte.
+const (
+	sgbPal01   = 0x00
+	sgbPal23   = 0x01
+	sgbPal03   = 0x02
+	sgbPal12   = 0x03
+	sgbAttrBlk = 0x04 // ATTR_BLK
+	sgbAttrLin = 0x05 // ATTR_LIN
+	sgbAttrDiv = 0x06 // ATTR_DIV
+	sgbAttrChr = 0x07 // ATTR_CHR
+	sgbMltReq  = 0x11
+	sgbChrTrn  = 0x13
+	sgbPctTrn  = 0x14
+	sgbMaskEn  = 0x17
+)
+
+// MASK_EN modes.
+const (
+	sgbMaskCancel = 0x00
+	sgbMaskFreeze = 0x01 // keep showing the last picture
+	sgbMaskBlack  = 0x02
+	sgbMaskColor0 = 0x03 // fill with color 0
+)
+
+// ATTR_BLK control bits: which parts of a block get a palette.
+const (
+	sgbBlockInside  = 0x01
+	sgbBlockBorder  = 0x02
+	sgbBlockOutside = 0x04
+)
+
+// sgbDefaultPalette is a plain grey scale, used until the game sets its own.
+var sgbDefaultPalette = [4]uint16{0x7FFF, 0x56B5, 0x294A, 0x0000}
+
+// sgbPlayerCounts maps MLT_REQ's mode to the number of controllers.
+var sgbPlayerCounts = [4]int{1, 2, 1, 4}
+
+// sgb is the Super Game Boy side of the cartridge slot. Games talk to it by
+// pulsing P14/P15: a reset pulse (both low), then 128 bits (P14 low for 0,
+// P15 low for 1, both high in between) and a 0 stop bit make a 16-byte
+// packet, and a command is one to seven packets. The SGB colors the Game
+// Boy screen with four palettes assigned per 8x8 cell, frames it with a
+// 256x224 border and can read up to four controllers.
+//
+// CHR_TRN and PCT_TRN send their 4 KiB of data as a picture: the game
+// displays it on the next frame and the SGB reads the screen back.
+//
+// Reference: Pan Docs - Super Game Boy
+// https://gbdev.io/pandocs/SGB_Functions.html
+type sgb struct {
+	joypad  *Joypad
+	enabled bool // the cartridge header asks for SGB functions
+
+	selects   uint8 // P14/P15 as last written
+	receiving bool
+	bits      int
+	packet    [sgbPacketBytes]uint8
+	command   []uint8 // packets received so far of the current command
+
+	palettes [4][4]uint16
+	attrs    [sgbCellsX * sgbCellsY]uint8
+	mask     uint8
+
+	transfer    int // CHR_TRN or PCT_TRN waiting for the next frame
+	transferArg uint8
+
+	tiles          [sgbBorderTiles * sgbBorderTileBytes]uint8
+	borderMap      [sgbBorderMapBytes]uint8
+	borderPalettes [sgbBorderPalettes][sgbBorderColors]uint16
+
+	players int
+	player  int                   // controller whose buttons P1 shows, 0-3
+	pads    [sgbMaxPlayers]Button // controllers 2-4; the first is the Joypad
+
+	frame *image.RGBA
+}
+
+func newSGB(j *Joypad, enabled bool) *sgb {
+	s := &sgb{
+		joypad:   j,
+		enabled:  enabled,
+		selects:  p1SelectMask,
+		transfer: sgbNoTransfer,
+		players:  1,
+		frame:    image.NewRGBA(image.Rect(0, 0, SGBWidth, SGBHeight)),
+	}
+
+	for i := range s.palettes {
+		s.palettes[i] = sgbDefaultPalette
+	}
+
+	return s
+}
+
+// registerSGB puts the SGB in front of the joypad on the SGB model and takes
+// it away on the others. Commands are only carried out for cartridges that
+// ask for SGB functions; bare test images count as such. New picks the SGB
+// model for those cartridges unless the caller chose a model.
+func (b *bus) registerSGB() {
+	if b.model != ModelSGB {
+		b.sgb = nil
+		b.register(regP1, regP1, b.joypad)
+
+		return
+	}
+
+	b.sgb = newSGB(b.joypad, b.cartridge == nil || b.cartridge.SupportsSGB())
+	b.sgb.compose(b.ppu.Framebuffer())
+	b.register(regP1, regP1, b.sgb)
+}
+
+// Frame returns the composed 256x224 picture as of the last VBlank.
+func (s *sgb) Frame() *image.RGBA {
+	return s.frame
+}
+
+// SetPlayer sets the buttons held on controller 1-4. Controller 1 is the
+// Joypad; the others are only read after MLT_REQ.
+func (s *sgb) SetPlayer(player int, pressed Button) {
+	if player <= 1 {
+		s.joypad.SetState(pressed)
+
+		return
+	}
+
+	s.pads[min(player, sgbMaxPlayers)-1] = pressed
+}
+
+// Read returns P1 for the current controller. With more than one enabled
+// and both rows deselected, the low nibble is the controller's ID: 0xF for
+// the first down to 0xC for the fourth.
+func (s *sgb) Read(addr uint16) uint8 {
+	selects := s.joypad.selects
+
+	if s.players > 1 && selects == p1SelectMask {
+		return selects | (p1Lines - uint8(s.player))
+	}
+
+	if s.player == 0 {
+		return s.joypad.Read(addr)
+	}
+
+	return selects | selectedLines(selects, s.pads[s.player])
+}
+
+// Write passes P1 to the joypad and decodes packet pulses. Outside a
+// packet, deselecting both rows after reading one moves on to the next
+// controller.
+func (s *sgb) Write(addr uint16, value uint8) {
+	s.joypad.Write(addr, value)
+
+	previous := s.selects
+	s.selects = value & p1SelectMask
+
+	switch s.selects {
+	case 0x00:
+		s.receiving = true
+		s.bits = 0
+		s.packet = [sgbPacketBytes]uint8{}
+	case p1SelectMask:
+		switch {
+		case s.receiving:
+			// The release after the stop bit ends the packet
+			if s.bits > sgbPacketBits {
+				s.receiving = false
+			}
+		case s.players > 1 && previous != p1SelectMask:
+			s.player = (s.player + 1) % s.players
+		}
+	default:
+		if s.receiving && previous == p1SelectMask {
+			s.receiveBit(s.selects == p1SelectDPad)
+		}
+	}
+}
+
+// receiveBit stores the next bit of a packet, LSB first, or checks the stop
+// bit after the last one.
+func (s *sgb) receiveBit(one bool) {
+	switch {
+	case s.bits < sgbPacketBits:
+		if one {
+			s.packet[s.bits/8] |= 1 << (s.bits % 8)
+		}
+	case s.bits == sgbPacketBits && !one:
+		s.receivePacket()
+	}
+
+	s.bits++
+}
+
+// receivePacket appends a packet to the current command and runs the
+// command once all of its packets have arrived.
+func (s *sgb) receivePacket() {
+	if len(s.command) == 0 && s.packet[0]&sgbPacketsMask == 0 {
+		return
+	}
+
+	s.command = append(s.command, s.packet[:]...)
+
+	if len(s.command) < int(s.command[0]&sgbPacketsMask)*sgbPacketBytes {
+		return
+	}
+
+	if s.enabled {
+		s.execute(s.command)
+	}
+
+	s.command = s.command[:0]
+}
+
+func (s *sgb) execute(data []uint8) {
+	switch data[0] >> 3 {
+	case sgbPal01:
+		s.setPalettes(0, 1, data)
+	case sgbPal23:
+		s.setPalettes(2, 3, data)
+	case sgbPal03:
+		s.setPalettes(0, 3, data)
+	case sgbPal12:
+		s.setPalettes(1, 2, data)
+	case sgbAttrBlk:
+		s.attrBlocks(data)
+	case sgbAttrLin:
+		s.attrLines(data)
+	case sgbAttrDiv:
+		s.attrDivide(data)
+	case sgbAttrChr:
+		s.attrCells(data)
+	case sgbMltReq:
+		s.players = sgbPlayerCounts[data[1]&0x03]
+		s.player = 0
+	case sgbChrTrn, sgbPctTrn:
+		s.transfer = int(data[0] >> 3)
+		s.transferArg = data[1]
+	case sgbMaskEn:
+		s.mask = data[1] & 0x03
+	}
+}
+
+// setPalettes loads colors 1-3 of palettes a and b. Color 0 is shared by
+// all four palettes, so every PALxx command sets it for all of them.
+func (s *sgb) setPalettes(a, b int, data []uint8) {
+	for i := range s.palettes {
+		s.palettes[i][0] = binary.LittleEndian.Uint16(data[1:])
+	}
+
+	for i := 1; i < 4; i++ {
+		s.palettes[a][i] = binary.LittleEndian.Uint16(data[1+i*2:])
+		s.palettes[b][i] = binary.LittleEndian.Uint16(data[7+i*2:])
+	}
+}
+
+func (s *sgb) setAttr(x, y int, palette uint8) {
+	s.attrs[y*sgbCellsX+x] = palette & 0x03
+}
+
+// attrBlocks is ATTR_BLK: up to 18 rectangles, each of which can set a
+// palette for the cells inside it, on its edge and outside it. Setting only
+// inside or only outside colors the edge the same way.
+func (s *sgb) attrBlocks(data []uint8) {
+	for i := range int(data[1]) {
+		offset := 2 + i*6
+
+		if offset+6 > len(data) {
+			return
+		}
+
+		set := data[offset : offset+6]
+		control := set[0] & (sgbBlockInside | sgbBlockBorder | sgbBlockOutside)
+		inside, edge, outside := set[1]&0x03, set[1]>>2&0x03, set[1]>>4&0x03
+		x1, y1, x2, y2 := int(set[2]), int(set[3]), int(set[4]), int(set[5])
+
+		switch control {
+		case sgbBlockInside:
+			control |= sgbBlockBorder
+			edge = inside
+		case sgbBlockOutside:
+			control |= sgbBlockBorder
+			edge = outside
+		}
+
+		for y := range sgbCellsY {
+			for x := range sgbCellsX {
+				in := x >= x1 && x <= x2 && y >= y1 && y <= y2
+				onEdge := in && (x == x1 || x == x2 || y == y1 || y == y2)
+
+				switch {
+				case onEdge && control&sgbBlockBorder != 0:
+					s.setAttr(x, y, edge)
+				case in && !onEdge && control&sgbBlockInside != 0:
+					s.setAttr(x, y, inside)
+				case !in && control&sgbBlockOutside != 0:
+					s.setAttr(x, y, outside)
+				}
+			}
+		}
+	}
+}
+
+// attrLines is ATTR_LIN: whole rows (bit 7 set) or columns of cells, the
+// line number in bits 0-4 and the palette in bits 5-6.
+func (s *sgb) attrLines(data []uint8) {
+	for i := range int(data[1]) {
+		if 2+i >= len(data) {
+			return
+		}
+
+		value := data[2+i]
+		line := int(value & 0x1F)
+		palette := value >> 5
+
+		if value&0x80 != 0 {
+			for x := range sgbCellsX {
+				if line < sgbCellsY {
+					s.setAttr(x, line, palette)
+				}
+			}
+
+			continue
+		}
+
+		for y := range sgbCellsY {
+			if line < sgbCellsX {
+				s.setAttr(line, y, palette)
+			}
+		}
+	}
+}
+
+// attrDivide is ATTR_DIV: the screen split at one row or column, with a
+// palette each for the line itself and the two sides.
+func (s *sgb) attrDivide(data []uint8) {
+	after, before, on := data[1]&0x03, data[1]>>2&0x03, data[1]>>4&0x03
+	horizontal := data[1]&0x40 != 0
+	line := int(data[2])
+
+	for y := range sgbCellsY {
+		for x := range sgbCellsX {
+			position := x
+
+			if horizontal {
+				position = y
+			}
+
+			switch {
+			case position < line:
+				s.setAttr(x, y, before)
+			case position == line:
+				s.setAttr(x, y, on)
+			default:
+				s.setAttr(x, y, after)
+			}
+		}
+	}
+}
+
+// attrCells is ATTR_CHR: a run of per-cell palettes, four to a byte with
+// the first in the top bits, from a starting cell left to right or top to
+// bottom.
+func (s *sgb) attrCells(data []uint8) {
+	x, y := int(data[1]), int(data[2])
+	count := int(binary.LittleEndian.Uint16(data[3:]))
+	vertical := data[5]&0x01 != 0
+
+	for i := range count {
+		offset := 6 + i/4
+
+		if offset >= len(data) || x >= sgbCellsX || y >= sgbCellsY {
+			return
+		}
+
+		s.setAttr(x, y, data[offset]>>(6-2*(i%4)))
+
+		if vertical {
+			if y++; y == sgbCellsY {
+				y = 0
+				x++
+			}
+		} else if x++; x == sgbCellsX {
+			x = 0
+			y++
+		}
+	}
+}
+
+// vblank receives a finished Game Boy frame: it completes a pending
+// transfer from it and composes the SGB picture.
+func (s *sgb) vblank(screen *image.RGBA) {
+	if s.transfer != sgbNoTransfer {
+		s.receiveTransfer(screenTiles(screen))
+		s.transfer = sgbNoTransfer
+	}
+
+	s.compose(screen)
+}
+
+// receiveTransfer stores CHR_TRN border tiles (the lower or upper 128, by
+// bit 0 of the argument) or the PCT_TRN border map and its palettes.
+func (s *sgb) receiveTransfer(data []uint8) {
+	if s.transfer == sgbChrTrn {
+		copy(s.tiles[int(s.transferArg&0x01)*sgbTransferSize:], data)
+
+		return
+	}
+
+	copy(s.borderMap[:], data)
+
+	for p := range s.borderPalettes {
+		for c := range s.borderPalettes[p] {
+			offset := sgbBorderPaletteOffset + (p*sgbBorderColors+c)*2
+			s.borderPalettes[p][c] = binary.LittleEndian.Uint16(data[offset:])
+		}
+	}
+}
+
+// screenTiles reads the first 256 tiles of the screen, left to right and
+// top to bottom, back as 2bpp tile data.
+func screenTiles(screen *image.RGBA) []uint8 {
+	data := make([]uint8, sgbTransferSize)
+
+	for tile := range sgbTransferSize / tileBytes {
+		left, top := tile%sgbCellsX*8, tile/sgbCellsX*8
+
+		for row := range 8 {
+			var low, high uint8
+
+			for column := range 8 {
+				value := dmgShadeIndex(screen.RGBAAt(left+column, top+row))
+				low |= (value & 0x01) << (7 - column)
+				high |= (value >> 1) << (7 - column)
+			}
+
+			data[tile*tileBytes+row*2] = low
+			data[tile*tileBytes+row*2+1] = high
+		}
+	}
+
+	return data
+}
+
+// dmgShadeIndex recovers the shade, 0-3, the PPU drew a pixel with.
+func dmgShadeIndex(c color.RGBA) uint8 {
+	for i, value := range dmgShades {
+		if value == c {
+			return uint8(i)
+		}
+	}
+
+	return 0
+}
+
+// compose draws the colored Game Boy screen, or what MASK_EN shows in its
+// place, and lays the border over it. Border color 0 is transparent and
+// shows color 0 of palette 0.
+func (s *sgb) compose(screen *image.RGBA) {
+	backdrop := rgb555(s.palettes[0][0])
+
+	if s.mask != sgbMaskFreeze {
+		for y := range ScreenHeight {
+			for x := range ScreenWidth {
+				var c color.RGBA
+
+				switch s.mask {
+				case sgbMaskBlack:
+					c = color.RGBA{A: 0xFF}
+				case sgbMaskColor0:
+					c = backdrop
+				default:
+					palette := s.attrs[y/8*sgbCellsX+x/8]
+					c = rgb555(s.palettes[palette][dmgShadeIndex(screen.RGBAAt(x, y))])
+				}
+
+				s.frame.SetRGBA(sgbScreenX+x, sgbScreenY+y, c)
+			}
+		}
+	}
+
+	for y := range SGBHeight {
+		for x := range SGBWidth {
+			palette, index := s.borderPixel(x, y)
+
+			if index != 0 {
+				s.frame.SetRGBA(x, y, rgb555(s.borderPalettes[palette][index]))
+			} else if !insideSGBScreen(x, y) {
+				s.frame.SetRGBA(x, y, backdrop)
+			}
+		}
+	}
+}
+
+func insideSGBScreen(x, y int) bool {
+	return x >= sgbScreenX && x < sgbScreenX+ScreenWidth && y >= sgbScreenY && y < sgbScreenY+ScreenHeight
+}
+
+// borderPixel returns the palette and 4-bit color index of the border at
+// (x, y). Map entries hold the tile in bits 0-7, the palette (4-7) in bits
+// 10-12 and the flips in bits 14-15.
+func (s *sgb) borderPixel(x, y int) (int, uint8) {
+	offset := (y/8*sgbBorderMapWidth + x/8) * 2
+	entry := binary.LittleEndian.Uint16(s.borderMap[offset:])
+	row, column := y%8, x%8
+
+	if entry&sgbBorderFlipY != 0 {
+		row = 7 - row
+	}
+
+	if entry&sgbBorderFlipX != 0 {
+		column = 7 - column
+	}
+
+	// SNES 4bpp: planes 0 and 1 interleaved, then planes 2 and 3
+	base := int(entry&0xFF)*sgbBorderTileBytes + row*2
+	bit := uint8(7 - column)
+	planes := [4]uint8{s.tiles[base], s.tiles[base+1], s.tiles[base+16], s.tiles[base+17]}
+	index := uint8(0)
+
+	for i, plane := range planes {
+		index |= (plane >> bit & 0x01) << i
+	}
+
+	return int(entry>>10) & (sgbBorderPalettes - 1), index
+}
@@ -0,0 +1,375 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSGBTestBus(t *testing.T) *bus {
+	t.Helper()
+
+	c, err := newCPUForModel(ModelSGB, nil)
+	require.NoError(t, err)
+	require.NotNil(t, c.bus.sgb)
+
+	return c.bus
+}
+
+// sendSGB pulses a command through P1, padding it to whole packets.
+func sendSGB(b *bus, command ...uint8) {
+	for len(command)%sgbPacketBytes != 0 {
+		command = append(command, 0x00)
+	}
+
+	for len(command) > 0 {
+		b.Write(regP1, 0x00)
+		b.Write(regP1, p1SelectMask)
+
+		for i := range sgbPacketBits + 1 {
+			pulse := uint8(p1SelectButtons)
+
+			if i < sgbPacketBits && command[i/8]>>(i%8)&0x01 != 0 {
+				pulse = p1SelectDPad
+			}
+
+			b.Write(regP1, pulse)
+			b.Write(regP1, p1SelectMask)
+		}
+
+		command = command[sgbPacketBytes:]
+	}
+}
+
+// sgbHeader is the first byte of a command.
+func sgbHeader(command, packets uint8) uint8 {
+	return command<<3 | packets
+}
+
+// sgbAttrs returns the palette of every cell as rows of digits.
+func sgbAttrs(s *sgb) []string {
+	rows := make([]string, sgbCellsY)
+
+	for y := range sgbCellsY {
+		row := make([]byte, sgbCellsX)
+
+		for x := range sgbCellsX {
+			row[x] = '0' + s.attrs[y*sgbCellsX+x]
+		}
+
+		rows[y] = string(row)
+	}
+
+	return rows
+}
+
+// transferScreen draws 4 KiB of tile data the way a game displays it for
+// CHR_TRN and PCT_TRN: tiles 0-255 left to right, 20 per row.
+func transferScreen(data []uint8) *image.RGBA {
+	screen := image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+
+	for tile := range sgbTransferSize / tileBytes {
+		left, top := tile%sgbCellsX*8, tile/sgbCellsX*8
+
+		for row := range 8 {
+			low, high := data[tile*tileBytes+row*2], data[tile*tileBytes+row*2+1]
+
+			for column := range 8 {
+				value := pixelColor(low, high, uint8(7-column))
+				screen.SetRGBA(left+column, top+row, dmgShades[value])
+			}
+		}
+	}
+
+	return screen
+}
+
+// =============================================================================
+// PACKETS AND PALETTES
+// =============================================================================
+//
+// A packet is a reset pulse (P14 and P15 low), 128 bits sent LSB first (P14
+// low for 0, P15 low for 1, both high in between) and a 0 stop bit. The
+// first byte of a command is its code times 8 plus its packet count.
+// PAL01/PAL23/PAL03/PAL12 set colors 1-3 of two palettes and the shared
+// color 0.
+//
+// Reference: Pan Docs - SGB Functions
+// https://gbdev.io/pandocs/SGB_Functions.html
+
+func TestSGB_PaletteCommands(t *testing.T) {
+	testCases := []struct {
+		command uint8
+		a, b    int
+	}{
+		{sgbPal01, 0, 1},
+		{sgbPal23, 2, 3},
+		{sgbPal03, 0, 3},
+		{sgbPal12, 1, 2},
+	}
+
+	for _, tc := range testCases {
+		b := newSGBTestBus(t)
+		s := b.sgb
+
+		sendSGB(b, sgbHeader(tc.command, 1),
+			0x11, 0x11,
+			0x01, 0x00, 0x02, 0x00, 0x03, 0x00,
+			0x04, 0x00, 0x05, 0x00, 0x06, 0x00,
+		)
+
+		for i := range s.palettes {
+			require.Equal(t, uint16(0x1111), s.palettes[i][0], "color 0 is shared")
+		}
+
+		require.Equal(t, [4]uint16{0x1111, 1, 2, 3}, s.palettes[tc.a])
+		require.Equal(t, [4]uint16{0x1111, 4, 5, 6}, s.palettes[tc.b])
+	}
+}
+
+func TestSGB_IgnoresBrokenPackets(t *testing.T) {
+	b := newSGBTestBus(t)
+
+	// A stop bit of 1 drops the packet
+	b.Write(regP1, 0x00)
+	b.Write(regP1, p1SelectMask)
+
+	for range sgbPacketBits + 1 {
+		b.Write(regP1, p1SelectDPad)
+		b.Write(regP1, p1SelectMask)
+	}
+
+	require.Empty(t, b.sgb.command)
+	require.Equal(t, sgbDefaultPalette, b.sgb.palettes[0])
+}
+
+func TestSGB_OnlyForSGBCartridges(t *testing.T) {
+	c, err := newCPUForModel(ModelSGB, nil)
+	require.NoError(t, err)
+	require.NoError(t, c.bus.LoadROM(newTestROM(0x00, 0x00, 0x00)))
+	c.reset()
+
+	sendSGB(c.bus, sgbHeader(sgbPal01, 1), 0x11, 0x11)
+	require.Equal(t, sgbDefaultPalette, c.bus.sgb.palettes[0], "header lacks the SGB flag")
+
+	dmg, err := newCPUForModel(ModelDMG, nil)
+	require.NoError(t, err)
+	require.Nil(t, dmg.bus.sgb)
+}
+
+// =============================================================================
+// ATTRIBUTES
+// =============================================================================
+//
+// The screen is 20x18 cells of 8x8 pixels, each using one of the four
+// palettes. ATTR_BLK sets rectangles, ATTR_LIN whole rows or columns,
+// ATTR_DIV splits the screen in two and ATTR_CHR lists cells one by one.
+
+func TestSGB_AttributeCommands(t *testing.T) {
+	testCases := []struct {
+		name    string
+		command []uint8
+		want    map[[2]int]uint8 // cell (x, y) -> palette
+	}{
+		{
+			name: "ATTR_BLK inside only also colors the edge",
+			command: []uint8{sgbHeader(sgbAttrBlk, 1), 1,
+				sgbBlockInside, 0x01, 2, 2, 4, 4},
+			want: map[[2]int]uint8{{2, 2}: 1, {3, 3}: 1, {4, 4}: 1, {5, 5}: 0, {1, 2}: 0},
+		},
+		{
+			name: "ATTR_BLK inside, edge and outside",
+			command: []uint8{sgbHeader(sgbAttrBlk, 1), 1,
+				sgbBlockInside | sgbBlockBorder | sgbBlockOutside, 0x01 | 0x02<<2 | 0x03<<4, 2, 2, 4, 4},
+			want: map[[2]int]uint8{{3, 3}: 1, {2, 3}: 2, {4, 4}: 2, {0, 0}: 3, {19, 17}: 3},
+		},
+		{
+			name: "ATTR_BLK second set in a second packet",
+			command: []uint8{sgbHeader(sgbAttrBlk, 2), 3,
+				sgbBlockOutside, 0x01 << 4, 0, 0, 0, 0, // outside only: the edge too
+				sgbBlockInside, 0x02, 10, 10, 10, 10,
+				sgbBlockInside, 0x03, 19, 17, 19, 17},
+			want: map[[2]int]uint8{{0, 0}: 1, {5, 5}: 1, {10, 10}: 2, {19, 17}: 3},
+		},
+		{
+			name:    "ATTR_LIN",
+			command: []uint8{sgbHeader(sgbAttrLin, 1), 2, 0x80 | 0x20 | 3, 0x40 | 7},
+			want:    map[[2]int]uint8{{0, 3}: 1, {19, 3}: 1, {7, 0}: 2, {7, 17}: 2, {7, 3}: 2, {0, 0}: 0},
+		},
+		{
+			name:    "ATTR_DIV vertical",
+			command: []uint8{sgbHeader(sgbAttrDiv, 1), 0x01 | 0x02<<2 | 0x03<<4, 5},
+			want:    map[[2]int]uint8{{4, 0}: 2, {5, 9}: 3, {6, 17}: 1},
+		},
+		{
+			name:    "ATTR_DIV horizontal",
+			command: []uint8{sgbHeader(sgbAttrDiv, 1), 0x40 | 0x01 | 0x02<<2 | 0x03<<4, 5},
+			want:    map[[2]int]uint8{{19, 4}: 2, {0, 5}: 3, {0, 6}: 1},
+		},
+		{
+			name:    "ATTR_CHR left to right, wrapping",
+			command: []uint8{sgbHeader(sgbAttrChr, 1), 18, 0, 4, 0, 0, 0b01_10_11_01},
+			want:    map[[2]int]uint8{{18, 0}: 1, {19, 0}: 2, {0, 1}: 3, {1, 1}: 1, {2, 1}: 0},
+		},
+		{
+			name:    "ATTR_CHR top to bottom",
+			command: []uint8{sgbHeader(sgbAttrChr, 1), 3, 16, 3, 0, 1, 0b11_10_01_00},
+			want:    map[[2]int]uint8{{3, 16}: 3, {3, 17}: 2, {4, 0}: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newSGBTestBus(t)
+			sendSGB(b, tc.command...)
+
+			for cell, palette := range tc.want {
+				require.Equal(t, palette, b.sgb.attrs[cell[1]*sgbCellsX+cell[0]],
+					"cell %v\n%v", cell, sgbAttrs(b.sgb))
+			}
+		})
+	}
+}
+
+// =============================================================================
+// MULTIPLAYER
+// =============================================================================
+//
+// After MLT_REQ, P1 with both rows deselected reads the current controller's
+// ID (0xF, 0xE, ...), and deselecting both rows after reading one moves on
+// to the next controller.
+
+func TestSGB_MultiplayerRequest(t *testing.T) {
+	b := newSGBTestBus(t)
+	s := b.sgb
+
+	s.SetPlayer(1, ButtonA)
+	s.SetPlayer(2, ButtonB)
+	s.SetPlayer(4, ButtonStart)
+
+	sendSGB(b, sgbHeader(sgbMltReq, 1), 0x03)
+	require.Equal(t, 4, s.players)
+
+	// ID, then buttons, for each controller in turn
+	want := []struct {
+		id      uint8
+		buttons uint8
+	}{
+		{0x0F, 0x0E},
+		{0x0E, 0x0D},
+		{0x0D, 0x0F},
+		{0x0C, 0x07},
+		{0x0F, 0x0E},
+	}
+
+	for _, w := range want {
+		require.Equal(t, 0xC0|p1SelectMask|w.id, readReg(b, regP1))
+
+		b.Write(regP1, p1SelectDPad)
+		require.Equal(t, 0xC0|p1SelectDPad|w.buttons, readReg(b, regP1))
+		b.Write(regP1, p1SelectMask)
+	}
+
+	sendSGB(b, sgbHeader(sgbMltReq, 1), 0x00)
+	require.Equal(t, 1, s.players)
+	require.Equal(t, uint8(0xFF), readReg(b, regP1), "no ID with one player")
+}
+
+// =============================================================================
+// PICTURE
+// =============================================================================
+//
+// The SGB shows the Game Boy screen at (48, 40) of a 256x224 picture,
+// colored through the cell palettes. MASK_EN can freeze or blank it.
+// CHR_TRN (border tiles in SNES 4bpp) and PCT_TRN (the 32x28 border map and
+// four 16-color palettes) send their data as the next displayed frame.
+
+func TestSGB_ColorsTheScreen(t *testing.T) {
+	b := newSGBTestBus(t)
+	b.Write(regBGP, 0xE4)
+	b.Write(regLCDC, lcdcEnable|lcdcBGEnable|lcdcTileData)
+	writeTile(b, 0x8000, 2)
+
+	sendSGB(b, sgbHeader(sgbPal01, 1),
+		0x1F, 0x00,
+		0x01, 0x00, 0xE0, 0x03, 0x03, 0x00,
+		0x04, 0x00, 0x00, 0x7C, 0x06, 0x00,
+	)
+	sendSGB(b, sgbHeader(sgbAttrDiv, 1), 0x01, 10)
+
+	b.ppu.Tick(CyclesPerFrame)
+
+	frame := b.sgb.Frame()
+	require.Equal(t, image.Rect(0, 0, SGBWidth, SGBHeight), frame.Bounds())
+	require.Equal(t, rgb555(0x03E0), frame.RGBAAt(sgbScreenX, sgbScreenY), "palette 0, color 2")
+	require.Equal(t, rgb555(0x7C00), frame.RGBAAt(sgbScreenX+ScreenWidth-1, sgbScreenY), "palette 1, color 2")
+	require.Equal(t, rgb555(0x001F), frame.RGBAAt(0, 0), "no border: color 0 shows")
+
+	testCases := []struct {
+		mask uint8
+		want color.RGBA
+	}{
+		{sgbMaskBlack, color.RGBA{A: 0xFF}},
+		{sgbMaskColor0, rgb555(0x001F)},
+		{sgbMaskFreeze, rgb555(0x001F)}, // frozen on the previous frame
+		{sgbMaskCancel, rgb555(0x03E0)},
+	}
+
+	for _, tc := range testCases {
+		sendSGB(b, sgbHeader(sgbMaskEn, 1), tc.mask)
+		b.ppu.Tick(CyclesPerFrame)
+		require.Equal(t, tc.want, frame.RGBAAt(sgbScreenX, sgbScreenY), "mask %d", tc.mask)
+	}
+}
+
+func TestSGB_Border(t *testing.T) {
+	b := newSGBTestBus(t)
+	s := b.sgb
+
+	// Tile 1: row 0 has color 0x0F in its first pixel, color 0x01 in the last
+	tiles := make([]uint8, sgbTransferSize)
+	tiles[sgbBorderTileBytes+0] = 0x81
+	tiles[sgbBorderTileBytes+1] = 0x80
+	tiles[sgbBorderTileBytes+16] = 0x80
+	tiles[sgbBorderTileBytes+17] = 0x80
+
+	sendSGB(b, sgbHeader(sgbChrTrn, 1), 0x00)
+	s.vblank(transferScreen(tiles))
+
+	// Map: tile 1 with palette 5 at (0, 0), X-flipped with palette 6 at
+	// (1, 0), and over the top-left corner of the Game Boy screen
+	picture := make([]uint8, sgbTransferSize)
+	picture[0], picture[1] = 0x01, 0x05<<2
+	picture[2], picture[3] = 0x01, 0x06<<2|sgbBorderFlipX>>8
+	corner := (sgbScreenY/8*sgbBorderMapWidth + sgbScreenX/8) * 2
+	picture[corner], picture[corner+1] = 0x01, 0x04<<2
+
+	// Palettes 4-7 follow at 0x800; 0x700-0x7FF is unused and left as junk
+	for i := sgbBorderMapBytes; i < 0x800; i++ {
+		picture[i] = 0xAA
+	}
+
+	palette := func(p, c int) int { return 0x800 + (p*sgbBorderColors+c)*2 }
+	picture[palette(1, 0x0F)] = 0x1F
+	picture[palette(1, 0x01)] = 0xE0
+	picture[palette(2, 0x0F)+1] = 0x7C
+	picture[palette(0, 0x0F)] = 0x10
+
+	sendSGB(b, sgbHeader(sgbPctTrn, 1))
+	s.vblank(transferScreen(picture))
+	s.vblank(b.ppu.Framebuffer())
+
+	backdrop := rgb555(s.palettes[0][0])
+	frame := s.Frame()
+
+	require.Equal(t, rgb555(0x001F), frame.RGBAAt(0, 0))
+	require.Equal(t, rgb555(0x00E0), frame.RGBAAt(7, 0))
+	require.Equal(t, backdrop, frame.RGBAAt(3, 0), "border color 0 shows the backdrop")
+	require.Equal(t, rgb555(0x7C00), frame.RGBAAt(15, 0), "X flip")
+	require.Equal(t, rgb555(0x0010), frame.RGBAAt(sgbScreenX, sgbScreenY), "border covers the screen")
+	require.Equal(t, backdrop, frame.RGBAAt(sgbScreenX+1, sgbScreenY), "white Game Boy pixel")
+	require.Equal(t, [sgbBorderColors]uint16{0x0F: 0x001F, 0x01: 0x00E0}, s.borderPalettes[1])
+}
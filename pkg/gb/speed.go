@@ -0,0 +1,64 @@
+package gb
+
+const (
+	key1Armed          = 0x01
+	key1DoubleSpeed    = 0x80
+	speedSwitchMCycles = 2050 // the CPU sits idle while the clock settles
+)
+
+// speedControl is KEY1 (0xFF4D), through which CGB programs prepare a switch
+// between the 4 MiHz and 8 MiHz CPU clock. Bit 7 reads the current speed;
+// setting bit 0 arms the switch, which the next STOP carries out.
+//
+// Reference: Pan Docs - CGB Registers, KEY1
+// https://gbdev.io/pandocs/CGB_Registers.html#ff4d--key1-cgb-mode-only-prepare-speed-switch
+type speedControl struct {
+	cpu *cpu
+}
+
+func (s *speedControl) Read(_ uint16) uint8 {
+	value := uint8(0)
+
+	if s.cpu.doubleSpeed {
+		value |= key1DoubleSpeed
+	}
+
+	if s.cpu.speedSwitchArmed {
+		value |= key1Armed
+	}
+
+	return value
+}
+
+func (s *speedControl) Write(_ uint16, value uint8) {
+	if s.cpu.bus.cgbMode {
+		s.cpu.speedSwitchArmed = value&key1Armed != 0
+	}
+}
+
+// registerKEY1 maps KEY1 on color models and unmaps it on the others.
+func (c *cpu) registerKEY1() {
+	if !c.bus.model.IsCGB() {
+		c.bus.register(regKEY1, regKEY1, nil)
+
+		return
+	}
+
+	c.bus.register(regKEY1, regKEY1, &speedControl{cpu: c})
+}
+
+// DoubleSpeed reports whether the CPU runs at 8 MiHz. The timer, serial
+// port and OAM DMA follow the CPU clock; the PPU and APU do not.
+func (c *cpu) DoubleSpeed() bool {
+	return c.doubleSpeed
+}
+
+// dots converts CPU cycles to cycles of the fixed 4 MiHz clock that drives
+// the PPU and APU.
+func dots(cycles int, doubleSpeed bool) int {
+	if doubleSpeed {
+		return cycles / 2
+	}
+
+	return cycles
+}
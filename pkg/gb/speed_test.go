@@ -0,0 +1,108 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// CGB DOUBLE SPEED
+// =============================================================================
+//
+// Writing 1 to KEY1 bit 0 arms a speed switch, which the next STOP performs:
+// the CPU toggles between 4 and 8 MiHz, DIV is reset and held, and the CPU
+// idles for 2050 M-cycles. KEY1 bit 7 shows the current speed. The timer,
+// serial port and OAM DMA follow the CPU clock; the PPU and APU keep their
+// pace.
+//
+// Reference: Pan Docs - CGB Registers, KEY1
+// https://gbdev.io/pandocs/CGB_Registers.html#ff4d--key1-cgb-mode-only-prepare-speed-switch
+
+// newSpeedTestCPU returns a CGB in CGB mode running prog from 0x0000.
+func newSpeedTestCPU(t *testing.T, prog ...uint8) *cpu {
+	t.Helper()
+
+	c, err := newCPUForModel(ModelCGB, nil)
+	require.NoError(t, err)
+	require.NoError(t, c.bus.LoadROM(prog))
+	c.reset()
+	c.SetPC(0x0000)
+
+	return c
+}
+
+func TestSpeed_SwitchThroughKEY1AndSTOP(t *testing.T) {
+	// STOP ; STOP
+	c := newSpeedTestCPU(t, 0x10, 0x00, 0x10, 0x00)
+
+	require.Equal(t, uint8(0x7E), readReg(c.bus, regKEY1))
+
+	c.bus.Write(regKEY1, key1Armed)
+	require.Equal(t, uint8(0x7F), readReg(c.bus, regKEY1))
+
+	c.bus.tick(0x100, false)
+	require.NotZero(t, readReg(c.bus, regDIV))
+
+	cycles, err := c.Step()
+	require.NoError(t, err)
+	require.Equal(t, 4+speedSwitchMCycles*4, cycles)
+	require.True(t, c.DoubleSpeed())
+	require.False(t, c.stopped)
+	require.Equal(t, uint8(0xFE), readReg(c.bus, regKEY1), "double speed, disarmed")
+	require.Zero(t, readReg(c.bus, regDIV), "DIV is reset and held through the switch")
+	require.Zero(t, c.bus.timer.counter)
+
+	// Unarmed, STOP stops
+	_, err = c.Step()
+	require.NoError(t, err)
+	require.True(t, c.stopped)
+	require.True(t, c.DoubleSpeed())
+}
+
+func TestSpeed_KEY1OnlyInCGBMode(t *testing.T) {
+	dmg := newCPU()
+	dmg.bus.Write(regKEY1, key1Armed)
+	require.Equal(t, uint8(0xFF), readReg(dmg.bus, regKEY1), "no KEY1 on DMG")
+	require.False(t, dmg.speedSwitchArmed)
+
+	c, err := newCPUForModel(ModelCGB, nil)
+	require.NoError(t, err)
+	require.NoError(t, c.bus.LoadROM(newTestROM(0x00, 0x00, 0x00)))
+	c.reset()
+
+	c.bus.Write(regKEY1, key1Armed)
+	require.False(t, c.speedSwitchArmed, "locked in DMG compatibility mode")
+}
+
+func TestSpeed_PPUKeepsItsPace(t *testing.T) {
+	testCases := []struct {
+		name        string
+		doubleSpeed bool
+		div         uint8 // DIV increments every 256 CPU cycles
+		ly          uint8
+	}{
+		{"normal speed", false, 0x10, 8},
+		{"double speed", true, 0x10, 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newSpeedTestCPU(t)
+			c.bus.Write(regLCDC, 0x00)
+			c.bus.Write(regLCDC, lcdcEnable)
+			c.bus.Write(regDIV, 0x00)
+			c.doubleSpeed = tc.doubleSpeed
+
+			// NOPs for 0x1000 CPU cycles
+			for range 0x1000 / 4 {
+				_, err := c.Step()
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tc.div, readReg(c.bus, regDIV))
+			require.Equal(t, tc.ly, readReg(c.bus, regLY))
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package gb
+
+const (
+	tacEnable      = 0x04
+	tacClockMask   = 0x03
+	initDivCounter = 0xABCC // DMG value after the boot ROM
+)
+
+// tacBits maps TAC's clock select to the system counter bit whose falling
+// edge increments TIMA: 4096, 262144, 65536 and 16384 Hz.
+var tacBits = [4]uint16{1 << 9, 1 << 3, 1 << 5, 1 << 7}
+
+// timer holds DIV, TIMA, TMA and TAC. All of them hang off a 16-bit system
+// counter that advances every T-cycle: DIV is its upper byte, and TIMA
+// increments whenever the counter bit selected by TAC (ANDed with the enable
+// bit) falls from 1 to 0. Modelling the edge rather than a frequency is what
+// makes DIV writes and TAC changes tick TIMA the way hardware does.
+//
+// Reference: Pan Docs - Timer obscure behaviour
+// https://gbdev.io/pandocs/Timer_Obscure_Behaviour.html
+type timer struct {
+	interrupts *interrupts
+
+	counter uint16
+	tima    uint8
+	tma     uint8
+	tac     uint8
+	cycles  int // T-cycles not yet spent on a whole M-cycle
+
+	// overflow is set for the M-cycle after TIMA wraps, while it reads 0x00
+	// and before TMA is loaded. reloaded is set for the M-cycle in which TMA
+	// was loaded, when TIMA ignores writes.
+	overflow bool
+	reloaded bool
+
+	stopped bool // the CPU is in STOP mode, which holds the system counter
+}
+
+func newTimer(i *interrupts) *timer {
+	return &timer{interrupts: i, counter: initDivCounter}
+}
+
+func (t *timer) Read(addr uint16) uint8 {
+	switch addr {
+	case regDIV:
+		return uint8(t.counter >> 8)
+	case regTIMA:
+		return t.tima
+	case regTMA:
+		return t.tma
+	default:
+		return t.tac
+	}
+}
+
+func (t *timer) Write(addr uint16, value uint8) {
+	switch addr {
+	case regDIV:
+		t.setCounter(0)
+	case regTIMA:
+		if t.reloaded {
+			return
+		}
+
+		// Writing during the overflow cycle cancels the reload and interrupt
+		t.tima = value
+		t.overflow = false
+	case regTMA:
+		t.tma = value
+
+		if t.reloaded {
+			t.tima = value
+		}
+	default:
+		before := t.signal()
+		t.tac = value & (tacEnable | tacClockMask)
+		t.edge(before)
+	}
+}
+
+// Tick advances the system counter one M-cycle at a time.
+func (t *timer) Tick(cycles int) {
+	if t.stopped {
+		return
+	}
+
+	t.cycles += cycles
+
+	for t.cycles >= 4 {
+		t.cycles -= 4
+		t.reloaded = false
+
+		if t.overflow {
+			t.overflow = false
+			t.reloaded = true
+			t.tima = t.tma
+			t.interrupts.request(interruptTimer)
+		}
+
+		t.setCounter(t.counter + 4)
+	}
+}
+
+// signal is the AND of the TAC enable bit and the selected counter bit.
+func (t *timer) signal() bool {
+	return t.tac&tacEnable != 0 && t.counter&tacBits[t.tac&tacClockMask] != 0
+}
+
+func (t *timer) setCounter(value uint16) {
+	before := t.signal()
+	t.counter = value
+	t.edge(before)
+}
+
+// edge increments TIMA on a falling edge of the timer signal.
+func (t *timer) edge(before bool) {
+	if !before || t.signal() {
+		return
+	}
+
+	t.tima++
+
+	if t.tima == 0 {
+		t.overflow = true
+	}
+}
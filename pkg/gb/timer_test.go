@@ -0,0 +1,301 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTimerTestBus returns a bus whose system counter starts at zero.
+func newTimerTestBus() *bus {
+	b := newBus()
+	b.timer.counter = 0
+
+	return b
+}
+
+func readReg(b *bus, addr uint16) uint8 {
+	value, _ := b.Read(addr)
+
+	return value
+}
+
+// =============================================================================
+// DIV AND TIMA FREQUENCIES
+// =============================================================================
+//
+// DIV is the upper byte of a 16-bit counter incremented every T-cycle, so it
+// ticks every 256 cycles. TIMA ticks on the falling edge of counter bit 9, 3,
+// 5 or 7 depending on TAC.
+//
+// Reference: Pan Docs - Timer and Divider Registers
+// https://gbdev.io/pandocs/Timer_and_Divider_Registers.html
+
+func TestTimer_DIV(t *testing.T) {
+	b := newTimerTestBus()
+
+	b.tick(255, false)
+	require.Equal(t, uint8(0), readReg(b, regDIV))
+
+	b.tick(1, false)
+	require.Equal(t, uint8(1), readReg(b, regDIV))
+
+	b.tick(256*9, false)
+	require.Equal(t, uint8(10), readReg(b, regDIV))
+
+	b.Write(regDIV, 0x77)
+	require.Equal(t, uint8(0), readReg(b, regDIV), "any write resets DIV")
+}
+
+func TestTimer_TIMAFrequencies(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tac    uint8
+		period int
+	}{
+		{"4096 Hz", 0x04, 1024},
+		{"262144 Hz", 0x05, 16},
+		{"65536 Hz", 0x06, 64},
+		{"16384 Hz", 0x07, 256},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newTimerTestBus()
+			b.Write(regTAC, tc.tac)
+
+			b.tick(tc.period*3-4, false)
+			require.Equal(t, uint8(2), readReg(b, regTIMA))
+
+			b.tick(4, false)
+			require.Equal(t, uint8(3), readReg(b, regTIMA))
+		})
+	}
+}
+
+func TestTimer_Disabled(t *testing.T) {
+	b := newTimerTestBus()
+	b.Write(regTAC, 0x01)
+
+	b.tick(4096, false)
+	require.Equal(t, uint8(0), readReg(b, regTIMA))
+	require.Equal(t, uint8(0xF9), readReg(b, regTAC), "TAC bits 3-7 read as 1")
+}
+
+// =============================================================================
+// OVERFLOW AND RELOAD
+// =============================================================================
+//
+// When TIMA overflows it reads 0x00 for one M-cycle; only then is TMA loaded
+// and the interrupt requested. Writing TIMA in that cycle cancels both;
+// writing it in the reload cycle is ignored, and writing TMA in the reload
+// cycle goes straight through to TIMA.
+
+// overflowTimer leaves TIMA one 262144 Hz tick away from overflowing.
+func overflowTimer(b *bus) {
+	b.Write(regTAC, 0x05)
+	b.Write(regTMA, 0x42)
+	b.Write(regTIMA, 0xFF)
+	b.interrupts.flag = 0
+}
+
+func TestTimer_OverflowReloadsAfterOneMCycle(t *testing.T) {
+	b := newTimerTestBus()
+	overflowTimer(b)
+
+	b.tick(16, false)
+	require.Equal(t, uint8(0x00), readReg(b, regTIMA))
+	require.Zero(t, b.interrupts.flag&interruptTimer, "no interrupt during the delay")
+
+	b.tick(4, false)
+	require.Equal(t, uint8(0x42), readReg(b, regTIMA))
+	require.NotZero(t, b.interrupts.flag&interruptTimer)
+}
+
+func TestTimer_WriteDuringOverflowCancelsReload(t *testing.T) {
+	b := newTimerTestBus()
+	overflowTimer(b)
+
+	b.tick(16, false)
+	b.Write(regTIMA, 0x10)
+	b.tick(4, false)
+
+	require.Equal(t, uint8(0x10), readReg(b, regTIMA))
+	require.Zero(t, b.interrupts.flag&interruptTimer)
+}
+
+func TestTimer_WriteDuringReloadIsIgnored(t *testing.T) {
+	b := newTimerTestBus()
+	overflowTimer(b)
+
+	b.tick(20, false)
+	b.Write(regTIMA, 0x10)
+	require.Equal(t, uint8(0x42), readReg(b, regTIMA))
+
+	b.Write(regTMA, 0x99)
+	require.Equal(t, uint8(0x99), readReg(b, regTIMA), "TMA writes reach TIMA in the reload cycle")
+
+	b.tick(4, false)
+	b.Write(regTIMA, 0x10)
+	require.Equal(t, uint8(0x10), readReg(b, regTIMA), "one M-cycle later writes work again")
+}
+
+// =============================================================================
+// FALLING-EDGE GLITCHES
+// =============================================================================
+
+func TestTimer_DIVWriteCanTickTIMA(t *testing.T) {
+	testCases := []struct {
+		name    string
+		elapsed int
+		tima    uint8
+	}{
+		{"selected bit high", 8, 1},
+		{"selected bit low", 4, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newTimerTestBus()
+			b.Write(regTAC, 0x05) // bit 3
+
+			b.tick(tc.elapsed, false)
+			b.Write(regDIV, 0x00)
+
+			require.Equal(t, tc.tima, readReg(b, regTIMA))
+		})
+	}
+}
+
+func TestTimer_TACChangeCanTickTIMA(t *testing.T) {
+	testCases := []struct {
+		name  string
+		from  uint8
+		to    uint8
+		ticks uint8
+	}{
+		{"disable while the bit is high", 0x05, 0x01, 1},
+		{"switch to a low bit", 0x05, 0x04, 1},
+		{"switch to another high bit", 0x05, 0x06, 0},
+		{"enable while the bit is high", 0x01, 0x05, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newTimerTestBus()
+			b.Write(regTAC, tc.from)
+
+			// Counter = 0x28: bits 3 and 5 high, bits 7 and 9 low
+			b.tick(0x28, false)
+			before := readReg(b, regTIMA)
+			b.Write(regTAC, tc.to)
+
+			require.Equal(t, tc.ticks, readReg(b, regTIMA)-before)
+		})
+	}
+}
+
+func TestTimer_InterruptFromCPUStep(t *testing.T) {
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	cpu.bus.timer.counter = 0
+	overflowTimer(cpu.bus)
+
+	// NOPs: 5 steps of 4 cycles overflow and reload TIMA
+	for range 5 {
+		_, err := cpu.Step()
+		require.NoError(t, err)
+	}
+
+	require.NotZero(t, cpu.bus.interrupts.flag&interruptTimer)
+}
+
+// =============================================================================
+// CPU ACCESS TIMING
+// =============================================================================
+//
+// The CPU reads and writes the timer registers in the last M-cycle of an
+// instruction, after the timer has advanced through the earlier ones. The
+// programs below reset DIV, load TIMA=0xFE with TMA=0x7F at a 16-cycle
+// period, and then touch TIMA or TMA one M-cycle apart around the overflow,
+// the same windows Mooneye's tima_reload, tima_write_reloading and
+// tma_write_reloading probe.
+//
+// After the TIMA write the counter is 20; TIMA becomes 0xFF at 32, overflows
+// to 0x00 at 48 and is reloaded at 52.
+
+// runTimerProgram runs the setup above, then body, and returns the CPU.
+func runTimerProgram(t *testing.T, body ...uint8) *cpu {
+	t.Helper()
+
+	program := []uint8{
+		0x3E, 0x7F, 0xE0, 0x06, // LD A,$7F ; LDH (TMA),A
+		0x3E, 0x05, 0xE0, 0x07, // LD A,$05 ; LDH (TAC),A
+		0xE0, 0x04, // LDH (DIV),A ; counter = 0
+		0x3E, 0xFE, 0xE0, 0x05, // LD A,$FE ; LDH (TIMA),A
+	}
+	program = append(program, body...)
+
+	cpu := newCPU()
+	cpu.SetPC(0x0000)
+	require.NoError(t, cpu.bus.LoadROM(program))
+	cpu.bus.interrupts.flag = 0
+
+	for cpu.PC() != uint16(len(program)) {
+		_, err := cpu.Step()
+		require.NoError(t, err)
+	}
+
+	return cpu
+}
+
+// nops returns n NOPs followed by the given instruction.
+func nops(n int, instruction ...uint8) []uint8 {
+	return append(make([]uint8, n), instruction...)
+}
+
+func TestTimer_CPUReadsAroundReload(t *testing.T) {
+	testCases := []struct {
+		nops     int
+		expected uint8
+	}{
+		{0, 0xFF}, // counter 32
+		{3, 0xFF}, // counter 44
+		{4, 0x00}, // counter 48: overflowed, not yet reloaded
+		{5, 0x7F}, // counter 52: reloaded from TMA
+	}
+
+	for _, tc := range testCases {
+		cpu := runTimerProgram(t, nops(tc.nops, 0xF0, 0x05)...) // LDH A,(TIMA)
+
+		require.Equal(t, tc.expected, cpu.A(), "read after %d NOPs", tc.nops)
+	}
+}
+
+func TestTimer_CPUWritesAroundReload(t *testing.T) {
+	testCases := []struct {
+		name      string
+		reg       uint8
+		nops      int
+		tima      uint8
+		interrupt bool
+	}{
+		{"TIMA before overflow", 0x05, 1, 0x21, false},           // counter 44: 0x20 then the edge at 48
+		{"TIMA in overflow cycle cancels", 0x05, 2, 0x20, false}, // counter 48
+		{"TIMA in reload cycle is ignored", 0x05, 3, 0x7F, true}, // counter 52
+		{"TMA in overflow cycle is loaded", 0x06, 2, 0x20, true},
+		{"TMA in reload cycle goes to TIMA", 0x06, 3, 0x20, true},
+		{"TMA after reload", 0x06, 4, 0x7F, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// LD A,$20 ; NOPs ; LDH (reg),A ; NOP to see the next M-cycle
+			cpu := runTimerProgram(t, append([]uint8{0x3E, 0x20}, nops(tc.nops, 0xE0, tc.reg, 0x00)...)...)
+
+			require.Equal(t, tc.tima, readReg(cpu.bus, regTIMA))
+			require.Equal(t, tc.interrupt, cpu.bus.interrupts.flag&interruptTimer != 0)
+		})
+	}
+}
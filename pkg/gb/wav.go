@@ -0,0 +1,116 @@
+package gb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	wavHeaderSize    = 44
+	wavFormatPCM     = 1
+	wavChannels      = 2
+	wavBitsPerSample = 16
+	wavBlockAlign    = wavChannels * wavBitsPerSample / 8
+)
+
+// wavHeader is the canonical 44-byte RIFF/WAVE header for PCM data.
+type wavHeader struct {
+	RIFF          [4]byte
+	RIFFSize      uint32
+	WAVE          [4]byte
+	Fmt           [4]byte
+	FmtSize       uint32
+	Format        uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+	Data          [4]byte
+	DataSize      uint32
+}
+
+// WAVWriter is an AudioSink that writes 16-bit stereo PCM to a WAV file.
+// The header is written up front with zero sizes and patched by Close, so
+// the output depends only on the samples received.
+type WAVWriter struct {
+	w          io.WriteSeeker
+	sampleRate int
+	dataSize   uint32
+	err        error
+}
+
+// NewWAVWriter writes a placeholder header to w and returns a sink for
+// samples at the given rate.
+func NewWAVWriter(w io.WriteSeeker, sampleRate int) (*WAVWriter, error) {
+	ww := &WAVWriter{w: w, sampleRate: sampleRate}
+
+	if err := ww.writeHeader(); err != nil {
+		return nil, err
+	}
+
+	return ww, nil
+}
+
+// WriteSamples appends interleaved stereo samples. The first write error is
+// kept and reported by Close.
+func (ww *WAVWriter) WriteSamples(samples []int16) {
+	if ww.err != nil {
+		return
+	}
+
+	if err := binary.Write(ww.w, binary.LittleEndian, samples); err != nil {
+		ww.err = fmt.Errorf("failed to write WAV samples: %w", err)
+
+		return
+	}
+
+	ww.dataSize += uint32(len(samples) * 2)
+}
+
+// Close patches the header with the final sizes. It does not close the
+// underlying writer.
+func (ww *WAVWriter) Close() error {
+	if ww.err != nil {
+		return ww.err
+	}
+
+	if _, err := ww.w.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind WAV file: %w", err)
+	}
+
+	if err := ww.writeHeader(); err != nil {
+		return err
+	}
+
+	if _, err := ww.w.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to seek WAV file: %w", err)
+	}
+
+	return nil
+}
+
+func (ww *WAVWriter) writeHeader() error {
+	header := wavHeader{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		RIFFSize:      wavHeaderSize - 8 + ww.dataSize,
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		Format:        wavFormatPCM,
+		Channels:      wavChannels,
+		SampleRate:    uint32(ww.sampleRate),
+		ByteRate:      uint32(ww.sampleRate * wavBlockAlign),
+		BlockAlign:    wavBlockAlign,
+		BitsPerSample: wavBitsPerSample,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      ww.dataSize,
+	}
+
+	if err := binary.Write(ww.w, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("failed to write WAV header: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+//nolint:testpackage // testing internals
+package gb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// WAV OUTPUT
+// =============================================================================
+//
+// 16-bit little-endian stereo PCM in a canonical 44-byte RIFF header.
+
+func TestWAVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	wav, err := NewWAVWriter(f, 32000)
+	require.NoError(t, err)
+
+	wav.WriteSamples([]int16{1, -1, 0x1234, -0x1234})
+	wav.WriteSamples([]int16{0x7FFF, -0x8000})
+	require.NoError(t, wav.Close())
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, data, wavHeaderSize+12)
+
+	var header wavHeader
+	require.NoError(t, binary.Read(bytes.NewReader(data), binary.LittleEndian, &header))
+
+	require.Equal(t, "RIFF", string(header.RIFF[:]))
+	require.Equal(t, "WAVE", string(header.WAVE[:]))
+	require.Equal(t, uint32(36+12), header.RIFFSize)
+	require.Equal(t, uint16(2), header.Channels)
+	require.Equal(t, uint32(32000), header.SampleRate)
+	require.Equal(t, uint32(32000*4), header.ByteRate)
+	require.Equal(t, uint16(16), header.BitsPerSample)
+	require.Equal(t, uint32(12), header.DataSize)
+
+	require.Equal(t, []byte{0x01, 0x00, 0xFF, 0xFF, 0x34, 0x12}, data[wavHeaderSize:wavHeaderSize+6])
+}